@@ -0,0 +1,104 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package downloads
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const indexFile = "index.json"
+
+type cachedSong struct {
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// index lists the cached songs, it's kept next to them in the cache directory
+type index struct {
+	Songs map[string]*cachedSong `json:"songs"`
+	// song ids by album id, see EnqueueAlbum
+	Albums map[string][]string `json:"albums"`
+}
+
+func (i index) size() (size int64) {
+	for _, song := range i.Songs {
+		size += song.Size
+	}
+	return
+}
+
+func (m *Manager) loadIndex() error {
+	m.index = index{}
+	data, err := os.ReadFile(filepath.Join(m.dir, indexFile))
+	if err == nil {
+		err = json.Unmarshal(data, &m.index)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if m.index.Songs == nil {
+		m.index.Songs = map[string]*cachedSong{}
+	}
+	if m.index.Albums == nil {
+		m.index.Albums = map[string][]string{}
+	}
+
+	// files may have been deleted behind our back
+	for id := range m.index.Songs {
+		if _, err := os.Stat(m.songPath(id)); err != nil {
+			delete(m.index.Songs, id)
+		}
+	}
+	return nil
+}
+
+// saveIndex must be called with the mutex held
+func (m *Manager) saveIndex() {
+	data, err := json.Marshal(m.index)
+	if err != nil {
+		m.logger.PrintError("downloads: save index", err)
+		return
+	}
+
+	// write a new file so a crash doesn't leave a truncated index behind
+	path := filepath.Join(m.dir, indexFile)
+	if err := os.WriteFile(path+".new", data, 0o644); err != nil {
+		m.logger.PrintError("downloads: save index", err)
+		return
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		m.logger.PrintError("downloads: save index", err)
+	}
+}
+
+// evict deletes the least recently used songs but keep until the cache fits
+// into maxSize, it must be called with the mutex held
+func (m *Manager) evict(keep string) {
+	if m.maxSize <= 0 {
+		return
+	}
+
+	size := m.index.size()
+	for size > m.maxSize {
+		oldest := ""
+		for id, song := range m.index.Songs {
+			if id != keep && (oldest == "" || song.LastUsed.Before(m.index.Songs[oldest].LastUsed)) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+
+		m.logger.Printf("downloads: evicting %s", oldest)
+		size -= m.index.Songs[oldest].Size
+		delete(m.index.Songs, oldest)
+		m.removeFile(m.songPath(oldest))
+	}
+}
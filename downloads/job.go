@@ -0,0 +1,152 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package downloads
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// nextJob marks the first queued job as running
+func (m *Manager) nextJob() (*Job, context.Context) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.quitting {
+		return nil, nil
+	}
+	for _, job := range m.jobs {
+		if job.State == JobQueued {
+			job.State = JobRunning
+			ctx, cancel := context.WithCancel(context.Background())
+			m.cancel = cancel
+			return job, ctx
+		}
+	}
+	return nil, nil
+}
+
+func (m *Manager) download(ctx context.Context, job *Job) {
+	err := m.fetch(ctx, job)
+
+	m.mutex.Lock()
+	m.cancel()
+	m.cancel = nil
+	if !slices.Contains(m.jobs, job) {
+		// removed while downloading, the partial file may have been created
+		// after Remove deleted it
+		m.removeFile(m.partialPath(job.Id))
+		m.mutex.Unlock()
+		return
+	}
+
+	if err == nil {
+		err = os.Rename(m.partialPath(job.Id), m.songPath(job.Id))
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			m.logger.PrintError("downloads: "+job.Title, err)
+		}
+		job.State = JobFailed
+		job.Err = err
+	} else {
+		job.State = JobDone
+		m.index.Songs[job.Id] = &cachedSong{
+			Size:     job.Received,
+			LastUsed: time.Now(),
+		}
+		m.evict(job.Id)
+		m.saveIndex()
+	}
+	m.mutex.Unlock()
+
+	m.notify()
+}
+
+// fetch downloads job into its partial file, resuming what's there already.
+// The download may take long, but it's aborted once the server sends nothing
+// for the connection timeout.
+func (m *Manager) fetch(ctx context.Context, job *Job) (err error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	resetIdle := func() {}
+	if timeout := m.connection.Timeout; timeout > 0 {
+		idle := time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("no data received for %s", timeout))
+		})
+		defer idle.Stop()
+		resetIdle = func() { idle.Reset(timeout) }
+	}
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+	}()
+
+	partialPath := m.partialPath(job.Id)
+	var offset int64
+	if info, err := os.Stat(partialPath); err == nil {
+		offset = info.Size()
+	}
+
+	download, err := m.connection.DownloadContext(ctx, job.Id, offset)
+	if err != nil {
+		return err
+	}
+	defer download.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if download.Offset == 0 {
+		// the server can't resume, start over
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partialPath, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	received := download.Offset
+	m.setProgress(job, received, download.Size)
+
+	buffer := make([]byte, 64*1024)
+	lastReport := time.Now()
+	for {
+		resetIdle()
+		n, readErr := download.Body.Read(buffer)
+		if n > 0 {
+			if _, err := file.Write(buffer[:n]); err != nil {
+				return err
+			}
+			received += int64(n)
+			if time.Since(lastReport) >= progressInterval {
+				lastReport = time.Now()
+				m.setProgress(job, received, download.Size)
+				m.notify()
+			}
+		}
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return readErr
+		}
+	}
+
+	if download.Size >= 0 && received != download.Size {
+		return fmt.Errorf("got %d of %d bytes", received, download.Size)
+	}
+	m.setProgress(job, received, received)
+	return file.Close()
+}
+
+func (m *Manager) setProgress(job *Job, received, size int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job.Received = received
+	job.Size = size
+}
@@ -0,0 +1,321 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+// Package downloads keeps songs in a local cache directory so they can be
+// played without streaming them.
+package downloads
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// progressInterval limits how often progress is reported while downloading
+const progressInterval = 500 * time.Millisecond
+
+type JobState int
+
+const (
+	JobQueued JobState = iota
+	JobRunning
+	JobDone
+	JobFailed
+)
+
+// Job is a song that's queued for download
+type Job struct {
+	Id    string
+	Title string
+	State JobState
+	// bytes downloaded so far, including a resumed partial file
+	Received int64
+	// size of the file, -1 if unknown
+	Size int64
+	Err  error
+}
+
+// Manager downloads songs one at a time and keeps the cache below a size
+// limit by evicting the least recently used songs.
+type Manager struct {
+	dir        string
+	maxSize    int64
+	connection *subsonic.SubsonicConnection
+	logger     logger.LoggerInterface
+
+	mutex sync.Mutex
+	index index
+	jobs  []*Job
+	// cancels the running download
+	cancel   context.CancelFunc
+	wake     chan struct{}
+	quit     chan struct{}
+	quitOnce sync.Once
+	quitting bool
+	onChange func()
+}
+
+// NewManager uses dir as cache directory, it's created if it doesn't exist.
+// maxSize is in bytes.
+func NewManager(dir string, maxSize int64, connection *subsonic.SubsonicConnection, logger logger.LoggerInterface) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		dir:        dir,
+		maxSize:    maxSize,
+		connection: connection,
+		logger:     logger,
+		wake:       make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	if err := m.loadIndex(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OnChange registers cb to be called when jobs progress or finish. It's
+// called from the download goroutine.
+func (m *Manager) OnChange(cb func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onChange = cb
+}
+
+// Run downloads queued jobs until Quit is called
+func (m *Manager) Run() {
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-m.wake:
+		}
+
+		for {
+			job, ctx := m.nextJob()
+			if job == nil {
+				break
+			}
+			m.download(ctx, job)
+		}
+	}
+}
+
+// Quit aborts the running download, its partial file is resumed next time.
+// It can be called more than once.
+func (m *Manager) Quit() {
+	m.quitOnce.Do(func() {
+		m.mutex.Lock()
+		m.quitting = true
+		if m.cancel != nil {
+			m.cancel()
+		}
+		m.saveIndex()
+		m.mutex.Unlock()
+		close(m.quit)
+	})
+}
+
+// Enqueue downloads song id unless it's cached or queued already
+func (m *Manager) Enqueue(id, title string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.enqueue(id, title)
+}
+
+// EnqueueAlbum downloads all songs of an album and remembers them so the
+// album can be shown as cached
+func (m *Manager) EnqueueAlbum(albumId string, songs []subsonic.SubsonicEntity) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := make([]string, 0, len(songs))
+	for _, song := range songs {
+		ids = append(ids, song.Id)
+		m.enqueue(song.Id, song.Title)
+	}
+	m.index.Albums[albumId] = ids
+	m.saveIndex()
+}
+
+func (m *Manager) enqueue(id, title string) {
+	if _, ok := m.index.Songs[id]; ok {
+		return
+	}
+	for _, job := range m.jobs {
+		if job.Id == id && (job.State == JobQueued || job.State == JobRunning) {
+			return
+		}
+	}
+
+	m.jobs = slices.DeleteFunc(m.jobs, func(job *Job) bool {
+		return job.Id == id
+	})
+	m.jobs = append(m.jobs, &Job{Id: id, Title: title, Size: -1})
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Path returns the local file of song id if it's cached and marks it as used
+func (m *Manager) Path(id string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	song, ok := m.index.Songs[id]
+	if !ok {
+		return "", false
+	}
+	// saved right away so the eviction order survives a crash
+	song.LastUsed = time.Now()
+	m.saveIndex()
+	return m.songPath(id), true
+}
+
+// IsCached reports whether song id is cached
+func (m *Manager) IsCached(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, ok := m.index.Songs[id]
+	return ok
+}
+
+// IsAlbumCached reports whether all songs of an album added with EnqueueAlbum
+// are cached
+func (m *Manager) IsAlbumCached(albumId string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids, ok := m.index.Albums[albumId]
+	if !ok {
+		return false
+	}
+	for _, id := range ids {
+		if _, ok := m.index.Songs[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove cancels the download of song id and deletes it from the cache
+func (m *Manager) Remove(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.remove(id)
+	m.saveIndex()
+}
+
+// RemoveAlbum deletes all songs of an album from the cache
+func (m *Manager) RemoveAlbum(albumId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range m.index.Albums[albumId] {
+		m.remove(id)
+	}
+	delete(m.index.Albums, albumId)
+	m.saveIndex()
+}
+
+func (m *Manager) remove(id string) {
+	for _, job := range m.jobs {
+		if job.Id == id && job.State == JobRunning && m.cancel != nil {
+			m.cancel()
+		}
+	}
+	m.jobs = slices.DeleteFunc(m.jobs, func(job *Job) bool {
+		return job.Id == id
+	})
+
+	delete(m.index.Songs, id)
+	m.removeFile(m.songPath(id))
+	m.removeFile(m.partialPath(id))
+}
+
+func (m *Manager) removeFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		m.logger.PrintError("downloads: remove", err)
+	}
+}
+
+// Jobs returns a copy of the download queue
+func (m *Manager) Jobs() []Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+// ClearFinished removes finished and failed jobs from the queue
+func (m *Manager) ClearFinished() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.jobs = slices.DeleteFunc(m.jobs, func(job *Job) bool {
+		return job.State == JobDone || job.State == JobFailed
+	})
+}
+
+// RetryFailed queues failed jobs again, partial files are resumed
+func (m *Manager) RetryFailed() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	retry := false
+	for _, job := range m.jobs {
+		if job.State == JobFailed {
+			job.State = JobQueued
+			job.Err = nil
+			retry = true
+		}
+	}
+	if retry {
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Usage returns the number of cached songs and their size in bytes, and the
+// size limit
+func (m *Manager) Usage() (songs int, size, maxSize int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.index.Songs), m.index.size(), m.maxSize
+}
+
+func (m *Manager) songPath(id string) string {
+	// ids are opaque strings, they can't be used as file names as they are
+	return filepath.Join(m.dir, base64.RawURLEncoding.EncodeToString([]byte(id)))
+}
+
+func (m *Manager) partialPath(id string) string {
+	return m.songPath(id) + ".part"
+}
+
+func (m *Manager) notify() {
+	m.mutex.Lock()
+	cb := m.onChange
+	m.mutex.Unlock()
+	if cb != nil {
+		cb()
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package downloads
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer serves the songs in files by id with range support
+type fakeServer struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	files  map[string]string
+	ranges []string
+	// requests for this id block until the request is canceled
+	hang    string
+	started chan struct{}
+}
+
+func newFakeServer(t *testing.T, files map[string]string) *fakeServer {
+	s := &fakeServer{files: files, started: make(chan struct{}, 1)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		s.mutex.Lock()
+		content, ok := s.files[id]
+		hang := s.hang == id
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mutex.Unlock()
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 70, "message": "not found"}}}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		if hang {
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte(content))
+			w.(http.Flusher).Flush()
+			s.started <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		http.ServeContent(w, r, id, time.Time{}, strings.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) requestedRanges() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ranges
+}
+
+func newTestManager(t *testing.T, dir string, maxSize int64, server *fakeServer) *Manager {
+	connection := subsonic.Init(nil)
+	connection.Host = server.URL
+	m, err := NewManager(dir, maxSize, connection, logger.Init())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	go m.Run()
+	t.Cleanup(m.Quit)
+	return m
+}
+
+// download enqueues id and waits until it's done
+func download(t *testing.T, m *Manager, id string) {
+	m.Enqueue(id, id)
+	assert.Eventually(t, func() bool {
+		for _, job := range m.Jobs() {
+			if job.Id == id {
+				return job.State == JobDone || job.State == JobFailed
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDownloadResume(t *testing.T) {
+	server := newFakeServer(t, map[string]string{"a": "0123456789abcdef"})
+	dir := t.TempDir()
+	m := newTestManager(t, dir, 0, server)
+
+	// a partial file of an interrupted download
+	if err := os.WriteFile(m.partialPath("a"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	download(t, m, "a")
+	assert.Equal(t, []string{"bytes=10-"}, server.requestedRanges())
+	path, ok := m.Path("a")
+	assert.True(t, ok)
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(content))
+	assert.NoFileExists(t, m.partialPath("a"))
+}
+
+func TestDownloadEviction(t *testing.T) {
+	server := newFakeServer(t, map[string]string{
+		"a": strings.Repeat("a", 10),
+		"b": strings.Repeat("b", 10),
+		"c": strings.Repeat("c", 10),
+		"d": strings.Repeat("d", 40),
+	})
+	m := newTestManager(t, t.TempDir(), 25, server)
+
+	download(t, m, "a")
+	download(t, m, "b")
+	// a is used after b was downloaded, b is evicted first
+	_, ok := m.Path("a")
+	assert.True(t, ok)
+	download(t, m, "c")
+	assert.True(t, m.IsCached("a"))
+	assert.False(t, m.IsCached("b"))
+	assert.True(t, m.IsCached("c"))
+
+	// a song larger than the cache is kept, everything else is evicted
+	download(t, m, "d")
+	songs, size, _ := m.Usage()
+	assert.Equal(t, 1, songs)
+	assert.Equal(t, int64(40), size)
+	assert.True(t, m.IsCached("d"))
+	assert.NoFileExists(t, m.songPath("a"))
+}
+
+func TestDownloadIndex(t *testing.T) {
+	server := newFakeServer(t, map[string]string{"a": "aaaa", "b": "bbbb"})
+	dir := t.TempDir()
+	m := newTestManager(t, dir, 0, server)
+
+	m.EnqueueAlbum("album", []subsonic.SubsonicEntity{{Id: "a"}, {Id: "b"}})
+	download(t, m, "a")
+	download(t, m, "b")
+	assert.True(t, m.IsAlbumCached("album"))
+	_, ok := m.Path("a")
+	assert.True(t, ok)
+	lastUsed := m.index.Songs["a"].LastUsed
+
+	// the index is replaced, not written in place
+	assert.FileExists(t, filepath.Join(dir, indexFile))
+	assert.NoFileExists(t, filepath.Join(dir, indexFile+".new"))
+
+	// a new run, without quitting the last one, finds the songs and when they
+	// were used
+	other := newTestManager(t, dir, 0, server)
+	assert.True(t, other.IsAlbumCached("album"))
+	assert.True(t, other.index.Songs["a"].LastUsed.Equal(lastUsed))
+
+	// songs deleted behind our back are dropped
+	assert.NoError(t, os.Remove(m.songPath("b")))
+	other = newTestManager(t, dir, 0, server)
+	assert.True(t, other.IsCached("a"))
+	assert.False(t, other.IsCached("b"))
+	assert.False(t, other.IsAlbumCached("album"))
+}
+
+func TestDownloadRemoveRunning(t *testing.T) {
+	server := newFakeServer(t, map[string]string{"a": "aaaa", "b": "bbbb"})
+	server.hang = "a"
+	m := newTestManager(t, t.TempDir(), 0, server)
+
+	m.Enqueue("a", "a")
+	select {
+	case <-server.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("download didn't start")
+	}
+	m.Remove("a")
+
+	// downloads run one at a time, b is done once a was aborted
+	download(t, m, "b")
+	assert.True(t, m.IsCached("b"))
+	assert.False(t, m.IsCached("a"))
+	for _, job := range m.Jobs() {
+		assert.NotEqual(t, "a", job.Id)
+	}
+	assert.NoFileExists(t, m.songPath("a"))
+	assert.NoFileExists(t, m.partialPath("a"))
+}
+
+func TestDownloadIdleTimeout(t *testing.T) {
+	server := newFakeServer(t, map[string]string{"a": "aaaa"})
+	server.hang = "a"
+	m := newTestManager(t, t.TempDir(), 0, server)
+	m.connection.Timeout = 50 * time.Millisecond
+
+	download(t, m, "a")
+	jobs := m.Jobs()
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, JobFailed, jobs[0].State)
+		assert.ErrorContains(t, jobs[0].Err, "no data received")
+	}
+	// the partial file is resumed by a retry
+	content, err := os.ReadFile(m.partialPath("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "aaaa", string(content))
+}
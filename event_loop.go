@@ -4,20 +4,36 @@
 package main
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
 )
 
+// reconnectInterval is how often the server is checked while offline
+const reconnectInterval = 30 * time.Second
+
+// snapshotSaveInterval is how often the offline snapshot is saved if it
+// changed, so it survives a crash
+const snapshotSaveInterval = time.Minute
+
 type eventLoop struct {
 	// scrobbles are handled by background loop
 	scrobbleNowPlaying      chan string
 	scrobbleSubmissionTimer *time.Timer
+
+	// bookmarks of interrupted songs are created by background loop
+	createBookmark chan subsonic.Bookmark
+
+	// the now playing page is only refreshed while it's shown
+	nowPlayingVisible atomic.Bool
 }
 
 func (ui *Ui) initEventLoops() {
 	el := &eventLoop{
 		scrobbleNowPlaying: make(chan string, 5),
+		createBookmark:     make(chan subsonic.Bookmark, 5),
 	}
 	ui.eventLoop = el
 
@@ -39,6 +55,10 @@ func (ui *Ui) guiEventLoop() {
 	events := 0.0
 	fpsTimer := time.NewTimer(0)
 
+	// the stream title of radio stations is appended to the playing/paused status
+	statusText := ""
+	streamTitle := ""
+
 	for {
 		events++
 
@@ -65,18 +85,24 @@ func (ui *Ui) guiEventLoop() {
 
 				ui.app.QueueUpdateDraw(func() {
 					ui.playerStatus.SetText(formatPlayerStatus(statusData.Volume, statusData.Position, statusData.Duration))
+					ui.queuePage.UpdateLyricsPosition(statusData.Position)
+					ui.podcastsPage.UpdatePosition(statusData.Position)
 				})
 
 			case mpvplayer.EventStopped:
 				ui.logger.Print("mpvEvent: stopped")
+				statusText = "[red::b]Stopped[::-]"
+				streamTitle = ""
 				ui.app.QueueUpdateDraw(func() {
 					ui.startStopStatus.SetText("[red::b]Stopped[::-]")
 					ui.queuePage.UpdateQueue()
+					ui.podcastsPage.SavePositions()
 				})
 
 			case mpvplayer.EventPlaying:
 				ui.logger.Print("mpvEvent: playing")
-				statusText := "[green::b]Playing[::-]"
+				statusText = "[green::b]Playing[::-]"
+				streamTitle = ""
 
 				var currentSong mpvplayer.QueueItem
 				if mpvEvent.Data != nil {
@@ -88,7 +114,7 @@ func (ui *Ui) guiEventLoop() {
 						ui.mprisPlayer.OnSongChange(currentSong)
 					}
 
-					if ui.connection.Scrobble {
+					if ui.connection.Scrobble && currentSong.IsSong() {
 						// scrobble "now playing" event (delegate to background event loop)
 						ui.eventLoop.scrobbleNowPlaying <- currentSong.Id
 
@@ -112,14 +138,19 @@ func (ui *Ui) guiEventLoop() {
 					}
 				}
 
+				text := statusText
 				ui.app.QueueUpdateDraw(func() {
-					ui.startStopStatus.SetText(statusText)
+					ui.startStopStatus.SetText(text)
 					ui.queuePage.UpdateQueue()
+					ui.podcastsPage.SavePositions()
+					if currentSong.IsValid() {
+						ui.bookmarksPage.OfferResume(currentSong)
+					}
 				})
 
 			case mpvplayer.EventPaused:
 				ui.logger.Print("mpvEvent: paused")
-				statusText := "[yellow::b]Paused[::-]"
+				statusText = "[yellow::b]Paused[::-]"
 
 				var currentSong mpvplayer.QueueItem
 				if mpvEvent.Data != nil {
@@ -127,13 +158,14 @@ func (ui *Ui) guiEventLoop() {
 					statusText += formatSongForStatusBar(&currentSong)
 				}
 
+				text := statusText + formatStreamTitle(streamTitle)
 				ui.app.QueueUpdateDraw(func() {
-					ui.startStopStatus.SetText(statusText)
+					ui.startStopStatus.SetText(text)
 				})
 
 			case mpvplayer.EventUnpaused:
 				ui.logger.Print("mpvEvent: unpaused")
-				statusText := "[green::b]Playing[::-]"
+				statusText = "[green::b]Playing[::-]"
 
 				var currentSong mpvplayer.QueueItem
 				if mpvEvent.Data != nil {
@@ -141,8 +173,40 @@ func (ui *Ui) guiEventLoop() {
 					statusText += formatSongForStatusBar(&currentSong)
 				}
 
+				text := statusText + formatStreamTitle(streamTitle)
+				ui.app.QueueUpdateDraw(func() {
+					ui.startStopStatus.SetText(text)
+				})
+
+			case mpvplayer.EventStreamTitle:
+				if mpvEvent.Data == nil {
+					continue
+				}
+				streamTitle = mpvEvent.Data.(string)
+				if statusText == "" {
+					// nothing played yet, keep showing the version
+					continue
+				}
+
+				text := statusText + formatStreamTitle(streamTitle)
+				ui.app.QueueUpdateDraw(func() {
+					ui.startStopStatus.SetText(text)
+				})
+
+			case mpvplayer.EventModeChanged:
+				if mpvEvent.Data == nil {
+					continue
+				}
+				mode := mpvEvent.Data.(mpvplayer.ModeData)
+				ui.logger.Printf("mpvEvent: repeat %s, stop after current %v, shuffle %v", mode.RepeatMode, mode.StopAfterCurrent, mode.Shuffle)
+
+				if ui.mprisPlayer != nil {
+					ui.mprisPlayer.OnModeChange(mode.RepeatMode.LoopStatus(), mode.Shuffle)
+				}
 				ui.app.QueueUpdateDraw(func() {
-					ui.startStopStatus.SetText(statusText)
+					ui.updateModeStatus(mode)
+					// the queue is reordered when shuffle is turned on or off
+					ui.queuePage.UpdateQueue()
 				})
 
 			default:
@@ -154,6 +218,18 @@ func (ui *Ui) guiEventLoop() {
 
 // loop for blocking background tasks that would otherwise block the ui
 func (ui *Ui) backgroundEventLoop() {
+	// bookmarks are needed to offer resuming songs
+	ui.fetchBookmarks()
+
+	nowPlayingTicker := time.NewTicker(nowPlayingRefreshInterval)
+	defer nowPlayingTicker.Stop()
+
+	reconnectTicker := time.NewTicker(reconnectInterval)
+	defer reconnectTicker.Stop()
+
+	snapshotTicker := time.NewTicker(snapshotSaveInterval)
+	defer snapshotTicker.Stop()
+
 	for {
 		select {
 		case songId := <-ui.eventLoop.scrobbleNowPlaying:
@@ -167,6 +243,9 @@ func (ui *Ui) backgroundEventLoop() {
 			if currentSong, err := ui.player.GetPlayingTrack(); err != nil {
 				// user paused/stopped
 				ui.logger.Printf("not scrobbling: %v", err)
+			} else if !currentSong.IsSong() {
+				// the song was replaced by a radio station or podcast
+				ui.logger.Printf("not scrobbling: %s is not a song", currentSong.Id)
 			} else {
 				// it's still playing
 				ui.logger.Printf("scrobbling: %s", currentSong.Id)
@@ -174,14 +253,77 @@ func (ui *Ui) backgroundEventLoop() {
 					ui.logger.PrintError("scrobble submission", err)
 				}
 			}
+
+		case bookmark := <-ui.eventLoop.createBookmark:
+			ui.logger.Printf("bookmarking %s at %dms", bookmark.Entry.Id, bookmark.Position)
+			if _, err := ui.connection.CreateBookmark(bookmark.Entry.Id, bookmark.Position, ""); err != nil {
+				ui.logger.PrintError("CreateBookmark", err)
+			} else {
+				ui.fetchBookmarks()
+			}
+
+		case <-reconnectTicker.C:
+			if ui.connection.IsOffline() {
+				ui.reconnect()
+			}
+
+		case <-snapshotTicker.C:
+			if err := ui.connection.SaveSnapshot(); err != nil {
+				ui.logger.PrintError("SaveSnapshot", err)
+			}
+
+		case <-nowPlayingTicker.C:
+			if !ui.eventLoop.nowPlayingVisible.Load() {
+				continue
+			}
+			response, err := ui.connection.GetNowPlaying()
+			if err != nil {
+				ui.logger.PrintError("GetNowPlaying", err)
+				continue
+			}
+			ui.app.QueueUpdateDraw(func() {
+				ui.nowPlayingPage.setEntries(response.NowPlaying.Entry)
+			})
 		}
 	}
 }
 
+// reconnect leaves offline mode once the server is reachable again and sends
+// the changes made in the meantime, it's called from the background event loop
+func (ui *Ui) reconnect() {
+	replayed, err := ui.connection.Reconnect()
+	if ui.connection.IsOffline() {
+		return
+	}
+	if err != nil {
+		ui.logger.PrintError("replaying offline changes", err)
+	}
+	ui.logger.Printf("server is back online, sent %d offline changes", replayed)
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.updateConnectionStatus()
+	})
+	ui.fetchBookmarks()
+}
+
+// fetchBookmarks updates the bookmarks page, it's called from the background
+// event loop
+func (ui *Ui) fetchBookmarks() {
+	response, err := ui.connection.GetBookmarks()
+	if err != nil {
+		ui.logger.PrintError("GetBookmarks", err)
+		return
+	}
+	ui.app.QueueUpdateDraw(func() {
+		ui.bookmarksPage.setBookmarks(response.Bookmarks.Bookmark)
+	})
+}
+
 func (ui *Ui) addStarredToList() {
 	response, err := ui.connection.GetStarred()
 	if err != nil {
 		ui.logger.PrintError("addStarredToList", err)
+		return
 	}
 
 	for _, e := range response.Starred.Song {
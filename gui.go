@@ -8,6 +8,7 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/downloads"
 	"github.com/spezifisch/stmps/logger"
 	"github.com/spezifisch/stmps/mpvplayer"
 	"github.com/spezifisch/stmps/remote"
@@ -20,8 +21,12 @@ type Ui struct {
 	pages *tview.Pages
 
 	// top bar
-	startStopStatus *tview.TextView
-	playerStatus    *tview.TextView
+	topBar              *tview.Flex
+	startStopStatus     *tview.TextView
+	connectionStatus    *tview.TextView
+	modeStatus          *tview.TextView
+	streamProfileStatus *tview.TextView
+	playerStatus        *tview.TextView
 
 	// bottom bar
 	menuWidget *MenuWidget
@@ -38,6 +43,33 @@ type Ui struct {
 	// search page
 	searchPage *SearchPage
 
+	// albums page
+	albumsPage *AlbumsPage
+
+	// genres page
+	genresPage *GenresPage
+
+	// radio page
+	radioPage *RadioPage
+
+	// podcasts page
+	podcastsPage *PodcastsPage
+
+	// bookmarks page
+	bookmarksPage *BookmarksPage
+
+	// starred page
+	starredPage *StarredPage
+
+	// now playing page
+	nowPlayingPage *NowPlayingPage
+
+	// shares page
+	sharesPage *SharesPage
+
+	// downloads page
+	downloadsPage *DownloadsPage
+
 	// log page
 	logPage *LogPage
 
@@ -48,26 +80,43 @@ type Ui struct {
 	helpWidget           *HelpWidget
 	selectPlaylistModal  tview.Primitive
 	selectPlaylistWidget *PlaylistSelectionWidget
+	shareWidget          *ShareWidget
 
 	starIdList map[string]struct{}
+	// user ratings by id, 0 is unrated
+	ratings map[string]int
 
 	eventLoop   *eventLoop
 	mpvEvents   chan mpvplayer.UiEvent
 	mprisPlayer *remote.MprisPlayer
 
+	// stream formats to switch between, see setStreamProfile
+	streamProfiles     []subsonic.StreamProfile
+	streamProfileIndex int
+
 	playlists  []subsonic.SubsonicPlaylist
 	connection *subsonic.SubsonicConnection
-	player     *mpvplayer.Player
+	player     *mpvplayer.Switch
+	downloads  *downloads.Manager
 	logger     *logger.Logger
 }
 
 const (
 	// page identifiers (use these instead of hardcoding page names for showing/hiding)
-	PageBrowser   = "browser"
-	PageQueue     = "queue"
-	PagePlaylists = "playlists"
-	PageSearch    = "search"
-	PageLog       = "log"
+	PageBrowser    = "browser"
+	PageQueue      = "queue"
+	PagePlaylists  = "playlists"
+	PageSearch     = "search"
+	PageLog        = "log"
+	PageAlbums     = "albums"
+	PageGenres     = "genres"
+	PageRadio      = "radio"
+	PagePodcasts   = "podcasts"
+	PageBookmarks  = "bookmarks"
+	PageStarred    = "starred"
+	PageNowPlaying = "nowplaying"
+	PageShares     = "shares"
+	PageDownloads  = "downloads"
 
 	PageDeletePlaylist = "deletePlaylist"
 	PageNewPlaylist    = "newPlaylist"
@@ -75,22 +124,44 @@ const (
 	PageMessageBox     = "messageBox"
 	PageHelpBox        = "helpBox"
 	PageSelectPlaylist = "selectPlaylist"
+
+	PageSelectMusicFolder = "selectMusicFolder"
+
+	PageRadioStation       = "radioStation"
+	PageDeleteRadioStation = "deleteRadioStation"
+
+	PageNewPodcastChannel    = "newPodcastChannel"
+	PageDeletePodcastEpisode = "deletePodcastEpisode"
+
+	PageResumeBookmark = "resumeBookmark"
+
+	PageShareForm   = "shareForm"
+	PageShareUrl    = "shareUrl"
+	PageDeleteShare = "deleteShare"
 )
 
 func InitGui(indexes *[]subsonic.SubsonicIndex,
 	connection *subsonic.SubsonicConnection,
-	player *mpvplayer.Player,
+	streamProfiles []subsonic.StreamProfile,
+	streamProfileIndex int,
+	player *mpvplayer.Switch,
+	downloads *downloads.Manager,
 	logger *logger.Logger,
 	mprisPlayer *remote.MprisPlayer) (ui *Ui) {
 	ui = &Ui{
 		starIdList: map[string]struct{}{},
+		ratings:    map[string]int{},
 
 		eventLoop: nil, // initialized by initEventLoops()
 		mpvEvents: make(chan mpvplayer.UiEvent, 5),
 
+		streamProfiles:     streamProfiles,
+		streamProfileIndex: streamProfileIndex,
+
 		playlists:   []subsonic.SubsonicPlaylist{},
 		connection:  connection,
 		player:      player,
+		downloads:   downloads,
 		logger:      logger,
 		mprisPlayer: mprisPlayer,
 	}
@@ -110,6 +181,24 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 		return action, nil
 	})
 
+	ui.connectionStatus = tview.NewTextView().
+		SetTextAlign(tview.AlignRight).
+		SetDynamicColors(true).
+		SetScrollable(false)
+
+	ui.modeStatus = tview.NewTextView().
+		SetTextAlign(tview.AlignRight).
+		SetDynamicColors(true).
+		SetScrollable(false)
+
+	ui.streamProfileStatus = tview.NewTextView().
+		SetTextAlign(tview.AlignRight).
+		SetDynamicColors(true).
+		SetScrollable(false)
+	if len(streamProfiles) > 0 {
+		ui.setStreamProfile(streamProfileIndex)
+	}
+
 	statusRight := formatPlayerStatus(0, 0, 0)
 	ui.playerStatus = tview.NewTextView().SetText(statusRight).
 		SetTextAlign(tview.AlignRight).
@@ -119,6 +208,7 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 	ui.menuWidget = ui.createMenuWidget()
 	ui.helpWidget = ui.createHelpWidget()
 	ui.selectPlaylistWidget = ui.createPlaylistSelectionWidget()
+	ui.shareWidget = ui.createShareWidget()
 
 	// same as 'playlistList' except for the addToPlaylistModal
 	// - we need a specific version of this because we need different keybinds
@@ -149,9 +239,13 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 	})
 
 	// top bar: status text
-	topBarFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
+	ui.topBar = tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(ui.startStopStatus, 0, 1, false).
+		AddItem(ui.connectionStatus, 0, 0, false).
+		AddItem(ui.modeStatus, 0, 0, false).
+		AddItem(ui.streamProfileStatus, streamProfileStatusWidth(streamProfiles), 0, false).
 		AddItem(ui.playerStatus, 20, 0, false)
+	ui.updateConnectionStatus()
 
 	// browser page
 	ui.browserPage = ui.createBrowserPage(indexes)
@@ -165,6 +259,34 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 	// search page
 	ui.searchPage = ui.createSearchPage()
 
+	// albums page
+	ui.albumsPage = ui.createAlbumsPage()
+
+	// genres page
+	ui.genresPage = ui.createGenresPage()
+
+	// radio page
+	ui.radioPage = ui.createRadioPage()
+
+	// podcasts page
+	ui.podcastsPage = ui.createPodcastsPage()
+
+	// bookmarks page
+	ui.bookmarksPage = ui.createBookmarksPage()
+	player.OnInterrupt(ui.bookmarksPage.handleInterrupt)
+
+	// starred page
+	ui.starredPage = ui.createStarredPage()
+
+	// now playing page
+	ui.nowPlayingPage = ui.createNowPlayingPage()
+
+	// shares page
+	ui.sharesPage = ui.createSharesPage()
+
+	// downloads page
+	ui.downloadsPage = ui.createDownloadsPage()
+
 	// log page
 	ui.logPage = ui.createLogPage()
 
@@ -175,14 +297,32 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 		AddPage(PageDeletePlaylist, ui.playlistPage.DeletePlaylistModal, true, false).
 		AddPage(PageNewPlaylist, ui.playlistPage.NewPlaylistModal, true, false).
 		AddPage(PageAddToPlaylist, ui.browserPage.AddToPlaylistModal, true, false).
+		AddPage(PageSelectMusicFolder, ui.browserPage.MusicFolderModal, true, false).
 		AddPage(PageSelectPlaylist, ui.selectPlaylistModal, true, false).
 		AddPage(PageMessageBox, ui.messageBox, true, false).
 		AddPage(PageHelpBox, ui.helpModal, true, false).
-		AddPage(PageLog, ui.logPage.Root, true, false)
+		AddPage(PageLog, ui.logPage.Root, true, false).
+		AddPage(PageAlbums, ui.albumsPage.Root, true, false).
+		AddPage(PageGenres, ui.genresPage.Root, true, false).
+		AddPage(PageRadio, ui.radioPage.Root, true, false).
+		AddPage(PageRadioStation, ui.radioPage.StationModal, true, false).
+		AddPage(PageDeleteRadioStation, ui.radioPage.DeleteStationModal, true, false).
+		AddPage(PagePodcasts, ui.podcastsPage.Root, true, false).
+		AddPage(PageNewPodcastChannel, ui.podcastsPage.NewChannelModal, true, false).
+		AddPage(PageDeletePodcastEpisode, ui.podcastsPage.DeleteEpisodeModal, true, false).
+		AddPage(PageBookmarks, ui.bookmarksPage.Root, true, false).
+		AddPage(PageResumeBookmark, ui.bookmarksPage.ResumeModal, true, false).
+		AddPage(PageStarred, ui.starredPage.Root, true, false).
+		AddPage(PageNowPlaying, ui.nowPlayingPage.Root, true, false).
+		AddPage(PageShares, ui.sharesPage.Root, true, false).
+		AddPage(PageDeleteShare, ui.sharesPage.DeleteShareModal, true, false).
+		AddPage(PageShareForm, ui.shareWidget.FormModal, true, false).
+		AddPage(PageShareUrl, ui.shareWidget.UrlModal, true, false).
+		AddPage(PageDownloads, ui.downloadsPage.Root, true, false)
 
 	rootFlex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(topBarFlex, 1, 0, false).
+		AddItem(ui.topBar, 1, 0, false).
 		AddItem(ui.pages, 0, 1, true).
 		AddItem(ui.menuWidget.Root, 1, 0, false)
 
@@ -191,7 +331,8 @@ func InitGui(indexes *[]subsonic.SubsonicIndex,
 
 	ui.app.SetRoot(rootFlex, true).
 		SetFocus(rootFlex).
-		EnableMouse(true)
+		EnableMouse(true).
+		SetAfterDrawFunc(ui.shareWidget.writeClipboard)
 
 	ui.playlistPage.UpdatePlaylists()
 
@@ -208,6 +349,15 @@ func (ui *Ui) Run() error {
 	// run mpv event handler
 	go ui.player.EventLoop()
 
+	// download songs in the background
+	ui.downloads.OnChange(func() {
+		ui.app.QueueUpdateDraw(func() {
+			ui.downloadsPage.Load()
+			ui.browserPage.UpdateDownloads()
+		})
+	})
+	go ui.downloads.Run()
+
 	// gui main loop (blocking)
 	return ui.app.Run()
 }
@@ -244,3 +394,9 @@ func (ui *Ui) showMessageBox(text string) {
 	ui.messageBox.SetText(text)
 	ui.app.SetFocus(ui.messageBox)
 }
+
+// showErrorMessage logs err and tells the user why the action failed
+func (ui *Ui) showErrorMessage(action string, err error) {
+	ui.logger.PrintError(action, err)
+	ui.showMessageBox(fmt.Sprintf("%s failed: %s", action, subsonic.ErrorDescription(err)))
+}
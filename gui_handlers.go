@@ -4,9 +4,10 @@
 package main
 
 import (
-	"log"
+	"sort"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
 	"github.com/spezifisch/stmps/mpvplayer"
 	"github.com/spezifisch/stmps/subsonic"
 )
@@ -17,6 +18,23 @@ func (ui *Ui) handlePageInput(event *tcell.EventKey) *tcell.EventKey {
 	if ui.playlistPage.IsNewPlaylistInputFocused(focused) || ui.browserPage.IsSearchFocused(focused) || focused == ui.searchPage.searchField || ui.selectPlaylistWidget.visible {
 		return event
 	}
+	// same for text input in forms
+	if _, ok := focused.(*tview.InputField); ok {
+		return event
+	}
+	// Alt+number keys are handled by the pages (ratings)
+	if event.Modifiers()&tcell.ModAlt != 0 {
+		return event
+	}
+
+	// pages after the tenth are shown with F1, F2, ...
+	if key := event.Key(); key >= tcell.KeyF1 && key <= tcell.KeyF12 {
+		if index := 10 + int(key-tcell.KeyF1); index < len(buttonOrder) {
+			ui.ShowPage(buttonOrder[index])
+			return nil
+		}
+		return event
+	}
 
 	switch event.Rune() {
 	case '1':
@@ -34,6 +52,21 @@ func (ui *Ui) handlePageInput(event *tcell.EventKey) *tcell.EventKey {
 	case '5':
 		ui.ShowPage(PageLog)
 
+	case '6':
+		ui.ShowPage(PageAlbums)
+
+	case '7':
+		ui.ShowPage(PageGenres)
+
+	case '8':
+		ui.ShowPage(PageRadio)
+
+	case '9':
+		ui.ShowPage(PagePodcasts)
+
+	case '0':
+		ui.ShowPage(PageBookmarks)
+
 	case '?':
 		ui.ShowHelp()
 
@@ -101,9 +134,34 @@ func (ui *Ui) handlePageInput(event *tcell.EventKey) *tcell.EventKey {
 		}
 		ui.queuePage.UpdateQueue()
 
+	case '<':
+		// back to previous track, or restart the current one
+		if err := ui.player.PreviousTrack(); err != nil {
+			ui.logger.PrintError("handlePageInput: Previous", err)
+		}
+		ui.queuePage.UpdateQueue()
+
+	case 'E':
+		// cycle through repeat off, one and all
+		if err := ui.player.SetRepeatMode(ui.player.GetRepeatMode().Next()); err != nil {
+			ui.showErrorMessage("Repeat", err)
+		}
+
+	case 'Z':
+		// toggle stopping after the current song
+		if err := ui.player.SetStopAfterCurrent(!ui.player.GetStopAfterCurrent()); err != nil {
+			ui.showErrorMessage("Stop after current song", err)
+		}
+
+	case 'J':
+		ui.toggleJukebox()
+
+	case 'T':
+		ui.cycleStreamProfile()
+
 	case 's':
 		if err := ui.connection.StartScan(); err != nil {
-			ui.logger.PrintError("startScan:", err)
+			ui.showErrorMessage("Starting library scan", err)
 		}
 
 	default:
@@ -114,6 +172,28 @@ func (ui *Ui) handlePageInput(event *tcell.EventKey) *tcell.EventKey {
 }
 
 func (ui *Ui) ShowPage(name string) {
+	switch name {
+	case PageAlbums:
+		ui.albumsPage.Load()
+	case PageGenres:
+		ui.genresPage.Load()
+	case PageRadio:
+		ui.radioPage.Load()
+	case PagePodcasts:
+		ui.podcastsPage.Load()
+	case PageBookmarks:
+		ui.bookmarksPage.Load()
+	case PageStarred:
+		ui.starredPage.Load()
+	case PageNowPlaying:
+		ui.nowPlayingPage.Load()
+	case PageShares:
+		ui.sharesPage.Load()
+	case PageDownloads:
+		ui.downloadsPage.Load()
+	}
+	ui.eventLoop.nowPlayingVisible.Store(name == PageNowPlaying)
+
 	ui.pages.SwitchToPage(name)
 	ui.menuWidget.SetActivePage(name)
 	_, prim := ui.pages.GetFrontPage()
@@ -121,25 +201,61 @@ func (ui *Ui) ShowPage(name string) {
 }
 
 func (ui *Ui) Quit() {
-	if len(ui.queuePage.queueData.playerQueue) > 0 {
-		ids := make([]string, len(ui.queuePage.queueData.playerQueue))
-		for i, it := range ui.queuePage.queueData.playerQueue {
-			ids[i] = it.Id
-		}
-		// stmps always only ever plays the first song in the queue
-		pos := ui.player.GetTimePos()
-		if err := ui.connection.SavePlayQueue(ids, ids[0], int(pos)); err != nil {
-			log.Printf("error stashing play queue: %s", err)
-		}
-	} else {
-		// The only way to purge a saved play queue is to force an error by providing
-		// bad data. Therefore, we ignore errors.
-		_ = ui.connection.SavePlayQueue([]string{"XXX"}, "XXX", 0)
+	ui.podcastsPage.SavePositions()
+
+	ui.savePlayQueue()
+	if err := ui.connection.SaveSnapshot(); err != nil {
+		ui.logger.PrintError("SaveSnapshot", err)
 	}
+	ui.downloads.Quit()
 	ui.player.Quit()
 	ui.app.Stop()
 }
 
+// toggleJukebox switches between playing locally and on the server's jukebox,
+// the queue is taken along
+func (ui *Ui) toggleJukebox() {
+	enable := !ui.player.IsJukebox()
+	if enable {
+		// fails if the user isn't allowed to use the jukebox
+		if _, err := ui.connection.JukeboxStatus(); err != nil {
+			ui.showErrorMessage("Switching to the jukebox", err)
+			return
+		}
+	}
+
+	repeatMode, stopAfterCurrent := ui.player.GetRepeatMode(), ui.player.GetStopAfterCurrent()
+	if err := ui.player.UseJukebox(enable); err != nil {
+		ui.showErrorMessage("Switching output", err)
+		return
+	}
+
+	output := "locally"
+	if enable {
+		output = "on the jukebox"
+	}
+	ui.logger.Printf("playing %s", output)
+	ui.startStopStatus.SetText("[red::b]Stopped[::-] [gray]playing " + output + "[white]")
+
+	// modes are taken along unless the new output can't do them
+	mode := mpvplayer.ModeData{
+		RepeatMode:       ui.player.GetRepeatMode(),
+		StopAfterCurrent: ui.player.GetStopAfterCurrent(),
+		Shuffle:          ui.player.GetShuffle(),
+	}
+	if mode.RepeatMode != repeatMode {
+		ui.logger.Printf("repeat %s isn't possible %s, it's off", repeatMode, output)
+	}
+	if mode.StopAfterCurrent != stopAfterCurrent {
+		ui.logger.Printf("stopping after the current song isn't possible %s, it's off", output)
+	}
+	ui.updateModeStatus(mode)
+	if ui.mprisPlayer != nil {
+		ui.mprisPlayer.OnModeChange(mode.RepeatMode.LoopStatus(), mode.Shuffle)
+	}
+	ui.queuePage.UpdateQueue()
+}
+
 func (ui *Ui) handleAddRandomSongs(Id string, randomType string) {
 	ui.addRandomSongsToQueue(Id, randomType)
 	ui.queuePage.UpdateQueue()
@@ -149,6 +265,7 @@ func (ui *Ui) addRandomSongsToQueue(Id string, randomType string) {
 	response, err := ui.connection.GetRandomSongs(Id, randomType)
 	if err != nil {
 		ui.logger.Printf("addRandomSongsToQueue %s", err.Error())
+		return
 	}
 	switch randomType {
 	case "random":
@@ -164,12 +281,65 @@ func (ui *Ui) addRandomSongsToQueue(Id string, randomType string) {
 
 // make sure to call ui.QueuePage.UpdateQueue() after this
 func (ui *Ui) addSongToQueue(entity *subsonic.SubsonicEntity) {
-	uri := ui.connection.GetPlayUrl(entity)
+	queueItem := ui.makeSongQueueItem(entity)
+	ui.player.AddToQueue(&queueItem)
+}
+
+// rememberRating stores the rating the server reported for id, unless it was
+// already set in this session (the directory cache may be outdated)
+func (ui *Ui) rememberRating(id string, rating int) {
+	if _, ok := ui.ratings[id]; !ok {
+		ui.ratings[id] = rating
+	}
+}
+
+// setRating rates id on the server, false is returned if that failed
+func (ui *Ui) setRating(id string, rating int) bool {
+	if _, err := ui.connection.SetRating(id, rating); err != nil {
+		ui.showErrorMessage("Setting rating", err)
+		return false
+	}
+	ui.ratings[id] = rating
+	return true
+}
+
+// ratingFromKey returns the rating chosen with Alt+0 to Alt+5, or -1 for any
+// other key
+func ratingFromKey(event *tcell.EventKey) int {
+	if event.Modifiers()&tcell.ModAlt == 0 || event.Key() != tcell.KeyRune {
+		return -1
+	}
+	if r := event.Rune(); r >= '0' && r <= '5' {
+		return int(r - '0')
+	}
+	return -1
+}
+
+// getPlayUrl returns the downloaded file of entity if there is one, and the
+// stream url otherwise
+func (ui *Ui) getPlayUrl(entity *subsonic.SubsonicEntity) string {
+	if path, ok := ui.downloads.Path(entity.Id); ok {
+		return path
+	}
+	return ui.connection.GetPlayUrl(entity)
+}
+
+// makeSongQueueItem looks up the album name of entity, which isn't part of the
+// song information
+func (ui *Ui) makeSongQueueItem(entity *subsonic.SubsonicEntity) mpvplayer.QueueItem {
+	ui.rememberRating(entity.Id, entity.UserRating)
+	return ui.newSongQueueItem(entity)
+}
+
+// newSongQueueItem is makeSongQueueItem without touching the ui's state, it
+// may be called from a background goroutine
+func (ui *Ui) newSongQueueItem(entity *subsonic.SubsonicEntity) mpvplayer.QueueItem {
+	uri := ui.getPlayUrl(entity)
 
 	response, err := ui.connection.GetAlbum(entity.Parent)
 	album := ""
 	if err != nil {
-		ui.logger.PrintError("addSongToQueue", err)
+		ui.logger.PrintError("newSongQueueItem", err)
 	} else {
 		switch {
 		case response.Album.Name != "":
@@ -181,7 +351,7 @@ func (ui *Ui) addSongToQueue(entity *subsonic.SubsonicEntity) {
 		}
 	}
 
-	queueItem := &mpvplayer.QueueItem{
+	return mpvplayer.QueueItem{
 		Id:          entity.Id,
 		Uri:         uri,
 		Title:       entity.GetSongTitle(),
@@ -192,14 +362,27 @@ func (ui *Ui) addSongToQueue(entity *subsonic.SubsonicEntity) {
 		CoverArtId:  entity.CoverArtId,
 		DiscNumber:  entity.DiscNumber,
 	}
-	ui.player.AddToQueue(queueItem)
+}
+
+// addAlbumToQueue adds all songs of an album, in track order
+func (ui *Ui) addAlbumToQueue(entity subsonic.Ider) {
+	response, err := ui.connection.GetAlbum(entity.ID())
+	if err != nil {
+		ui.logger.Printf("addAlbumToQueue: GetAlbum %s -- %s", entity.ID(), err.Error())
+		return
+	}
+	sort.Sort(response.Album.Song)
+	for _, e := range response.Album.Song {
+		ui.addSongToQueue(&e)
+	}
+	ui.queuePage.UpdateQueue()
 }
 
 func makeSongHandler(entity *subsonic.SubsonicEntity, ui *Ui, fallbackArtist string) func() {
 	// make copy of values so this function can be used inside a loop iterating over entities
 	id := entity.Id
 	// TODO: Why aren't we doing all of this _inside_ the returned func?
-	uri := ui.connection.GetPlayUrl(entity)
+	uri := ui.getPlayUrl(entity)
 	title := entity.Title
 	artist := stringOr(entity.Artist, fallbackArtist)
 	duration := entity.Duration
@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rivo/tview"
 	"github.com/spezifisch/stmps/mpvplayer"
@@ -47,6 +48,22 @@ func formatSongForStatusBar(currentSong *mpvplayer.QueueItem) (text string) {
 	return
 }
 
+// formatRating shows a rating of 1 to 5 as stars, unrated items get nothing
+func formatRating(rating int) string {
+	if rating <= 0 {
+		return ""
+	}
+	return strings.Repeat(ratingIcon, rating)
+}
+
+// formatStreamTitle formats the ICY title of a radio stream for the status bar
+func formatStreamTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return " [gray]- [white]" + tview.Escape(title)
+}
+
 func formatSongForPlaylistEntry(entity subsonic.SubsonicEntity) (text string) {
 	if entity.Title != "" {
 		text += "[::-] [white]" + tview.Escape(entity.Title)
@@ -56,3 +73,40 @@ func formatSongForPlaylistEntry(entity subsonic.SubsonicEntity) (text string) {
 	}
 	return
 }
+
+// updateModeStatus shows the repeat mode, whether playback stops after the
+// current song and shuffle in the top bar
+func (ui *Ui) updateModeStatus(mode mpvplayer.ModeData) {
+	text := ""
+	if mode.Shuffle {
+		text += "[shuffle]"
+	}
+	switch mode.RepeatMode {
+	case mpvplayer.RepeatOne:
+		text += "[repeat one]"
+	case mpvplayer.RepeatAll:
+		text += "[repeat all]"
+	}
+	if mode.StopAfterCurrent {
+		text += "[stop after]"
+	}
+
+	if text == "" {
+		ui.modeStatus.SetText("")
+		ui.topBar.ResizeItem(ui.modeStatus, 0, 0)
+	} else {
+		ui.modeStatus.SetText("[yellow]" + tview.Escape(text) + "[-]")
+		ui.topBar.ResizeItem(ui.modeStatus, len(text)+1, 0)
+	}
+}
+
+// updateConnectionStatus shows in the top bar whether the server is offline
+func (ui *Ui) updateConnectionStatus() {
+	if ui.connection.IsOffline() {
+		ui.connectionStatus.SetText("[red]" + tview.Escape("[offline]") + "[-]")
+		ui.topBar.ResizeItem(ui.connectionStatus, 10, 0)
+	} else {
+		ui.connectionStatus.SetText("")
+		ui.topBar.ResizeItem(ui.connectionStatus, 0, 0)
+	}
+}
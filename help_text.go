@@ -7,10 +7,16 @@ const helpPlayback = `
 p      play/pause
 P      stop
 >      next song
+<      previous song (restarts the song after 3 seconds)
+E      cycle repeat mode: off, one song, whole queue
+Z      toggle stopping after the current song
 -/=(+) volume down/volume up
 ,/.    seek -10/+10 seconds
 r      add 50 random songs to queue
 s      start server library scan
+J      toggle playing on the server's jukebox
+T      switch to the next stream profile
+F1-F4  show starred, now playing, shares, downloads page
 `
 
 const helpPageBrowser = `
@@ -20,12 +26,21 @@ artist tab
   a     Add all artist songs to queue
   n     Continue search forward
   N     Continue search backwards
+  i     show/hide artist info
+  f     choose music folder
 song tab
   ENTER play song (clears current queue)
   a     add album or song to queue
   A     add song to playlist
   y     toggle star on song/album
+  w     share song/album
+  o     download song/album for offline playback
+  O     remove song/album from downloads
+  Alt+1-5 rate song/album (Alt+0 removes rating)
   R     refresh the list
+  i     show/hide artist/album info
+info panel
+  ENTER go to similar artist
 ESC   Close search
 `
 
@@ -33,10 +48,13 @@ const helpPageQueue = `
 d/DEL remove currently selected song from the queue
 D     remove all songs from queue
 y     toggle star on song
+w     share song
+Alt+1-5 rate song (Alt+0 removes rating)
 k     move selected song up in queue
 j     move selected song down in queue
 s     save queue as a playlist
-S     shuffle the current queue
+S     toggle shuffle (turned off, the queue is in order again)
+L     toggle lyrics of the playing song
 l     load last queue from server
 `
 
@@ -44,6 +62,91 @@ const helpPagePlaylists = `
 n     new playlist
 d     delete playlist
 a     add playlist or song to queue
+w     share playlist or song
+`
+
+const helpPageAlbums = `
+list column
+  Down/Up choose album order
+  Right   go to albums
+  R       reload the list
+album column
+  Enter/a add album to queue
+  n       load more albums
+  R       reload the list
+`
+
+const helpPageGenres = `
+genre, album, or song column
+  Down/Up navigate within the column
+  Left    previous column
+  Right   next column
+  Enter/a add album or song to queue
+  n       load more albums or songs
+  S       add random songs of genre to queue
+  R       reload genres (genre column)
+`
+
+const helpPageRadio = `
+ENTER play station (clears current queue)
+a     add station to queue
+n     new station
+e     edit station
+d     delete station
+R     reload stations
+`
+
+const helpPagePodcasts = `
+channel column
+  Enter/Right go to episodes
+  n           subscribe to podcast feed
+  R           reload channels
+episode column
+  Enter       play episode (clears current queue)
+  a           add episode to queue
+  o           download episode on server
+  d           delete episode from server
+  Left        back to channels
+Episodes resume where they were left.
+`
+
+const helpPageStarred = `
+artist, album, or song column
+  Down/Up navigate within the column
+  Left    previous column
+  Right   next column
+  Enter   add artist/album to queue, play song
+  a       add item to queue
+  y       remove star
+  R       reload starred items
+`
+
+const helpPageNowPlaying = `
+ENTER add song to queue
+a     add song to queue
+R     refresh now
+The list refreshes every 30 seconds.
+`
+
+const helpPageBookmarks = `
+ENTER play song at bookmark (clears current queue)
+a     add song to queue, starting at bookmark
+d     delete bookmark
+R     reload bookmarks
+`
+
+const helpPageShares = `
+ENTER show share URL
+c     show share URL (to copy it)
+e     edit description and expiry
+d     revoke share
+R     reload shares
+`
+
+const helpPageDownloads = `
+d     cancel download or remove song
+C     clear finished and failed downloads
+R     retry failed downloads
 `
 
 const helpSearchPage = `
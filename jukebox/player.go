@@ -0,0 +1,820 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package jukebox
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// the jukebox doesn't send events, its status is polled this often while it's
+// playing
+const pollInterval = time.Second
+
+// Player plays the queue on the server's jukebox instead of locally. The
+// jukebox playlist mirrors the queue, songs that were played stay at its
+// start until the queue is replaced. Like mpvplayer.Player its state is owned
+// by one goroutine that runs all commands, including polling.
+type Player struct {
+	connection *subsonic.SubsonicConnection
+	logger     logger.LoggerInterface
+	owner      *mpvplayer.Owner
+
+	// owned by the owner goroutine
+	eventConsumer mpvplayer.EventConsumer
+	// queue[0] is the current song, it's at index offset of the jukebox playlist
+	queue mpvplayer.PlayerQueue
+	// played songs, they're only kept locally
+	history      mpvplayer.PlayerQueue
+	queueVersion uint64
+	offset       int
+	// songs added while shuffled are appended, the jukebox can't insert them
+	shuffler mpvplayer.Shuffler
+
+	stopped bool
+	paused  bool
+	// position in seconds
+	position int
+	// volume between 0 and 1
+	gain float64
+
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	state atomic.Pointer[playerState]
+
+	mpvplayer.Callbacks
+}
+
+// playerState is published by the owner goroutine, it's never modified
+type playerState struct {
+	queue    mpvplayer.QueueSnapshot
+	stopped  bool
+	paused   bool
+	position int
+	shuffle  bool
+}
+
+var _ mpvplayer.QueuePlayer = (*Player)(nil)
+
+func NewPlayer(connection *subsonic.SubsonicConnection, logger logger.LoggerInterface) *Player {
+	p := &Player{
+		connection:   connection,
+		logger:       logger,
+		owner:        mpvplayer.NewOwner(),
+		queue:        make(mpvplayer.PlayerQueue, 0),
+		queueVersion: mpvplayer.NextQueueVersion(),
+		stopped:      true,
+		gain:         1,
+		quit:         make(chan struct{}),
+	}
+	p.state.Store(&playerState{
+		queue:   mpvplayer.NewQueueSnapshot(p.queueVersion, nil, nil),
+		stopped: true,
+	})
+	return p
+}
+
+// do runs cmd on the owner goroutine and publishes the resulting state
+func (p *Player) do(cmd func() error) error {
+	return p.owner.Do(func() error {
+		err := cmd()
+		p.publish()
+		return err
+	})
+}
+
+func (p *Player) publish() {
+	state := &playerState{
+		queue:    p.state.Load().queue,
+		stopped:  p.stopped,
+		paused:   p.paused,
+		position: p.position,
+		shuffle:  p.shuffler.Enabled(),
+	}
+	if state.queue.Version != p.queueVersion {
+		state.queue = mpvplayer.NewQueueSnapshot(p.queueVersion, p.history, p.queue)
+	}
+	p.state.Store(state)
+}
+
+// queueChanged must be called by the owner goroutine after modifying the queue
+func (p *Player) queueChanged() {
+	p.queueVersion = mpvplayer.NextQueueVersion()
+}
+
+func (p *Player) RegisterEventConsumer(consumer mpvplayer.EventConsumer) {
+	_ = p.do(func() error {
+		p.eventConsumer = consumer
+		return nil
+	})
+}
+
+func (p *Player) EventLoop() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-ticker.C:
+			err := p.do(func() error {
+				if !p.stopped && !p.paused {
+					p.poll()
+				}
+				return nil
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Quit stops polling, the jukebox keeps playing. It can be called more than
+// once.
+func (p *Player) Quit() {
+	p.quitOnce.Do(func() {
+		close(p.quit)
+	})
+	p.owner.Quit()
+}
+
+func (p *Player) poll() {
+	response, err := p.connection.JukeboxStatus()
+	if err != nil {
+		p.logger.PrintError("JukeboxStatus", err)
+		return
+	}
+	p.updateStatus(response.JukeboxStatus)
+}
+
+// updateStatus follows the jukebox to the next song and notices when it
+// stopped by itself
+func (p *Player) updateStatus(status subsonic.JukeboxStatus) {
+	p.position = status.Position
+	p.gain = status.Gain
+
+	if advanced := status.CurrentIndex - p.offset; advanced > 0 && advanced < len(p.queue) {
+		// the jukebox went on to the next song
+		p.history = mpvplayer.AppendHistory(p.history, p.queue[:advanced]...)
+		p.queue = p.queue[advanced:]
+		p.queueChanged()
+		p.offset = status.CurrentIndex
+		p.sendEvent(mpvplayer.EventPlaying, p.queue[0])
+	} else if !status.Playing && !p.stopped && !p.paused {
+		if p.isAtEnd(status) {
+			p.logger.Print("jukebox: stopping (auto)")
+			p.history = mpvplayer.AppendHistory(p.history, p.queue...)
+			p.queue = make(mpvplayer.PlayerQueue, 0)
+			p.queueChanged()
+			p.offset = 0
+			p.stopped = true
+			if _, err := p.connection.JukeboxClear(); err != nil {
+				p.logger.PrintError("JukeboxClear", err)
+			}
+			p.sendEvent(mpvplayer.EventStopped, nil)
+		} else {
+			// paused by another client
+			p.paused = true
+			p.sendEvent(mpvplayer.EventPaused, p.queue[0])
+		}
+	}
+
+	p.sendEvent(mpvplayer.EventStatus, p.statusData())
+}
+
+func (p *Player) isAtEnd(status subsonic.JukeboxStatus) bool {
+	if len(p.queue) == 0 || status.CurrentIndex < 0 {
+		return true
+	}
+	if status.CurrentIndex < p.offset+len(p.queue)-1 {
+		return false
+	}
+	return status.Position == 0 || status.Position >= p.queue[0].Duration-1
+}
+
+func (p *Player) statusData() mpvplayer.StatusData {
+	duration := 0
+	if len(p.queue) > 0 {
+		duration = p.queue[0].Duration
+	}
+	return mpvplayer.StatusData{
+		Volume:   int64(math.Round(p.gain * 100)),
+		Position: int64(p.position),
+		Duration: int64(duration),
+	}
+}
+
+// sendEvent is called by the owner goroutine, the event is delivered on the
+// notification goroutine
+func (p *Player) sendEvent(typ mpvplayer.UiEventType, data interface{}) {
+	consumer := p.eventConsumer
+	p.owner.Notify(func() {
+		if consumer != nil {
+			consumer.SendEvent(mpvplayer.UiEvent{
+				Type: typ,
+				Data: data,
+			})
+		}
+
+		p.SendRemoteEvent(typ, data)
+	})
+}
+
+func (p *Player) sendInterrupt() {
+	if p.stopped || len(p.queue) == 0 {
+		return
+	}
+	track, position := p.queue[0], float64(p.position)
+	p.owner.Notify(func() {
+		p.SendInterrupt(track, position)
+	})
+}
+
+// playCurrent plays queue[0] starting at position (in seconds)
+func (p *Player) playCurrent(position int) error {
+	if _, err := p.connection.JukeboxSkip(p.offset, position); err != nil {
+		return err
+	}
+	if _, err := p.connection.JukeboxStart(); err != nil {
+		return err
+	}
+	p.stopped = false
+	p.paused = false
+	p.position = position
+	return nil
+}
+
+// the jukebox can only play what's in the library
+func isPlayable(item mpvplayer.QueueItem) bool {
+	return item.Kind != mpvplayer.KindRadio
+}
+
+func (p *Player) PlayNextTrack() error {
+	return p.do(func() error {
+		return p.advance(true)
+	})
+}
+
+// advance skips the current song, it's added to the history if played is set
+func (p *Player) advance(played bool) error {
+	p.sendInterrupt()
+
+	if played && len(p.queue) > 0 {
+		p.history = mpvplayer.AppendHistory(p.history, p.queue[0])
+	}
+	if len(p.queue) <= 1 {
+		// stop with empty queue
+		p.emptyQueue()
+		return nil
+	}
+
+	p.queue = p.queue[1:]
+	p.queueChanged()
+	p.offset++
+	if p.stopped {
+		return nil
+	}
+
+	if err := p.playCurrent(p.takeStartPosition()); err != nil {
+		return err
+	}
+	p.sendEvent(mpvplayer.EventPlaying, p.queue[0])
+	return nil
+}
+
+// takeStartPosition returns the start position of the current song and resets
+// it, it's only applied once
+func (p *Player) takeStartPosition() int {
+	position := p.queue[0].StartPosition
+	if position > 0 {
+		p.queue[0].StartPosition = 0
+		p.queueChanged()
+	}
+	return position
+}
+
+// PlayItem replaces the queue with item and plays it
+func (p *Player) PlayItem(item mpvplayer.QueueItem) error {
+	if !isPlayable(item) {
+		return fmt.Errorf("the jukebox can't play %s", item.Title)
+	}
+
+	return p.do(func() error {
+		p.sendInterrupt()
+
+		if _, err := p.connection.JukeboxSet([]string{item.Id}); err != nil {
+			return err
+		}
+		if !p.stopped && len(p.queue) > 0 {
+			p.history = mpvplayer.AppendHistory(p.history, p.queue[0])
+		}
+		p.shuffler.Queued(&item)
+		p.queue = mpvplayer.PlayerQueue{item}
+		p.queueChanged()
+		p.offset = 0
+		p.stopped = true
+		return p.pause()
+	})
+}
+
+func (p *Player) Stop() error {
+	return p.do(p.stop)
+}
+
+func (p *Player) stop() error {
+	p.sendInterrupt()
+
+	p.logger.Printf("jukebox: stopping (user)")
+	wasStopped := p.stopped
+	p.stopped = true
+	p.paused = false
+	if _, err := p.connection.JukeboxStop(); err != nil {
+		return err
+	}
+	if !wasStopped {
+		p.sendEvent(mpvplayer.EventStopped, nil)
+	}
+	return nil
+}
+
+func (p *Player) IsPaused() (bool, error) {
+	return p.state.Load().paused, nil
+}
+
+func (p *Player) IsPlaying() (bool, error) {
+	state := p.state.Load()
+	return !state.stopped && !state.paused, nil
+}
+
+func (p *Player) IsSeeking() (bool, error) {
+	return false, nil
+}
+
+// Pause toggles playing music, see mpvplayer.Player.Pause
+func (p *Player) Pause() error {
+	return p.do(p.pause)
+}
+
+func (p *Player) pause() error {
+	if len(p.queue) == 0 {
+		p.stopped = true
+		p.sendEvent(mpvplayer.EventStopped, nil)
+		return nil
+	}
+
+	if p.stopped {
+		// start the current song from the beginning
+		if err := p.playCurrent(p.takeStartPosition()); err != nil {
+			return err
+		}
+		p.sendEvent(mpvplayer.EventPlaying, p.queue[0])
+	} else if p.paused {
+		if _, err := p.connection.JukeboxStart(); err != nil {
+			return err
+		}
+		p.paused = false
+		p.sendEvent(mpvplayer.EventUnpaused, p.queue[0])
+	} else {
+		if _, err := p.connection.JukeboxStop(); err != nil {
+			return err
+		}
+		p.paused = true
+		p.sendEvent(mpvplayer.EventPaused, p.queue[0])
+	}
+	return nil
+}
+
+func (p *Player) Play() error {
+	return p.do(func() error {
+		if p.stopped || p.paused {
+			return p.pause()
+		}
+		return nil
+	})
+}
+
+func (p *Player) NextTrack() error {
+	return p.PlayNextTrack()
+}
+
+// PreviousTrack goes back to the last played song, see
+// mpvplayer.Player.PreviousTrack
+func (p *Player) PreviousTrack() error {
+	return p.do(p.previousTrack)
+}
+
+func (p *Player) previousTrack() error {
+	started := !p.stopped && len(p.queue) > 0
+	if started && (p.position > mpvplayer.PreviousRestartThreshold || len(p.history) == 0) {
+		return p.seekAbsolute(0)
+	}
+	if len(p.history) == 0 {
+		return nil
+	}
+
+	p.sendInterrupt()
+	var current mpvplayer.QueueItem
+	if len(p.queue) > 0 {
+		current = p.queue[0]
+	}
+	last := p.history[len(p.history)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.queue = append(mpvplayer.PlayerQueue{last}, p.queue...)
+	// the played songs may not be in the jukebox playlist anymore
+	p.replaceJukeboxPlaylist(current)
+
+	if p.paused {
+		p.position = 0
+		p.sendEvent(mpvplayer.EventPaused, p.queue[0])
+	}
+	return nil
+}
+
+func (p *Player) SetVolume(percentValue int) error {
+	return p.do(func() error {
+		return p.setVolume(percentValue)
+	})
+}
+
+func (p *Player) setVolume(percentValue int) error {
+	if percentValue > 100 {
+		percentValue = 100
+	} else if percentValue < 0 {
+		percentValue = 0
+	}
+
+	gain := float64(percentValue) / 100
+	if _, err := p.connection.JukeboxSetGain(gain); err != nil {
+		return err
+	}
+	p.gain = gain
+	p.sendEvent(mpvplayer.EventStatus, p.statusData())
+	return nil
+}
+
+func (p *Player) AdjustVolume(increment int) error {
+	return p.do(func() error {
+		return p.setVolume(int(math.Round(p.gain*100)) + increment)
+	})
+}
+
+func (p *Player) Seek(increment int) error {
+	return p.do(func() error {
+		return p.seekAbsolute(max(p.position+increment, 0))
+	})
+}
+
+func (p *Player) SeekAbsolute(position int) error {
+	return p.do(func() error {
+		return p.seekAbsolute(position)
+	})
+}
+
+func (p *Player) seekAbsolute(position int) error {
+	if p.stopped || len(p.queue) == 0 {
+		return nil
+	}
+
+	if _, err := p.connection.JukeboxSkip(p.offset, position); err != nil {
+		return err
+	}
+	p.position = position
+	if p.paused {
+		// skipping starts playback
+		if _, err := p.connection.JukeboxStop(); err != nil {
+			return err
+		}
+	}
+	p.sendEvent(mpvplayer.EventStatus, p.statusData())
+	return nil
+}
+
+func (p *Player) GetTimePos() float64 {
+	return float64(p.state.Load().position)
+}
+
+func (p *Player) ClearQueue() {
+	_ = p.do(func() error {
+		p.clearQueue()
+		return nil
+	})
+}
+
+// clearQueue removes the queued songs and the history
+func (p *Player) clearQueue() {
+	p.emptyQueue()
+	p.history = nil
+	p.shuffler.Cleared()
+}
+
+// emptyQueue stops and removes the queued songs, the history is kept
+func (p *Player) emptyQueue() {
+	if err := p.stop(); err != nil {
+		p.logger.PrintError("Stop", err)
+	}
+	if _, err := p.connection.JukeboxClear(); err != nil {
+		p.logger.PrintError("JukeboxClear", err)
+	}
+	p.queue = make(mpvplayer.PlayerQueue, 0)
+	p.queueChanged()
+	p.offset = 0
+}
+
+func (p *Player) DeleteQueueItem(index int) {
+	_ = p.do(func() error {
+		if index < 0 || index >= len(p.queue) {
+			p.logger.Printf("DeleteQueueItem bad index %d (len %d)", index, len(p.queue))
+		} else if len(p.queue) > 1 {
+			if index == 0 {
+				if err := p.advance(false); err != nil {
+					p.logger.PrintError("PlayNextTrack", err)
+				}
+			} else if _, err := p.connection.JukeboxRemove(p.offset + index); err != nil {
+				p.logger.PrintError("JukeboxRemove", err)
+			} else {
+				p.queue = append(p.queue[:index], p.queue[index+1:]...)
+				p.queueChanged()
+			}
+		} else {
+			p.emptyQueue()
+		}
+		return nil
+	})
+}
+
+func (p *Player) AddToQueue(item *mpvplayer.QueueItem) {
+	if !isPlayable(*item) {
+		p.logger.Printf("jukebox: can't play %s, not queued", item.Title)
+		return
+	}
+
+	added := *item
+	_ = p.do(func() error {
+		if _, err := p.connection.JukeboxAdd([]string{added.Id}); err != nil {
+			p.logger.PrintError("JukeboxAdd", err)
+			return nil
+		}
+		p.shuffler.Queued(&added)
+		p.queue = append(p.queue, added)
+		p.queueChanged()
+		return nil
+	})
+}
+
+// SetHistory drops the items the jukebox can't play
+func (p *Player) SetHistory(history mpvplayer.PlayerQueue) {
+	_ = p.do(func() error {
+		p.history = nil
+		for _, item := range history {
+			if isPlayable(item) {
+				p.history = mpvplayer.AppendHistory(p.history, item)
+			}
+		}
+		p.queueChanged()
+		return nil
+	})
+}
+
+// UpdateUris only matters when the queue is handed back to mpv, the jukebox
+// plays songs by id
+func (p *Player) UpdateUris(resolve func(item mpvplayer.QueueItem) string) {
+	_ = p.do(func() error {
+		for i := range p.queue {
+			p.queue[i].Uri = resolve(p.queue[i])
+		}
+		p.queueChanged()
+		return nil
+	})
+}
+
+func (p *Player) MoveSongUp(index int) {
+	_ = p.do(func() error {
+		if index < 1 {
+			p.logger.Printf("MoveSongUp(%d) can't move top item", index)
+			return nil
+		}
+		if index >= len(p.queue) {
+			p.logger.Printf("MoveSongUp(%d) not that many songs in queue", index)
+			return nil
+		}
+		current := p.queue[0]
+		p.queue[index-1], p.queue[index] = p.queue[index], p.queue[index-1]
+		p.replaceJukeboxPlaylist(current)
+		return nil
+	})
+}
+
+func (p *Player) MoveSongDown(index int) {
+	_ = p.do(func() error {
+		if index < 0 {
+			p.logger.Printf("MoveSongDown(%d) invalid index", index)
+			return nil
+		}
+		if index >= len(p.queue)-1 {
+			p.logger.Printf("MoveSongDown(%d) can't move last song down", index)
+			return nil
+		}
+		current := p.queue[0]
+		p.queue[index], p.queue[index+1] = p.queue[index+1], p.queue[index]
+		p.replaceJukeboxPlaylist(current)
+		return nil
+	})
+}
+
+// SetShuffle turns shuffle on with a random seed, or off, see
+// mpvplayer.Player.SetShuffle
+func (p *Player) SetShuffle(enabled bool) error {
+	return p.do(func() error {
+		if enabled == p.shuffler.Enabled() {
+			return nil
+		}
+		if enabled {
+			return p.shuffle(rand.Int63())
+		}
+		current := p.currentItem()
+		p.shuffler.Disable(p.queue)
+		p.replaceJukeboxPlaylist(current)
+		p.sendModeChanged()
+		return nil
+	})
+}
+
+func (p *Player) GetShuffle() bool {
+	return p.state.Load().shuffle
+}
+
+// SetShuffleSeed turns shuffle on, the queue is shuffled again if it was on
+func (p *Player) SetShuffleSeed(seed int64) error {
+	return p.do(func() error {
+		return p.shuffle(seed)
+	})
+}
+
+func (p *Player) shuffle(seed int64) error {
+	wasEnabled := p.shuffler.Enabled()
+	current := p.currentItem()
+	from := 1
+	if p.stopped {
+		from = 0
+	}
+	p.shuffler.Enable(p.history, p.queue, from, seed)
+	p.replaceJukeboxPlaylist(current)
+	if !wasEnabled {
+		p.sendModeChanged()
+	}
+	return nil
+}
+
+func (p *Player) GetShuffleState() (state mpvplayer.ShuffleState, ok bool) {
+	_ = p.owner.Do(func() error {
+		if p.shuffler.Enabled() {
+			state, ok = p.shuffler.State(p.queue), true
+		}
+		return nil
+	})
+	return
+}
+
+func (p *Player) RestoreShuffle(seed int64, permutation []int) error {
+	return p.do(func() error {
+		wasEnabled := p.shuffler.Enabled()
+		current := p.currentItem()
+		if err := p.shuffler.Restore(p.history, p.queue, seed, permutation); err != nil {
+			return err
+		}
+		p.replaceJukeboxPlaylist(current)
+		if !wasEnabled {
+			p.sendModeChanged()
+		}
+		return nil
+	})
+}
+
+func (p *Player) currentItem() mpvplayer.QueueItem {
+	if len(p.queue) == 0 {
+		return mpvplayer.QueueItem{}
+	}
+	return p.queue[0]
+}
+
+func (p *Player) sendModeChanged() {
+	p.sendEvent(mpvplayer.EventModeChanged, mpvplayer.ModeData{
+		Shuffle: p.shuffler.Enabled(),
+	})
+}
+
+// replaceJukeboxPlaylist uploads the queue after it was reordered, the jukebox
+// has no way to move songs. If current is still at the top it keeps playing.
+func (p *Player) replaceJukeboxPlaylist(current mpvplayer.QueueItem) {
+	p.queueChanged()
+
+	ids := make([]string, len(p.queue))
+	for i, item := range p.queue {
+		ids[i] = item.Id
+	}
+	if _, err := p.connection.JukeboxSet(ids); err != nil {
+		p.logger.PrintError("JukeboxSet", err)
+		return
+	}
+	p.offset = 0
+
+	if p.stopped || p.paused {
+		return
+	}
+	changed := p.queue[0].Id != current.Id
+	position := p.position
+	if changed {
+		position = 0
+	}
+	if err := p.playCurrent(position); err != nil {
+		p.logger.PrintError("playCurrent", err)
+		return
+	}
+	if changed {
+		p.sendEvent(mpvplayer.EventPlaying, p.queue[0])
+	}
+}
+
+// GetQueueItem returns the song at index, 0 is the current song
+func (p *Player) GetQueueItem(index int) (mpvplayer.QueueItem, error) {
+	items := p.state.Load().queue.Upcoming()
+	if index < 0 || index >= len(items) {
+		return mpvplayer.QueueItem{}, errors.New("invalid queue entry")
+	}
+	return items[index], nil
+}
+
+// GetQueueCopy returns the current song and the ones after it
+func (p *Player) GetQueueCopy() mpvplayer.PlayerQueue {
+	items := p.state.Load().queue.Upcoming()
+	cpy := make(mpvplayer.PlayerQueue, len(items))
+	copy(cpy, items)
+	return cpy
+}
+
+// GetQueueSnapshot returns the queue as of the last command or poll
+func (p *Player) GetQueueSnapshot() mpvplayer.QueueSnapshot {
+	return p.state.Load().queue
+}
+
+func (p *Player) GetPlayingTrack() (mpvplayer.QueueItem, error) {
+	state := p.state.Load()
+	if state.stopped || state.paused {
+		return mpvplayer.QueueItem{}, errors.New("not playing")
+	}
+	upcoming := state.queue.Upcoming()
+	if len(upcoming) == 0 {
+		return mpvplayer.QueueItem{}, errors.New("queue empty")
+	}
+	return upcoming[0], nil
+}
+
+// SetRepeatMode only accepts mpvplayer.RepeatOff, the jukebox doesn't tell
+// when a song ends so it can't be repeated in time
+func (p *Player) SetRepeatMode(mode mpvplayer.RepeatMode) error {
+	if mode != mpvplayer.RepeatOff {
+		return errors.New("the jukebox can't repeat songs")
+	}
+	return nil
+}
+
+func (p *Player) GetRepeatMode() mpvplayer.RepeatMode {
+	return mpvplayer.RepeatOff
+}
+
+// SetStopAfterCurrent isn't supported either, see SetRepeatMode
+func (p *Player) SetStopAfterCurrent(stop bool) error {
+	if stop {
+		return errors.New("the jukebox can't stop after the current song")
+	}
+	return nil
+}
+
+func (p *Player) GetStopAfterCurrent() bool {
+	return false
+}
+
+func (p *Player) GetLoopStatus() string {
+	return p.GetRepeatMode().LoopStatus()
+}
+
+func (p *Player) SetLoopStatus(status string) error {
+	mode, err := mpvplayer.ParseLoopStatus(status)
+	if err != nil {
+		return err
+	}
+	return p.SetRepeatMode(mode)
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"github.com/spezifisch/stmps/remote"
+)
+
+// Callbacks keeps the callbacks registered on a player, players embed it
+type Callbacks struct {
+	cbOnPaused     []func()
+	cbOnStopped    []func()
+	cbOnPlaying    []func()
+	cbOnSeek       []func()
+	cbOnSongChange []func(remote.TrackInterface)
+	cbOnInterrupt  []func(QueueItem, float64)
+}
+
+// remote.ControlledPlayer callbacks
+func (c *Callbacks) OnPaused(cb func()) {
+	c.cbOnPaused = append(c.cbOnPaused, cb)
+}
+
+func (c *Callbacks) OnStopped(cb func()) {
+	c.cbOnStopped = append(c.cbOnStopped, cb)
+}
+
+func (c *Callbacks) OnPlaying(cb func()) {
+	c.cbOnPlaying = append(c.cbOnPlaying, cb)
+}
+
+func (c *Callbacks) OnSeek(cb func()) {
+	c.cbOnSeek = append(c.cbOnSeek, cb)
+}
+
+func (c *Callbacks) OnSongChange(cb func(track remote.TrackInterface)) {
+	c.cbOnSongChange = append(c.cbOnSongChange, cb)
+}
+
+// OnInterrupt registers cb to be called with the playing track and its
+// position (in seconds) when the user stops or skips it before it ended
+func (c *Callbacks) OnInterrupt(cb func(track QueueItem, position float64)) {
+	c.cbOnInterrupt = append(c.cbOnInterrupt, cb)
+}
+
+// SendInterrupt calls the OnInterrupt callbacks
+func (c *Callbacks) SendInterrupt(track QueueItem, position float64) {
+	for _, cb := range c.cbOnInterrupt {
+		cb(track, position)
+	}
+}
+
+// SendRemoteEvent calls the remote.ControlledPlayer callbacks matching a UI
+// event
+func (c *Callbacks) SendRemoteEvent(typ UiEventType, data interface{}) {
+	switch typ {
+	case EventStopped:
+		defer func() {
+			for _, cb := range c.cbOnStopped {
+				cb()
+			}
+		}()
+
+	case EventUnpaused:
+		fallthrough
+	case EventPlaying:
+		defer func() {
+			if data != nil {
+				c.sendSongChange(data.(QueueItem))
+			}
+			for _, cb := range c.cbOnPlaying {
+				cb()
+			}
+		}()
+
+	case EventPaused:
+		defer func() {
+			if data != nil {
+				c.sendSongChange(data.(QueueItem))
+			}
+			for _, cb := range c.cbOnPaused {
+				cb()
+			}
+		}()
+
+	case EventStatus:
+		defer func() {
+			for _, cb := range c.cbOnSeek {
+				cb()
+			}
+		}()
+	}
+}
+
+func (c *Callbacks) sendSongChange(track QueueItem) {
+	for _, cb := range c.cbOnSongChange {
+		cb(&track)
+	}
+}
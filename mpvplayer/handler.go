@@ -7,7 +7,48 @@ import (
 	"github.com/supersonic-app/go-mpv"
 )
 
+// userdata of observed properties that need to be told apart from the others
+const (
+	observeIdIcyTitle uint64 = iota + 1
+	observeIdPlaylistPos
+	observeIdIdle
+)
+
+// EventLoop waits for mpv events and hands them to the owner goroutine, it
+// blocks until Quit is called
 func (p *Player) EventLoop() {
+	defer close(p.eventLoopDone)
+
+	err := p.owner.Do(func() error {
+		p.eventLoopRunning = true
+		p.observeProperties()
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	for {
+		evt := p.instance.WaitEvent(1)
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+		if evt == nil || evt.Event_Id == mpv.EVENT_NONE {
+			continue
+		}
+
+		if err := p.do(func() error {
+			p.handleEvent(evt)
+			return nil
+		}); err != nil {
+			return
+		}
+	}
+}
+
+func (p *Player) observeProperties() {
 	if err := p.instance.ObserveProperty(0, "playback-time", mpv.FORMAT_INT64); err != nil {
 		p.logger.PrintError("Observe1", err)
 	}
@@ -17,149 +58,133 @@ func (p *Player) EventLoop() {
 	if err := p.instance.ObserveProperty(0, "volume", mpv.FORMAT_INT64); err != nil {
 		p.logger.PrintError("Observe3", err)
 	}
+	if err := p.instance.ObserveProperty(observeIdIcyTitle, "metadata/by-key/icy-title", mpv.FORMAT_STRING); err != nil {
+		p.logger.PrintError("Observe4", err)
+	}
+	if err := p.instance.ObserveProperty(observeIdPlaylistPos, "playlist-pos", mpv.FORMAT_INT64); err != nil {
+		p.logger.PrintError("Observe5", err)
+	}
+	if err := p.instance.ObserveProperty(observeIdIdle, "idle-active", mpv.FORMAT_FLAG); err != nil {
+		p.logger.PrintError("Observe6", err)
+	}
+}
 
-	for evt := range p.mpvEvents {
-		if evt == nil {
-			// quit signal
-			break
-		} else if evt.Event_Id == mpv.EVENT_PROPERTY_CHANGE {
-			// one of our observed properties changed. which one is probably extractable from evt.Data.. somehow.
-
-			position, err := p.getPropertyInt64("playback-time")
-			if err != nil {
-				p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "playback-time", err.Error())
-			}
-			duration, err := p.getPropertyInt64("duration")
-			if err != nil {
-				p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "duration", err.Error())
-			}
-			volume, err := p.getPropertyInt64("volume")
-			if err != nil {
-				p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "volume", err.Error())
-			}
+// handleEvent is called by the owner goroutine
+func (p *Player) handleEvent(evt *mpv.Event) {
+	if evt.Event_Id == mpv.EVENT_PROPERTY_CHANGE && evt.Reply_Userdata == observeIdIcyTitle {
+		// radio stream title changed, it's unavailable (error) for anything but radio streams
+		title, err := p.getPropertyString("metadata/by-key/icy-title")
+		if err != nil {
+			title = ""
+		}
+		p.sendGuiDataEvent(EventStreamTitle, title)
+	} else if evt.Event_Id == mpv.EVENT_PROPERTY_CHANGE && evt.Reply_Userdata == observeIdPlaylistPos {
+		// mpv went on to the next preloaded item
+		p.syncPosition()
+	} else if evt.Event_Id == mpv.EVENT_PROPERTY_CHANGE && evt.Reply_Userdata == observeIdIdle {
+		p.handleIdle()
+	} else if evt.Event_Id == mpv.EVENT_PROPERTY_CHANGE {
+		// one of our observed properties changed. which one is probably extractable from evt.Data.. somehow.
+
+		position, err := p.getPropertyInt64("playback-time")
+		if err != nil {
+			p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "playback-time", err.Error())
+		}
+		duration, err := p.getPropertyInt64("duration")
+		if err != nil {
+			p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "duration", err.Error())
+		}
+		volume, err := p.getPropertyInt64("volume")
+		if err != nil {
+			p.logger.Printf("mpv.EventLoop (%s): GetProperty %s -- %s", evt.Event_Id.String(), "volume", err.Error())
+		}
 
-			statusData := StatusData{
-				Volume:   volume,
-				Position: position,
-				Duration: duration,
+		if len(p.queue) > 0 && p.queue[0].TimeOffset > 0 {
+			// mpv only knows the part of the song after the offset
+			position += int64(p.queue[0].TimeOffset)
+			if p.queue[0].Duration > 0 {
+				duration = int64(p.queue[0].Duration)
 			}
-			p.remoteState.timePos = float64(statusData.Position)
-			p.sendGuiDataEvent(EventStatus, statusData)
-		} else if evt.Event_Id == mpv.EVENT_END_FILE && !p.replaceInProgress {
-			// we don't want to update anything if we're in the process of replacing the current track
-
-			if p.stopped {
-				// this is feedback for a user-requested stop
-				// don't delete the first track so it gets started from the beginning when pressing play
-				p.logger.Print("mpv.EventLoop: mpv stopped")
-				p.stopped = true
-				p.sendGuiEvent(EventStopped)
-			} else {
-				// advance queue and play next track
-				if len(p.queue) > 0 {
-					p.queue = p.queue[1:]
-				}
-
-				if len(p.queue) > 0 {
-					if err := p.instance.Command([]string{"loadfile", p.queue[0].Uri}); err != nil {
-						p.logger.PrintError("mpv.EventLoop: load next", err)
-					}
-				} else {
-					// no remaining tracks
-					p.logger.Print("mpv.EventLoop: stopping (auto)")
-					p.stopped = true
-					p.sendGuiEvent(EventStopped)
-				}
-			}
-		} else if evt.Event_Id == mpv.EVENT_START_FILE {
-			p.replaceInProgress = false
-			p.stopped = false
+		}
 
-			currentSong := QueueItem{}
-			if len(p.queue) > 0 {
-				currentSong = p.queue[0]
-			}
+		statusData := StatusData{
+			Volume:   volume,
+			Position: position,
+			Duration: duration,
+		}
+		p.timePos = float64(statusData.Position)
+		p.sendGuiDataEvent(EventStatus, statusData)
+	} else if evt.Event_Id == mpv.EVENT_END_FILE && !p.replaceInProgress {
+		// we don't want to update anything if we're in the process of replacing the current track
+
+		// otherwise mpv goes on to the next entry of its playlist by itself, or
+		// becomes idle at its end (see handleIdle)
+		if p.stopped {
+			// this is feedback for a user-requested stop
+			// don't delete the first track so it gets started from the beginning when pressing play
+			p.logger.Print("mpv.EventLoop: mpv stopped")
+			p.stopped = true
+			p.sendGuiEvent(EventStopped)
+		}
+	} else if evt.Event_Id == mpv.EVENT_START_FILE {
+		p.replaceInProgress = false
+		p.fileLoaded = false
+		p.stopped = false
+		// the playlist-pos change may arrive after this event
+		p.syncPosition()
+
+		currentSong := QueueItem{}
+		if len(p.queue) > 0 {
+			currentSong = p.queue[0]
+		}
 
-			if paused, err := p.IsPaused(); err != nil {
-				p.logger.PrintError("mpv.EventLoop: IsPaused", err)
-			} else if !paused {
-				p.sendGuiDataEvent(EventPlaying, currentSong)
-			} else {
-				p.sendGuiDataEvent(EventPaused, currentSong)
-			}
-		} else if evt.Event_Id == mpv.EVENT_IDLE || evt.Event_Id == mpv.EVENT_NONE {
-			continue
+		if paused, err := p.isPaused(); err != nil {
+			p.logger.PrintError("mpv.EventLoop: IsPaused", err)
+		} else if !paused {
+			p.sendGuiDataEvent(EventPlaying, currentSong)
 		} else {
-			p.logger.Printf("mpv.EventLoop: unhandled event id %v", evt.Event_Id)
-			continue
+			p.sendGuiDataEvent(EventPaused, currentSong)
 		}
+	} else if evt.Event_Id == mpv.EVENT_FILE_LOADED {
+		p.fileLoaded = true
+		// seeking only works once the file is loaded
+		if len(p.queue) > 0 && p.queue[0].StartPosition > 0 {
+			position := p.queue[0].StartPosition
+			p.queue[0].StartPosition = 0
+			p.queueChanged()
+			if err := p.seekAbsolute(position); err != nil {
+				p.logger.PrintError("mpv.EventLoop: seek to start position", err)
+			}
+		}
+	} else if evt.Event_Id != mpv.EVENT_IDLE {
+		p.logger.Printf("mpv.EventLoop: unhandled event id %v", evt.Event_Id)
 	}
 }
 
+// sendGuiEvent and sendGuiDataEvent are called by the owner goroutine, the
+// event is delivered on the notification goroutine
 func (p *Player) sendGuiEvent(typ UiEventType) {
-	if p.eventConsumer != nil {
-		p.eventConsumer.SendEvent(UiEvent{
-			Type: typ,
-			Data: nil,
-		})
-	}
-
-	p.sendRemoteEvent(typ, nil)
+	p.sendGuiDataEvent(typ, nil)
 }
 
-func (p *Player) sendGuiDataEvent(typ UiEventType, data interface{}) {
-	if p.eventConsumer != nil {
-		p.eventConsumer.SendEvent(UiEvent{
-			Type: typ,
-			Data: data,
-		})
-	}
-
-	p.sendRemoteEvent(typ, data)
+func (p *Player) sendModeChanged() {
+	p.sendGuiDataEvent(EventModeChanged, ModeData{
+		RepeatMode:       p.repeatMode,
+		StopAfterCurrent: p.stopAfterCurrent,
+		Shuffle:          p.shuffler.Enabled(),
+	})
 }
 
-func (p *Player) sendRemoteEvent(typ UiEventType, data interface{}) {
-	switch typ {
-	case EventStopped:
-		defer func() {
-			for _, cb := range p.cbOnStopped {
-				cb()
-			}
-		}()
-
-	case EventUnpaused:
-		fallthrough
-	case EventPlaying:
-		defer func() {
-			if data != nil {
-				p.sendSongChange(data.(QueueItem))
-			}
-			for _, cb := range p.cbOnPlaying {
-				cb()
-			}
-		}()
-
-	case EventPaused:
-		defer func() {
-			if data != nil {
-				p.sendSongChange(data.(QueueItem))
-			}
-			for _, cb := range p.cbOnPaused {
-				cb()
-			}
-		}()
-
-	case EventStatus:
-		defer func() {
-			for _, cb := range p.cbOnSeek {
-				cb()
-			}
-		}()
-	}
-}
+func (p *Player) sendGuiDataEvent(typ UiEventType, data interface{}) {
+	consumer := p.eventConsumer
+	p.owner.Notify(func() {
+		if consumer != nil {
+			consumer.SendEvent(UiEvent{
+				Type: typ,
+				Data: data,
+			})
+		}
 
-func (p *Player) sendSongChange(track QueueItem) {
-	for _, cb := range p.cbOnSongChange {
-		cb(&track)
-	}
+		p.SendRemoteEvent(typ, data)
+	})
 }
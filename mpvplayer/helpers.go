@@ -19,6 +19,16 @@ func (p *Player) getPropertyInt64(name string) (int64, error) {
 	return value.(int64), err
 }
 
+func (p *Player) getPropertyString(name string) (string, error) {
+	value, err := p.instance.GetProperty(name, mpv.FORMAT_STRING)
+	if err != nil {
+		return "", err
+	} else if value == nil {
+		return "", errors.New("nil value")
+	}
+	return value.(string), err
+}
+
 func (p *Player) getPropertyBool(name string) (bool, error) {
 	value, err := p.instance.GetProperty(name, mpv.FORMAT_FLAG)
 	if err != nil {
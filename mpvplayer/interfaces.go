@@ -3,6 +3,10 @@
 
 package mpvplayer
 
+import (
+	"github.com/spezifisch/stmps/remote"
+)
+
 type UiEventType int
 
 const (
@@ -15,6 +19,10 @@ const (
 	EventPaused
 	// UI status update, data: StatusData
 	EventStatus
+	// stream title (ICY metadata) of a radio station changed, data: string
+	EventStreamTitle
+	// repeat mode, stop after current or shuffle changed, data: ModeData
+	EventModeChanged
 )
 
 type UiEvent struct {
@@ -26,3 +34,50 @@ type EventConsumer interface {
 	// create event that goes from mpv backend (this package) to a UI frontend
 	SendEvent(event UiEvent)
 }
+
+// QueuePlayer is a player that plays a queue of items, the UI controls the
+// local mpv player and the server's jukebox through it
+type QueuePlayer interface {
+	remote.ControlledPlayer
+
+	RegisterEventConsumer(consumer EventConsumer)
+	// EventLoop blocks until Quit is called
+	EventLoop()
+	Quit()
+
+	OnInterrupt(cb func(track QueueItem, position float64))
+
+	PlayNextTrack() error
+	PlayItem(item QueueItem) error
+	AdjustVolume(increment int) error
+	Seek(increment int) error
+
+	ClearQueue()
+	DeleteQueueItem(index int)
+	AddToQueue(item *QueueItem)
+	UpdateUris(resolve func(item QueueItem) string)
+	MoveSongUp(index int)
+	MoveSongDown(index int)
+	GetQueueItem(index int) (QueueItem, error)
+	GetQueueCopy() PlayerQueue
+	GetQueueSnapshot() QueueSnapshot
+	GetPlayingTrack() (QueueItem, error)
+	// SetHistory replaces the played items, the history of another output is
+	// handed over with it
+	SetHistory(history PlayerQueue)
+
+	SetRepeatMode(mode RepeatMode) error
+	GetRepeatMode() RepeatMode
+	// SetStopAfterCurrent stops playback once the current item ended, it's
+	// reset then
+	SetStopAfterCurrent(stop bool) error
+	GetStopAfterCurrent() bool
+	// SetShuffleSeed turns shuffle on, the order is generated from seed
+	SetShuffleSeed(seed int64) error
+	// GetShuffleState returns the order of the shuffled queue, it's false if
+	// shuffle is off
+	GetShuffleState() (ShuffleState, bool)
+	// RestoreShuffle turns shuffle on, the queue is shuffled like a saved
+	// ShuffleState of the same items says
+	RestoreShuffle(seed int64, permutation []int) error
+}
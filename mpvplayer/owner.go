@@ -0,0 +1,110 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrQuit is returned by player commands after Quit was called
+var ErrQuit = errors.New("player has quit")
+
+// Owner runs the commands of a player on a single goroutine, which is the
+// only one touching the player's state. Notifications (UI events and
+// callbacks) are delivered in order on a second goroutine so the owner never
+// waits for the UI, which may be waiting for a command at the same time.
+type Owner struct {
+	commands chan func()
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+
+	notifyMutex   sync.Mutex
+	notifications []func()
+	notifyWake    chan struct{}
+}
+
+func NewOwner() *Owner {
+	o := &Owner{
+		commands:   make(chan func()),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		notifyWake: make(chan struct{}, 1),
+	}
+	go o.run()
+	go o.deliver()
+	return o
+}
+
+func (o *Owner) run() {
+	defer close(o.done)
+	for {
+		select {
+		case <-o.quit:
+			return
+		case cmd := <-o.commands:
+			cmd()
+		}
+	}
+}
+
+// Do runs cmd on the owner goroutine and waits for it to finish. It returns
+// ErrQuit without running cmd if the owner has quit. cmd must not call Do.
+func (o *Owner) Do(cmd func() error) (err error) {
+	finished := make(chan struct{})
+	wrapped := func() {
+		err = cmd()
+		close(finished)
+	}
+
+	select {
+	case o.commands <- wrapped:
+	case <-o.done:
+		return ErrQuit
+	}
+	<-finished
+	return
+}
+
+// Notify queues fn to be called on the notification goroutine, it doesn't
+// block
+func (o *Owner) Notify(fn func()) {
+	o.notifyMutex.Lock()
+	o.notifications = append(o.notifications, fn)
+	o.notifyMutex.Unlock()
+
+	select {
+	case o.notifyWake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Owner) deliver() {
+	for {
+		select {
+		case <-o.quit:
+			return
+		case <-o.notifyWake:
+		}
+
+		o.notifyMutex.Lock()
+		pending := o.notifications
+		o.notifications = nil
+		o.notifyMutex.Unlock()
+
+		for _, fn := range pending {
+			fn()
+		}
+	}
+}
+
+// Quit stops both goroutines once the running command is finished, pending
+// notifications are dropped
+func (o *Owner) Quit() {
+	o.quitOnce.Do(func() {
+		close(o.quit)
+	})
+	<-o.done
+}
@@ -7,38 +7,76 @@ import (
 	"errors"
 	"math/rand"
 	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spezifisch/stmps/logger"
-	"github.com/spezifisch/stmps/remote"
 	"github.com/supersonic-app/go-mpv"
 )
 
-type PlayerQueue []QueueItem
+// mpvInstance is the part of libmpv the player uses, tests replace it
+type mpvInstance interface {
+	Command(command []string) error
+	SetProperty(name string, format mpv.Format, data interface{}) error
+	GetProperty(name string, format mpv.Format) (interface{}, error)
+	ObserveProperty(replyUserdata uint64, name string, format mpv.Format) error
+	WaitEvent(timeout float32) *mpv.Event
+	Wakeup()
+	TerminateDestroy()
+}
 
+// Player plays the queue with mpv. All queue and playback state is owned by
+// one goroutine, every method is a command run by it (see Owner). Getters
+// read the state published after each command and mpv event instead.
 type Player struct {
-	instance      *mpv.Mpv
-	mpvEvents     chan *mpv.Event
-	eventConsumer EventConsumer
-	queue         PlayerQueue
-	logger        logger.LoggerInterface
+	instance mpvInstance
+	logger   logger.LoggerInterface
+	owner    *Owner
+
+	// closed by Quit, stops the EventLoop
+	quit     chan struct{}
+	quitOnce sync.Once
+	// closed when the EventLoop returned
+	eventLoopDone chan struct{}
 
+	// owned by the owner goroutine
+	eventConsumer EventConsumer
+	// queue[0] is the current item, it's moved to history once it was played
+	queue        PlayerQueue
+	history      PlayerQueue
+	queueVersion uint64
+	// uris of mpv's playlist, the current item followed by preloaded ones.
+	// It's empty while nothing is loaded.
+	playlist []string
+	// a file was loaded, its EVENT_START_FILE hasn't arrived yet
 	replaceInProgress bool
-	stopped           bool
+	// the last started file was loaded, it didn't fail
+	fileLoaded       bool
+	stopped          bool
+	timePos          float64
+	eventLoopRunning bool
+	repeatMode       RepeatMode
+	stopAfterCurrent bool
+	shuffler         Shuffler
 
-	// player state
-	remoteState struct {
-		timePos float64
-	}
+	state atomic.Pointer[playerState]
 
-	// callbacks
-	cbOnPaused     []func()
-	cbOnStopped    []func()
-	cbOnPlaying    []func()
-	cbOnSeek       []func()
-	cbOnSongChange []func(remote.TrackInterface)
+	Callbacks
 }
 
-var _ remote.ControlledPlayer = (*Player)(nil)
+// playerState is published by the owner goroutine, it's never modified
+type playerState struct {
+	queue            QueueSnapshot
+	stopped          bool
+	paused           bool
+	idle             bool
+	timePos          float64
+	repeatMode       RepeatMode
+	stopAfterCurrent bool
+	shuffle          bool
+}
+
+var _ QueuePlayer = (*Player)(nil)
 
 func NewPlayer(logger logger.LoggerInterface) (player *Player, err error) {
 	m := mpv.Create()
@@ -59,127 +97,261 @@ func NewPlayer(logger logger.LoggerInterface) (player *Player, err error) {
 	if err = m.SetOptionString("audio-client-name", "stmp"); err != nil {
 		return
 	}
-
-	if err = m.Initialize(); err != nil {
+	// the next item is preloaded into mpv's playlist (see syncPlaylist), mpv
+	// goes on to it without a gap
+	if err = m.SetOptionString("gapless-audio", "yes"); err != nil {
+		return
+	}
+	if err = m.SetOptionString("prefetch-playlist", "yes"); err != nil {
 		return
 	}
 
-	player = &Player{
-		instance:          m,
-		mpvEvents:         make(chan *mpv.Event),
-		eventConsumer:     nil, // must be set by calling RegisterEventConsumer()
-		queue:             make([]QueueItem, 0),
-		logger:            logger,
-		replaceInProgress: false,
-		stopped:           true,
+	if err = m.Initialize(); err != nil {
+		return
 	}
 
-	go player.mpvEngineEventHandler(m)
+	player = newPlayer(m, logger)
 	return
 }
 
-func (p *Player) mpvEngineEventHandler(instance *mpv.Mpv) {
-	for {
-		evt := instance.WaitEvent(1)
-		p.mpvEvents <- evt
+func newPlayer(instance mpvInstance, logger logger.LoggerInterface) *Player {
+	p := &Player{
+		instance:      instance,
+		logger:        logger,
+		owner:         NewOwner(),
+		quit:          make(chan struct{}),
+		eventLoopDone: make(chan struct{}),
+		eventConsumer: nil, // must be set by calling RegisterEventConsumer()
+		queue:         make([]QueueItem, 0),
+		queueVersion:  NextQueueVersion(),
+		stopped:       true,
+	}
+	p.state.Store(&playerState{
+		queue:   NewQueueSnapshot(p.queueVersion, nil, nil),
+		stopped: true,
+		idle:    true,
+	})
+	return p
+}
+
+// do runs cmd on the owner goroutine, brings mpv's playlist up to date and
+// publishes the resulting state
+func (p *Player) do(cmd func() error) error {
+	return p.owner.Do(func() error {
+		err := cmd()
+		p.syncPlaylist()
+		p.publish()
+		return err
+	})
+}
+
+// publish makes the state visible to the getters, called by the owner
+// goroutine after every command and event. The queue is copied, so the owner
+// may modify it in place.
+func (p *Player) publish() {
+	prev := p.state.Load()
+	state := &playerState{
+		queue:            prev.queue,
+		stopped:          p.stopped,
+		timePos:          p.timePos,
+		repeatMode:       p.repeatMode,
+		stopAfterCurrent: p.stopAfterCurrent,
+		shuffle:          p.shuffler.Enabled(),
+	}
+
+	if state.queue.Version != p.queueVersion {
+		state.queue = NewQueueSnapshot(p.queueVersion, p.history, p.queue)
+	}
+
+	var err error
+	if state.paused, err = p.getPropertyBool("pause"); err != nil {
+		state.paused = prev.paused
 	}
+	if state.idle, err = p.getPropertyBool("idle-active"); err != nil {
+		state.idle = prev.idle
+	}
+
+	p.state.Store(state)
 }
 
+// queueChanged must be called by the owner goroutine after modifying the queue
+func (p *Player) queueChanged() {
+	p.queueVersion = NextQueueVersion()
+}
+
+// Quit stops the player, it can be called more than once
 func (p *Player) Quit() {
-	p.mpvEvents <- nil
-	p.instance.TerminateDestroy()
+	p.quitOnce.Do(func() {
+		close(p.quit)
+		p.instance.Wakeup()
+
+		// mpv must not be destroyed while the EventLoop is waiting for events
+		var running bool
+		_ = p.owner.Do(func() error {
+			running = p.eventLoopRunning
+			return nil
+		})
+		if running {
+			<-p.eventLoopDone
+		}
+
+		p.owner.Quit()
+		p.instance.TerminateDestroy()
+	})
 }
 
 func (p *Player) RegisterEventConsumer(consumer EventConsumer) {
-	p.eventConsumer = consumer
+	_ = p.do(func() error {
+		p.eventConsumer = consumer
+		return nil
+	})
 }
 
 func (p *Player) PlayNextTrack() error {
+	return p.do(func() error {
+		return p.advance(true)
+	})
+}
+
+// advance skips the current item, it's added to the history if played is set.
+// With RepeatAll a played item is moved to the end of the queue instead.
+func (p *Player) advance(played bool) error {
+	p.sendInterrupt()
+
 	if len(p.queue) >= 1 {
 		// advance queue if any tracks left
-		p.queue = p.queue[1:]
+		if played && p.repeatMode == RepeatAll {
+			p.queue = append(p.queue[1:], p.queue[0])
+		} else {
+			if played {
+				p.history = AppendHistory(p.history, p.queue[0])
+			}
+			p.queue = p.queue[1:]
+		}
+		p.queueChanged()
 
 		if len(p.queue) > 0 {
 			// replace currently playing song with next song
-			if loaded, err := p.IsSongLoaded(); err != nil {
+			if loaded, err := p.isSongLoaded(); err != nil {
 				p.logger.PrintError("PlayNextTrack", err)
 			} else if loaded {
-				p.replaceInProgress = true
-				if err := p.temporaryStop(); err != nil {
-					p.logger.PrintError("temporaryStop", err)
-				}
-				return p.instance.Command([]string{"loadfile", p.queue[0].Uri})
+				return p.loadCurrent()
+			} else {
+				// mpv is idle, its playlist is gone
+				p.playlist = nil
 			}
 		} else {
 			// stop with empty queue
-			if err := p.Stop(); err != nil {
+			if err := p.stop(); err != nil {
 				p.logger.PrintError("Stop", err)
 			}
 		}
 	} else {
 		// queue empty
-		if err := p.Stop(); err != nil {
+		if err := p.stop(); err != nil {
 			p.logger.PrintError("Stop", err)
 		}
 	}
 	return nil
 }
 
+// loadCurrent replaces the playing file with queue[0]
+func (p *Player) loadCurrent() error {
+	return p.loadFile(p.queue[0].Uri)
+}
+
 func (p *Player) PlayUri(id, uri, title, artist, album string, duration, track, disc int, coverArtId string) error {
-	p.queue = []QueueItem{{id, uri, title, artist, duration, album, track, coverArtId, disc}}
-	p.replaceInProgress = true
-	if ip, e := p.IsPaused(); ip && e == nil {
-		if err := p.Pause(); err != nil {
-			p.logger.PrintError("Pause", err)
+	return p.PlayItem(QueueItem{
+		Id:          id,
+		Uri:         uri,
+		Title:       title,
+		Artist:      artist,
+		Duration:    duration,
+		Album:       album,
+		TrackNumber: track,
+		CoverArtId:  coverArtId,
+		DiscNumber:  disc,
+	})
+}
+
+// PlayItem replaces the queue with item and plays it
+func (p *Player) PlayItem(item QueueItem) error {
+	return p.do(func() error {
+		p.sendInterrupt()
+
+		if !p.stopped && len(p.queue) > 0 {
+			p.history = AppendHistory(p.history, p.queue[0])
 		}
-	}
-	return p.instance.Command([]string{"loadfile", uri})
+		p.shuffler.Queued(&item)
+		p.queue = []QueueItem{item}
+		p.queueChanged()
+		if ip, e := p.isPaused(); ip && e == nil {
+			if err := p.pause(); err != nil {
+				p.logger.PrintError("Pause", err)
+			}
+		}
+		return p.loadFile(item.Uri)
+	})
 }
 
 func (p *Player) Stop() error {
+	return p.do(p.stop)
+}
+
+func (p *Player) stop() error {
+	p.sendInterrupt()
+
 	p.logger.Printf("stopping (user)")
 	p.stopped = true
+	// stopping clears mpv's playlist
+	p.playlist = nil
 	return p.instance.Command([]string{"stop"})
 }
 
-func (p *Player) temporaryStop() error {
-	return p.instance.Command([]string{"stop"})
+func (p *Player) IsSongLoaded() (bool, error) {
+	return !p.state.Load().idle, nil
 }
 
-func (p *Player) IsSongLoaded() (bool, error) {
+func (p *Player) isSongLoaded() (bool, error) {
 	idle, err := p.getPropertyBool("idle-active")
 	return !idle, err
 }
 
 func (p *Player) IsPaused() (bool, error) {
-	pause, err := p.getPropertyBool("pause")
-	return pause, err
+	return p.state.Load().paused, nil
 }
 
-func (p *Player) IsPlaying() (playing bool, err error) {
-	if idle, err := p.getPropertyBool("idle-active"); err != nil {
-	} else if paused, err := p.getPropertyBool("pause"); err != nil {
-	} else {
-		playing = !idle && !paused
-	}
-	return
+func (p *Player) isPaused() (bool, error) {
+	return p.getPropertyBool("pause")
+}
+
+func (p *Player) IsPlaying() (bool, error) {
+	state := p.state.Load()
+	return !state.idle && !state.paused, nil
 }
 
 func (p *Player) Test() {
-	res, err := p.getPropertyBool("idle-active")
-	p.logger.Printf("res %v err %v", res, err)
+	_ = p.do(func() error {
+		res, err := p.getPropertyBool("idle-active")
+		p.logger.Printf("res %v err %v", res, err)
+		return nil
+	})
 }
 
 // Pause toggles playing music
 // If a song is playing, it is paused. If a song is paused, playing resumes.
 // If stopped, the song starts playing.
 // The state after the toggle is returned, or an error.
-func (p *Player) Pause() (err error) {
-	loaded, err := p.IsSongLoaded()
+func (p *Player) Pause() error {
+	return p.do(p.pause)
+}
+
+func (p *Player) pause() (err error) {
+	loaded, err := p.isSongLoaded()
 	if err != nil {
 		return
 	}
-	paused, err := p.IsPaused()
+	paused, err := p.isPaused()
 	if err != nil {
 		return
 	}
@@ -206,7 +378,7 @@ func (p *Player) Pause() (err error) {
 	} else {
 		if len(p.queue) > 0 {
 			currentSong := p.queue[0]
-			err = p.instance.Command([]string{"loadfile", currentSong.Uri})
+			err = p.loadFile(currentSong.Uri)
 			if err != nil {
 				p.logger.PrintError("loadfile", err)
 				return
@@ -233,6 +405,12 @@ func (p *Player) Pause() (err error) {
 }
 
 func (p *Player) SetVolume(percentValue int) error {
+	return p.do(func() error {
+		return p.setVolume(percentValue)
+	})
+}
+
+func (p *Player) setVolume(percentValue int) error {
 	if percentValue > 100 {
 		percentValue = 100
 	} else if percentValue < 0 {
@@ -243,159 +421,360 @@ func (p *Player) SetVolume(percentValue int) error {
 }
 
 func (p *Player) AdjustVolume(increment int) error {
-	volume, err := p.getPropertyInt64("volume")
-	if err != nil {
-		return err
-	}
+	return p.do(func() error {
+		volume, err := p.getPropertyInt64("volume")
+		if err != nil {
+			return err
+		}
 
-	return p.SetVolume(int(volume) + increment)
+		return p.setVolume(int(volume) + increment)
+	})
 }
 
 func (p *Player) Seek(increment int) error {
-	return p.instance.Command([]string{"seek", strconv.Itoa(increment)})
+	return p.do(func() error {
+		return p.instance.Command([]string{"seek", strconv.Itoa(increment)})
+	})
 }
 
-// accessed from gui context
 func (p *Player) ClearQueue() {
-	if err := p.Stop(); err != nil {
+	_ = p.do(func() error {
+		p.clearQueue()
+		return nil
+	})
+}
+
+// clearQueue removes the queued items and the history
+func (p *Player) clearQueue() {
+	if err := p.stop(); err != nil {
 		p.logger.PrintError("Stop", err)
 	}
-	p.queue = make([]QueueItem, 0) // TODO mutex queue access
+	p.queue = make([]QueueItem, 0)
+	p.history = nil
+	p.shuffler.Cleared()
+	p.queueChanged()
 }
 
 func (p *Player) DeleteQueueItem(index int) {
-	// TODO mutex queue access
-	if index >= len(p.queue) {
-		p.logger.Printf("DeleteQueueItem bad index %d (len %d)", index, len(p.queue))
-	} else if len(p.queue) > 1 {
-		if index == 0 {
-			if err := p.PlayNextTrack(); err != nil {
-				p.logger.PrintError("PlayNextTrack", err)
+	_ = p.do(func() error {
+		if index < 0 || index >= len(p.queue) {
+			p.logger.Printf("DeleteQueueItem bad index %d (len %d)", index, len(p.queue))
+		} else if len(p.queue) > 1 {
+			if index == 0 {
+				if err := p.advance(false); err != nil {
+					p.logger.PrintError("PlayNextTrack", err)
+				}
+			} else {
+				p.queue = append(p.queue[:index], p.queue[index+1:]...)
+				p.queueChanged()
 			}
 		} else {
-			p.queue = append(p.queue[:index], p.queue[index+1:]...)
+			// the history is kept
+			if err := p.stop(); err != nil {
+				p.logger.PrintError("Stop", err)
+			}
+			p.queue = make([]QueueItem, 0)
+			p.queueChanged()
 		}
-	} else {
-		p.ClearQueue()
-	}
+		return nil
+	})
 }
 
 func (p *Player) AddToQueue(item *QueueItem) {
-	p.queue = append(p.queue, *item)
+	added := *item
+	_ = p.do(func() error {
+		p.shuffler.Queued(&added)
+		if p.shuffler.Enabled() {
+			p.queue = p.shuffler.Insert(p.queue, added, p.shuffleFrom())
+		} else {
+			p.queue = append(p.queue, added)
+		}
+		p.queueChanged()
+		return nil
+	})
+}
+
+func (p *Player) SetHistory(history PlayerQueue) {
+	_ = p.do(func() error {
+		p.history = AppendHistory(nil, history...)
+		p.queueChanged()
+		return nil
+	})
+}
+
+// UpdateUris replaces the uri of every queued item with resolve(item). The
+// playing item isn't reloaded, its new uri is used when it's started again.
+// resolve is called by the owner goroutine.
+func (p *Player) UpdateUris(resolve func(item QueueItem) string) {
+	_ = p.do(func() error {
+		for i := range p.queue {
+			p.queue[i].Uri = resolve(p.queue[i])
+		}
+		p.queueChanged()
+		return nil
+	})
 }
 
 func (p *Player) MoveSongUp(index int) {
-	if index < 1 {
-		p.logger.Printf("MoveSongUp(%d) can't move top item", index)
-		return
-	}
-	if index >= len(p.queue) {
-		p.logger.Printf("MoveSongUp(%d) not that many songs in queue", index)
-		return
-	}
-	p.queue[index-1], p.queue[index] = p.queue[index], p.queue[index-1]
+	_ = p.do(func() error {
+		if index < 1 {
+			p.logger.Printf("MoveSongUp(%d) can't move top item", index)
+			return nil
+		}
+		if index >= len(p.queue) {
+			p.logger.Printf("MoveSongUp(%d) not that many songs in queue", index)
+			return nil
+		}
+		p.swapQueueItems(index-1, index)
+		return nil
+	})
 }
 
 func (p *Player) MoveSongDown(index int) {
-	if index < 0 {
-		p.logger.Printf("MoveSongUp(%d) invalid index", index)
-		return
-	}
-	if index >= len(p.queue)-1 {
-		p.logger.Printf("MoveSongUp(%d) can't move last song down", index)
-		return
-	}
-	p.queue[index], p.queue[index+1] = p.queue[index+1], p.queue[index]
+	_ = p.do(func() error {
+		if index < 0 {
+			p.logger.Printf("MoveSongDown(%d) invalid index", index)
+			return nil
+		}
+		if index >= len(p.queue)-1 {
+			p.logger.Printf("MoveSongDown(%d) can't move last song down", index)
+			return nil
+		}
+		p.swapQueueItems(index, index+1)
+		return nil
+	})
 }
 
-func (p *Player) Shuffle() {
-	max := len(p.queue)
-	for range max / 2 {
-		ra := rand.Intn(max)
-		rb := rand.Intn(max)
-		p.queue[ra], p.queue[rb] = p.queue[rb], p.queue[ra]
-	}
+func (p *Player) swapQueueItems(a, b int) {
+	p.queue[a], p.queue[b] = p.queue[b], p.queue[a]
+	p.queueChanged()
 }
 
+// GetQueueItem returns the item at index, 0 is the current item
 func (p *Player) GetQueueItem(index int) (QueueItem, error) {
-	if index < 0 || index >= len(p.queue) {
+	items := p.state.Load().queue.Upcoming()
+	if index < 0 || index >= len(items) {
 		return QueueItem{}, errors.New("invalid queue entry")
 	}
-	return p.queue[index], nil
+	return items[index], nil
 }
 
+// GetQueueCopy returns the current item and the ones after it
 func (p *Player) GetQueueCopy() PlayerQueue {
-	cpy := make(PlayerQueue, len(p.queue))
-	copy(cpy, p.queue)
+	items := p.state.Load().queue.Upcoming()
+	cpy := make(PlayerQueue, len(items))
+	copy(cpy, items)
 	return cpy
 }
 
-// accessed from background context
+// GetQueueSnapshot returns the queue as of the last command or event
+func (p *Player) GetQueueSnapshot() QueueSnapshot {
+	return p.state.Load().queue
+}
+
 func (p *Player) GetPlayingTrack() (QueueItem, error) {
-	paused, err := p.IsPaused()
-	if err != nil {
-		return QueueItem{}, err
-	}
-	if paused {
+	state := p.state.Load()
+	if state.paused {
 		return QueueItem{}, errors.New("not playing")
 	}
 
-	if len(p.queue) == 0 { // TODO mutex queue access
+	upcoming := state.queue.Upcoming()
+	if len(upcoming) == 0 {
 		return QueueItem{}, errors.New("queue empty")
 	}
-	currentSong := p.queue[0]
-	return currentSong, nil
+	return upcoming[0], nil
 }
 
-// remote.ControlledPlayer callbacks
-func (p *Player) OnPaused(cb func()) {
-	p.cbOnPaused = append(p.cbOnPaused, cb)
+func (p *Player) sendInterrupt() {
+	if p.stopped || len(p.queue) == 0 {
+		return
+	}
+	track, position := p.queue[0], p.timePos
+	p.owner.Notify(func() {
+		p.SendInterrupt(track, position)
+	})
 }
 
-func (p *Player) OnStopped(cb func()) {
-	p.cbOnStopped = append(p.cbOnStopped, cb)
+func (p *Player) GetTimePos() float64 {
+	return p.state.Load().timePos
 }
 
-func (p *Player) OnPlaying(cb func()) {
-	p.cbOnPlaying = append(p.cbOnPlaying, cb)
+func (p *Player) IsSeeking() (bool, error) {
+	return false, nil
 }
 
-func (p *Player) OnSeek(cb func()) {
-	p.cbOnSeek = append(p.cbOnSeek, cb)
+func (p *Player) SeekAbsolute(position int) error {
+	return p.do(func() error {
+		return p.seekAbsolute(position)
+	})
 }
 
-func (p *Player) OnSongChange(cb func(track remote.TrackInterface)) {
-	p.cbOnSongChange = append(p.cbOnSongChange, cb)
+func (p *Player) seekAbsolute(position int) error {
+	if len(p.queue) > 0 && p.queue[0].TimeOffset > 0 {
+		// the stream starts at the offset, we can't seek before it
+		position = max(position-p.queue[0].TimeOffset, 0)
+	}
+	return p.instance.Command([]string{"seek", strconv.Itoa(position), "absolute"})
 }
 
-func (p *Player) GetTimePos() float64 {
-	return p.remoteState.timePos
+func (p *Player) Play() error {
+	return p.do(func() error {
+		loaded, err := p.isSongLoaded()
+		if err != nil {
+			return err
+		}
+		paused, err := p.isPaused()
+		if err != nil {
+			return err
+		}
+		if !loaded || paused {
+			return p.pause()
+		}
+		return nil
+	})
 }
 
-func (p *Player) IsSeeking() (bool, error) {
-	return false, nil
+func (p *Player) NextTrack() error {
+	return p.PlayNextTrack()
 }
 
-func (p *Player) SeekAbsolute(position int) error {
-	return p.instance.Command([]string{"seek", strconv.Itoa(position), "absolute"})
+// PreviousTrack goes back to the last played item. The current item is
+// restarted instead if it's been playing for more than
+// PreviousRestartThreshold seconds or if nothing was played before it.
+func (p *Player) PreviousTrack() error {
+	return p.do(p.previousTrack)
 }
 
-func (p *Player) Play() error {
-	if isPlaying, err := p.IsPlaying(); err != nil {
+func (p *Player) previousTrack() error {
+	started := !p.stopped && len(p.queue) > 0
+	if started && (p.timePos > PreviousRestartThreshold || len(p.history) == 0) {
+		return p.seekAbsolute(0)
+	}
+	if len(p.history) == 0 {
+		return nil
+	}
+
+	p.sendInterrupt()
+	last := p.history[len(p.history)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.queue = append(PlayerQueue{last}, p.queue...)
+	p.queueChanged()
+
+	if !started {
+		// stays stopped, the previous item is played when pressing play
+		return nil
+	}
+	return p.loadCurrent()
+}
+
+// SetRepeatMode sets what happens when the current item ends
+func (p *Player) SetRepeatMode(mode RepeatMode) error {
+	return p.do(func() error {
+		if mode != p.repeatMode {
+			p.repeatMode = mode
+			p.sendModeChanged()
+		}
+		return nil
+	})
+}
+
+func (p *Player) GetRepeatMode() RepeatMode {
+	return p.state.Load().repeatMode
+}
+
+// SetStopAfterCurrent stops playback once the current item ended, instead of
+// going on with the next one. It's reset then.
+func (p *Player) SetStopAfterCurrent(stop bool) error {
+	return p.do(func() error {
+		if stop != p.stopAfterCurrent {
+			p.stopAfterCurrent = stop
+			p.sendModeChanged()
+		}
+		return nil
+	})
+}
+
+func (p *Player) GetStopAfterCurrent() bool {
+	return p.state.Load().stopAfterCurrent
+}
+
+func (p *Player) GetLoopStatus() string {
+	return p.GetRepeatMode().LoopStatus()
+}
+
+func (p *Player) SetLoopStatus(status string) error {
+	mode, err := ParseLoopStatus(status)
+	if err != nil {
 		return err
-	} else if !isPlaying {
-		return p.Pause()
 	}
-	return nil
+	return p.SetRepeatMode(mode)
 }
 
-func (p *Player) NextTrack() error {
-	return p.PlayNextTrack()
+// SetShuffle turns shuffle on with a random seed, or off. Turned off, the
+// items after the current one are played in the order they were queued in
+// again, continuing after the current one.
+func (p *Player) SetShuffle(enabled bool) error {
+	return p.do(func() error {
+		if enabled == p.shuffler.Enabled() {
+			return nil
+		}
+		if enabled {
+			p.shuffler.Enable(p.history, p.queue, p.shuffleFrom(), rand.Int63())
+		} else {
+			p.shuffler.Disable(p.queue)
+		}
+		p.queueChanged()
+		p.sendModeChanged()
+		return nil
+	})
 }
 
-func (p *Player) PreviousTrack() (err error) {
-	if err = p.Stop(); err != nil {
-		return
+func (p *Player) GetShuffle() bool {
+	return p.state.Load().shuffle
+}
+
+// SetShuffleSeed turns shuffle on, the queue is shuffled again if it was on
+func (p *Player) SetShuffleSeed(seed int64) error {
+	return p.do(func() error {
+		wasEnabled := p.shuffler.Enabled()
+		p.shuffler.Enable(p.history, p.queue, p.shuffleFrom(), seed)
+		p.queueChanged()
+		if !wasEnabled {
+			p.sendModeChanged()
+		}
+		return nil
+	})
+}
+
+// shuffleFrom returns the index of the first item that may be shuffled, the
+// current one keeps its place unless it's stopped
+func (p *Player) shuffleFrom() int {
+	if p.stopped {
+		return 0
 	}
-	return p.Pause()
+	return 1
+}
+
+func (p *Player) GetShuffleState() (state ShuffleState, ok bool) {
+	_ = p.owner.Do(func() error {
+		if p.shuffler.Enabled() {
+			state, ok = p.shuffler.State(p.queue), true
+		}
+		return nil
+	})
+	return
+}
+
+func (p *Player) RestoreShuffle(seed int64, permutation []int) error {
+	return p.do(func() error {
+		wasEnabled := p.shuffler.Enabled()
+		if err := p.shuffler.Restore(p.history, p.queue, seed, permutation); err != nil {
+			return err
+		}
+		p.queueChanged()
+		if !wasEnabled {
+			p.sendModeChanged()
+		}
+		return nil
+	})
 }
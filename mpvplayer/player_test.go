@@ -0,0 +1,598 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supersonic-app/go-mpv"
+)
+
+// fakeMpv plays files instantly, finish ends the playing one and goes on to
+// the next entry of the playlist
+type fakeMpv struct {
+	mutex     sync.Mutex
+	idle      bool
+	paused    bool
+	playlist  []string
+	pos       int
+	observed  map[string]uint64
+	loaded    []string
+	destroyed bool
+
+	events chan *mpv.Event
+	wakeup chan struct{}
+}
+
+func newFakeMpv() *fakeMpv {
+	return &fakeMpv{
+		idle:     true,
+		pos:      -1,
+		observed: make(map[string]uint64),
+		events:   make(chan *mpv.Event, 10000),
+		wakeup:   make(chan struct{}, 1),
+	}
+}
+
+func (m *fakeMpv) Command(command []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch command[0] {
+	case "loadfile":
+		m.loaded = append(m.loaded, command[1]+" "+command[2])
+		if command[2] == "append" {
+			m.playlist = append(m.playlist, command[1])
+			return nil
+		}
+		if !m.idle {
+			m.push(mpv.EVENT_END_FILE)
+		}
+		m.playlist = []string{command[1]}
+		m.start(0)
+	case "stop":
+		if !m.idle {
+			m.push(mpv.EVENT_END_FILE)
+		}
+		m.playlist = nil
+		m.setIdle()
+	case "playlist-clear":
+		if m.pos >= 0 {
+			m.playlist = []string{m.playlist[m.pos]}
+			m.pos = 0
+		} else {
+			m.playlist = nil
+		}
+	case "playlist-remove":
+		index, err := strconv.Atoi(command[1])
+		if err != nil || index >= len(m.playlist) {
+			return errors.New("invalid playlist index")
+		}
+		m.playlist = append(m.playlist[:index], m.playlist[index+1:]...)
+		if index < m.pos {
+			m.pos--
+		}
+	case "cycle":
+		m.paused = !m.paused
+	}
+	return nil
+}
+
+// finish ends the playing file like reaching its end would
+func (m *fakeMpv) finish() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.idle {
+		return
+	}
+	m.push(mpv.EVENT_END_FILE)
+	if m.pos+1 < len(m.playlist) {
+		m.start(m.pos + 1)
+	} else {
+		m.setIdle()
+	}
+}
+
+func (m *fakeMpv) start(pos int) {
+	m.idle = false
+	m.pos = pos
+	m.push(mpv.EVENT_START_FILE)
+	m.push(mpv.EVENT_FILE_LOADED)
+	m.pushPropertyChange("playlist-pos")
+	m.pushPropertyChange("idle-active")
+}
+
+func (m *fakeMpv) setIdle() {
+	m.idle = true
+	m.pos = -1
+	m.pushPropertyChange("playlist-pos")
+	m.pushPropertyChange("idle-active")
+}
+
+func (m *fakeMpv) push(id mpv.EventId) {
+	m.events <- &mpv.Event{Event_Id: id}
+}
+
+func (m *fakeMpv) pushPropertyChange(name string) {
+	if userdata, ok := m.observed[name]; ok {
+		m.events <- &mpv.Event{Event_Id: mpv.EVENT_PROPERTY_CHANGE, Reply_Userdata: userdata}
+	}
+}
+
+// mpvPlaylist returns the playlist and the playing entry
+func (m *fakeMpv) mpvPlaylist() ([]string, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return slices.Clone(m.playlist), m.pos
+}
+
+func (m *fakeMpv) SetProperty(name string, format mpv.Format, data interface{}) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if name == "pause" {
+		m.paused = data.(bool)
+	}
+	return nil
+}
+
+func (m *fakeMpv) GetProperty(name string, format mpv.Format) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch name {
+	case "pause":
+		return m.paused, nil
+	case "idle-active":
+		return m.idle, nil
+	case "playlist-pos":
+		return int64(m.pos), nil
+	case "volume":
+		return int64(100), nil
+	}
+	return nil, errors.New("property unavailable")
+}
+
+func (m *fakeMpv) ObserveProperty(replyUserdata uint64, name string, format mpv.Format) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if replyUserdata != 0 {
+		m.observed[name] = replyUserdata
+	}
+	return nil
+}
+
+func (m *fakeMpv) WaitEvent(timeout float32) *mpv.Event {
+	select {
+	case evt := <-m.events:
+		return evt
+	case <-m.wakeup:
+		return nil
+	case <-time.After(time.Duration(timeout * float32(time.Second))):
+		return &mpv.Event{Event_Id: mpv.EVENT_NONE}
+	}
+}
+
+func (m *fakeMpv) Wakeup() {
+	select {
+	case m.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (m *fakeMpv) TerminateDestroy() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.destroyed = true
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Print(s string)                      {}
+func (nopLogger) Printf(s string, as ...interface{})  {}
+func (nopLogger) PrintError(source string, err error) {}
+
+type recordingConsumer struct {
+	mutex  sync.Mutex
+	events []UiEvent
+}
+
+func (c *recordingConsumer) SendEvent(event UiEvent) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.events = append(c.events, event)
+}
+
+// playing returns the ids of the items of EventPlaying events
+func (c *recordingConsumer) playing() (ids []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, event := range c.events {
+		if event.Type == EventPlaying {
+			ids = append(ids, event.Data.(QueueItem).Id)
+		}
+	}
+	return
+}
+
+func startTestPlayer(t *testing.T) (*Player, *fakeMpv, *recordingConsumer) {
+	instance := newFakeMpv()
+	consumer := &recordingConsumer{}
+	p := newPlayer(instance, nopLogger{})
+	p.RegisterEventConsumer(consumer)
+	go p.EventLoop()
+	t.Cleanup(p.Quit)
+	return p, instance, consumer
+}
+
+func queueIds(queue PlayerQueue) (ids []string) {
+	for _, item := range queue {
+		ids = append(ids, item.Id)
+	}
+	return
+}
+
+func TestPlayerAdvancesOnEndFile(t *testing.T) {
+	p, instance, consumer := startTestPlayer(t)
+
+	for _, id := range []string{"a", "b", "c"} {
+		p.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	before := p.GetQueueSnapshot()
+	assert.Equal(t, []string{"a", "b", "c"}, queueIds(before.Items))
+
+	assert.NoError(t, p.Pause())
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 1
+	}, time.Second, time.Millisecond)
+
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 2
+	}, time.Second, time.Millisecond)
+
+	after := p.GetQueueSnapshot()
+	assert.Equal(t, []string{"a", "b", "c"}, queueIds(after.Items))
+	assert.Equal(t, []string{"b", "c"}, queueIds(after.Upcoming()))
+	assert.Greater(t, after.Version, before.Version)
+	assert.Equal(t, []string{"a", "b"}, consumer.playing())
+
+	playing, err := p.GetPlayingTrack()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", playing.Id)
+
+	// mpv went on to the preloaded b by itself, c is preloaded now
+	assert.Eventually(t, func() bool {
+		playlist, _ := instance.mpvPlaylist()
+		return slices.Equal([]string{"uri-b", "uri-c"}, playlist)
+	}, time.Second, time.Millisecond)
+	instance.mutex.Lock()
+	assert.Equal(t, []string{"uri-a replace", "uri-b append", "uri-c append"}, instance.loaded)
+	instance.mutex.Unlock()
+
+	// reordering replaces the preloaded item
+	p.AddToQueue(&QueueItem{Id: "d", Uri: "uri-d"})
+	p.MoveSongUp(2)
+	playlist, pos := instance.mpvPlaylist()
+	assert.Equal(t, []string{"uri-b", "uri-d"}, playlist)
+	assert.Equal(t, 0, pos)
+
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 3
+	}, time.Second, time.Millisecond)
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 4
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a", "b", "d", "c"}, consumer.playing())
+
+	// earlier snapshots aren't changed by later commands
+	assert.Equal(t, []string{"a", "b", "c"}, queueIds(before.Items))
+}
+
+func TestPlayerPreviousTrack(t *testing.T) {
+	p, instance, consumer := startTestPlayer(t)
+
+	for _, id := range []string{"a", "b"} {
+		p.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	assert.NoError(t, p.Pause())
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 1
+	}, time.Second, time.Millisecond)
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 2
+	}, time.Second, time.Millisecond)
+
+	// b just started, previous goes back to a
+	assert.NoError(t, p.PreviousTrack())
+	snapshot := p.GetQueueSnapshot()
+	assert.Equal(t, []string{"a", "b"}, queueIds(snapshot.Items))
+	assert.Equal(t, 0, snapshot.Position)
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a", "b", "a"}, consumer.playing())
+
+	// nothing was played before a, it's restarted
+	assert.NoError(t, p.PreviousTrack())
+	assert.Equal(t, []string{"a", "b"}, queueIds(p.GetQueueSnapshot().Upcoming()))
+	instance.mutex.Lock()
+	assert.Equal(t, []string{"uri-a replace", "uri-b append", "uri-a replace", "uri-b append"}, instance.loaded)
+	instance.mutex.Unlock()
+}
+
+func TestPlayerRepeat(t *testing.T) {
+	p, instance, consumer := startTestPlayer(t)
+
+	for _, id := range []string{"a", "b"} {
+		p.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	assert.NoError(t, p.Pause())
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 1
+	}, time.Second, time.Millisecond)
+
+	// the current item is preloaded again
+	assert.NoError(t, p.SetRepeatMode(RepeatOne))
+	assert.Equal(t, RepeatOne, p.GetRepeatMode())
+	playlist, _ := instance.mpvPlaylist()
+	assert.Equal(t, []string{"uri-a", "uri-a"}, playlist)
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a", "a"}, consumer.playing())
+	assert.Equal(t, []string{"a", "b"}, queueIds(p.GetQueueSnapshot().Upcoming()))
+
+	// played items go to the end of the queue
+	assert.NoError(t, p.SetRepeatMode(RepeatAll))
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 3
+	}, time.Second, time.Millisecond)
+	snapshot := p.GetQueueSnapshot()
+	assert.Equal(t, []string{"b", "a"}, queueIds(snapshot.Upcoming()))
+	assert.Equal(t, 0, snapshot.Position)
+
+	// nothing is preloaded, the flag is reset once stopped
+	assert.NoError(t, p.SetStopAfterCurrent(true))
+	playlist, _ = instance.mpvPlaylist()
+	assert.Equal(t, []string{"uri-b"}, playlist)
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return !p.GetStopAfterCurrent()
+	}, time.Second, time.Millisecond)
+	playing, err := p.IsPlaying()
+	assert.NoError(t, err)
+	assert.False(t, playing)
+	assert.Equal(t, []string{"a", "b"}, queueIds(p.GetQueueSnapshot().Upcoming()))
+}
+
+func TestPlayerShuffle(t *testing.T) {
+	p, instance, consumer := startTestPlayer(t)
+
+	canonical := []string{"a", "b", "c", "d", "e"}
+	for _, id := range canonical {
+		p.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	assert.NoError(t, p.Pause())
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 1
+	}, time.Second, time.Millisecond)
+
+	// the playing item stays first
+	assert.NoError(t, p.SetShuffleSeed(42))
+	assert.True(t, p.GetShuffle())
+	shuffled := queueIds(p.GetQueueSnapshot().Upcoming())
+	assert.Equal(t, "a", shuffled[0])
+	assert.ElementsMatch(t, canonical, shuffled)
+
+	state, ok := p.GetShuffleState()
+	assert.True(t, ok)
+	assert.Equal(t, canonical, queueIds(state.Order))
+	for i, j := range state.Permutation {
+		assert.Equal(t, shuffled[i], state.Order[j].Id)
+	}
+
+	// a saved state shuffles the same items the same way
+	restored, _, _ := startTestPlayer(t)
+	for _, id := range canonical {
+		restored.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	assert.NoError(t, restored.RestoreShuffle(state.Seed, state.Permutation))
+	assert.Equal(t, shuffled, queueIds(restored.GetQueueSnapshot().Upcoming()))
+	assert.Error(t, restored.RestoreShuffle(state.Seed, []int{0, 0, 1, 2, 3}))
+
+	// turned off, the canonical order continues after the current item
+	instance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 2
+	}, time.Second, time.Millisecond)
+	current := shuffled[1]
+	assert.NoError(t, p.SetShuffle(false))
+	assert.False(t, p.GetShuffle())
+	expected := []string{current}
+	start := slices.Index(canonical, current)
+	for i := 1; i < len(canonical); i++ {
+		if id := canonical[(start+i)%len(canonical)]; id != "a" {
+			expected = append(expected, id)
+		}
+	}
+	assert.Equal(t, expected, queueIds(p.GetQueueSnapshot().Upcoming()))
+	_, ok = p.GetShuffleState()
+	assert.False(t, ok)
+}
+
+// TestShufflePermutation checks that every order is about equally likely
+func TestShufflePermutation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	counts := make(map[[3]int]int)
+	const runs = 60000
+	for range runs {
+		p := []int{0, 1, 2}
+		shufflePermutation(rng, p)
+		counts[[3]int(p)]++
+	}
+	assert.Len(t, counts, 6)
+	for order, count := range counts {
+		assert.InDelta(t, runs/6, count, runs/6*0.05, "order %v", order)
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	var history PlayerQueue
+	for i := range QueueHistoryLength + 5 {
+		history = AppendHistory(history, QueueItem{Id: strconv.Itoa(i)})
+	}
+	assert.Len(t, history, QueueHistoryLength)
+	assert.Equal(t, "5", history[0].Id)
+	assert.Equal(t, strconv.Itoa(QueueHistoryLength+4), history[len(history)-1].Id)
+}
+
+// TestPlayerConcurrentAccess is meant to be run with -race: the UI changes
+// the queue while mpv advances it and the background loop and MPRIS read it
+func TestPlayerConcurrentAccess(t *testing.T) {
+	p, instance, _ := startTestPlayer(t)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			p.AddToQueue(&QueueItem{Id: strconv.Itoa(i), Uri: "uri-" + strconv.Itoa(i)})
+			if i%20 == 0 {
+				_ = p.Pause()
+			}
+			p.MoveSongUp(1)
+			p.MoveSongDown(0)
+			if i%7 == 0 {
+				p.DeleteQueueItem(2)
+			}
+			if i%13 == 0 {
+				_ = p.SetShuffle(i%26 == 0)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			instance.finish()
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		var version uint64
+		for range 2000 {
+			snapshot := p.GetQueueSnapshot()
+			assert.GreaterOrEqual(t, snapshot.Version, version)
+			version = snapshot.Version
+
+			assert.LessOrEqual(t, snapshot.Position, len(snapshot.Items))
+			// every item is queued once, a torn queue would show duplicates
+			seen := make(map[string]bool, len(snapshot.Items))
+			for _, item := range snapshot.Items {
+				assert.False(t, seen[item.Id], "duplicate item %s in version %d", item.Id, snapshot.Version)
+				seen[item.Id] = true
+			}
+
+			_, _ = p.GetPlayingTrack()
+			_, _ = p.IsPlaying()
+			_ = p.GetTimePos()
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestPlayerQuit(t *testing.T) {
+	instance := newFakeMpv()
+	p := newPlayer(instance, nopLogger{})
+	go p.EventLoop()
+	p.AddToQueue(&QueueItem{Id: "a", Uri: "uri-a"})
+
+	p.Quit()
+
+	select {
+	case <-p.eventLoopDone:
+	case <-time.After(time.Second):
+		t.Error("EventLoop is still running after Quit")
+	}
+	instance.mutex.Lock()
+	assert.True(t, instance.destroyed)
+	instance.mutex.Unlock()
+
+	assert.ErrorIs(t, p.Stop(), ErrQuit)
+	assert.Equal(t, []string{"a"}, queueIds(p.GetQueueSnapshot().Items))
+}
+
+func TestSwitchHandsOver(t *testing.T) {
+	local, localInstance, _ := startTestPlayer(t)
+	other, _, _ := startTestPlayer(t)
+	s := NewSwitch(local)
+	s.AddJukebox(other)
+	consumer := &recordingConsumer{}
+	s.RegisterEventConsumer(consumer)
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		s.AddToQueue(&QueueItem{Id: id, Uri: "uri-" + id})
+	}
+	assert.NoError(t, s.Pause())
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 1
+	}, time.Second, time.Millisecond)
+	localInstance.finish()
+	assert.Eventually(t, func() bool {
+		return len(consumer.playing()) == 2
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, s.SetShuffleSeed(42))
+	assert.NoError(t, s.SetRepeatMode(RepeatAll))
+	assert.NoError(t, s.SetStopAfterCurrent(true))
+	before := s.GetQueueSnapshot()
+	state, _ := s.GetShuffleState()
+
+	assert.NoError(t, s.UseJukebox(true))
+	after := s.GetQueueSnapshot()
+	assert.Equal(t, queueIds(before.Items), queueIds(after.Items))
+	assert.Equal(t, before.Position, after.Position)
+	assert.Equal(t, RepeatAll, s.GetRepeatMode())
+	assert.True(t, s.GetStopAfterCurrent())
+	handedOver, ok := s.GetShuffleState()
+	assert.True(t, ok)
+	assert.Equal(t, queueIds(state.Order), queueIds(handedOver.Order))
+	assert.Equal(t, state.Permutation, handedOver.Permutation)
+	assert.Empty(t, local.GetQueueSnapshot().Items)
+
+	// turned off, the order the songs were queued in continues
+	assert.NoError(t, s.SetShuffle(false))
+	upcoming := queueIds(s.GetQueueSnapshot().Upcoming())
+	assert.Len(t, upcoming, 4)
+	assert.NotContains(t, upcoming, "a")
+}
+
+func TestHandOverPermutation(t *testing.T) {
+	state := ShuffleState{
+		Order:       PlayerQueue{{Id: "a"}, {Id: "radio"}, {Id: "b"}, {Id: "c"}},
+		Permutation: []int{2, 1, 3, 0},
+	}
+	// the radio station was dropped
+	accepted := PlayerQueue{{Id: "a"}, {Id: "b"}, {Id: "c"}}
+	assert.Equal(t, []int{1, 2, 0}, handOverPermutation(state, accepted))
+}
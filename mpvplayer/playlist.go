@@ -0,0 +1,156 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"slices"
+	"strconv"
+)
+
+// playlistPreload is the number of items after the current one that are kept
+// in mpv's playlist
+const playlistPreload = 1
+
+// loadFile replaces mpv's playlist with uri and plays it
+func (p *Player) loadFile(uri string) error {
+	if err := p.instance.Command([]string{"loadfile", uri, "replace"}); err != nil {
+		p.playlist = nil
+		return err
+	}
+	p.replaceInProgress = true
+	p.playlist = []string{uri}
+	// don't let a quick "previous" see the position of the replaced file
+	p.timePos = 0
+	return nil
+}
+
+// syncPlaylist makes mpv's playlist hold the items after the current one, so
+// mpv goes on to them by itself. It's called after every command and event,
+// reordering or removing queue items only changes what's preloaded.
+func (p *Player) syncPlaylist() {
+	if len(p.playlist) == 0 || len(p.queue) == 0 {
+		return
+	}
+
+	want := p.nextUris()
+	if slices.Equal(p.playlist[1:], want) {
+		return
+	}
+
+	if len(p.playlist) > 1 {
+		// removes everything but the current entry
+		if err := p.instance.Command([]string{"playlist-clear"}); err != nil {
+			p.logger.PrintError("playlist-clear", err)
+			return
+		}
+		p.playlist = p.playlist[:1]
+	}
+	for _, uri := range want {
+		if err := p.instance.Command([]string{"loadfile", uri, "append"}); err != nil {
+			p.logger.PrintError("loadfile append", err)
+			return
+		}
+		p.playlist = append(p.playlist, uri)
+	}
+}
+
+// nextUris returns the uris of the items that are played after the current
+// one, as far as they're preloaded
+func (p *Player) nextUris() (uris []string) {
+	if p.stopAfterCurrent {
+		return nil
+	}
+	for i := 1; i <= playlistPreload; i++ {
+		switch {
+		case p.repeatMode == RepeatOne:
+			uris = append(uris, p.queue[0].Uri)
+		case p.repeatMode == RepeatAll:
+			uris = append(uris, p.queue[i%len(p.queue)].Uri)
+		case i < len(p.queue):
+			uris = append(uris, p.queue[i].Uri)
+		}
+	}
+	return
+}
+
+// finished moves the current item out of the way after it was played, as the
+// repeat mode says
+func (p *Player) finished() {
+	switch p.repeatMode {
+	case RepeatOne:
+		// it stays the current item
+		return
+	case RepeatAll:
+		p.queue = append(p.queue[1:], p.queue[0])
+	default:
+		p.history = AppendHistory(p.history, p.queue[0])
+		p.queue = p.queue[1:]
+	}
+	p.queueChanged()
+}
+
+// syncPosition follows mpv to the next entries of its playlist. The entries
+// before the current one were played, they're removed from the playlist and
+// the queue.
+func (p *Player) syncPosition() {
+	if len(p.playlist) == 0 {
+		return
+	}
+	pos, err := p.getPropertyInt64("playlist-pos")
+	if err != nil || pos <= 0 {
+		return
+	}
+
+	for range pos {
+		if len(p.queue) == 0 || len(p.playlist) == 0 {
+			break
+		}
+		if err := p.instance.Command([]string{"playlist-remove", strconv.Itoa(0)}); err != nil {
+			p.logger.PrintError("playlist-remove", err)
+			return
+		}
+		p.finished()
+		p.playlist = p.playlist[1:]
+	}
+
+	if len(p.queue) > 0 && len(p.playlist) > 0 && p.playlist[0] != p.queue[0].Uri {
+		// the queue was reordered while mpv went on, play what the queue says
+		if err := p.loadCurrent(); err != nil {
+			p.logger.PrintError("loadCurrent", err)
+		}
+	}
+}
+
+// handleIdle stops at the end of mpv's playlist, or loads the rest of the
+// queue if mpv couldn't play the preloaded items
+func (p *Player) handleIdle() {
+	idle, err := p.getPropertyBool("idle-active")
+	if err != nil || !idle || p.stopped || p.replaceInProgress || len(p.playlist) == 0 {
+		return
+	}
+
+	// every entry of the playlist was played, or failed to play
+	for range min(len(p.playlist), len(p.queue)) {
+		p.finished()
+	}
+	p.playlist = nil
+
+	// when repeating, a file that can't be played would be retried forever
+	failed := !p.fileLoaded && p.repeatMode != RepeatOff
+	if len(p.queue) > 0 && !p.stopAfterCurrent && !failed {
+		if err := p.loadCurrent(); err != nil {
+			p.logger.PrintError("mpv.EventLoop: load next", err)
+		}
+		return
+	}
+
+	// no remaining tracks, or stopping was requested
+	p.logger.Print("mpv.EventLoop: stopping (auto)")
+	if p.stopAfterCurrent {
+		p.stopAfterCurrent = false
+		p.sendModeChanged()
+	}
+	p.stopped = true
+	p.sendGuiEvent(EventStopped)
+}
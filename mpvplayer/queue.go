@@ -0,0 +1,120 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/spezifisch/stmps/remote"
+)
+
+type PlayerQueue []QueueItem
+
+// QueueHistoryLength is the number of played items that are kept
+const QueueHistoryLength = 100
+
+// PreviousRestartThreshold is the position in seconds after which "previous"
+// restarts the current item instead of going back to the last played one
+const PreviousRestartThreshold = 3
+
+// queueVersions is shared by all players so a snapshot of one output is never
+// mistaken for an unchanged snapshot of another one
+var queueVersions atomic.Uint64
+
+// NextQueueVersion returns a new version for a queue that was changed
+func NextQueueVersion() uint64 {
+	return queueVersions.Add(1)
+}
+
+// QueueSnapshot is the queue at one point in time. Version changes with every
+// change of the queue, Items must not be modified.
+type QueueSnapshot struct {
+	Version uint64
+	// played items followed by the current item and the upcoming ones
+	Items PlayerQueue
+	// index of the current item in Items, it's len(Items) if nothing is left
+	// to play
+	Position int
+}
+
+// NewQueueSnapshot copies history and queue into a snapshot
+func NewQueueSnapshot(version uint64, history, queue PlayerQueue) QueueSnapshot {
+	items := make(PlayerQueue, 0, len(history)+len(queue))
+	items = append(items, history...)
+	items = append(items, queue...)
+	return QueueSnapshot{
+		Version:  version,
+		Items:    items,
+		Position: len(history),
+	}
+}
+
+// Upcoming returns the current item and the ones after it
+func (s QueueSnapshot) Upcoming() PlayerQueue {
+	return s.Items[s.Position:]
+}
+
+// AppendHistory adds played items to history, the oldest ones are dropped
+// once there are more than QueueHistoryLength
+func AppendHistory(history PlayerQueue, played ...QueueItem) PlayerQueue {
+	history = append(history, played...)
+	if len(history) > QueueHistoryLength {
+		history = history[len(history)-QueueHistoryLength:]
+	}
+	return history
+}
+
+// RepeatMode is what happens when the current item ends
+type RepeatMode int
+
+const (
+	// the item is moved to the history, playback stops at the end of the queue
+	RepeatOff RepeatMode = iota
+	// the item is played again
+	RepeatOne
+	// the item is moved to the end of the queue
+	RepeatAll
+)
+
+// Next returns the mode after m, for toggling through all of them
+func (m RepeatMode) Next() RepeatMode {
+	return (m + 1) % (RepeatAll + 1)
+}
+
+func (m RepeatMode) String() string {
+	switch m {
+	case RepeatOne:
+		return "one"
+	case RepeatAll:
+		return "all"
+	default:
+		return "off"
+	}
+}
+
+// LoopStatus returns the MPRIS LoopStatus matching m
+func (m RepeatMode) LoopStatus() string {
+	switch m {
+	case RepeatOne:
+		return remote.LoopStatusTrack
+	case RepeatAll:
+		return remote.LoopStatusPlaylist
+	default:
+		return remote.LoopStatusNone
+	}
+}
+
+// ParseLoopStatus returns the repeat mode matching an MPRIS LoopStatus
+func ParseLoopStatus(status string) (RepeatMode, error) {
+	switch status {
+	case remote.LoopStatusNone:
+		return RepeatOff, nil
+	case remote.LoopStatusTrack:
+		return RepeatOne, nil
+	case remote.LoopStatusPlaylist:
+		return RepeatAll, nil
+	}
+	return RepeatOff, fmt.Errorf("invalid loop status %q", status)
+}
@@ -7,6 +7,17 @@ import (
 	"github.com/spezifisch/stmps/remote"
 )
 
+// QueueItemKind tells songs apart from other things that can be queued
+type QueueItemKind int
+
+const (
+	KindSong QueueItemKind = iota
+	// internet radio stream, has no duration and isn't scrobbled
+	KindRadio
+	// podcast episode, resumed where it was left off
+	KindPodcast
+)
+
 type QueueItem struct {
 	Id          string
 	Uri         string
@@ -17,6 +28,15 @@ type QueueItem struct {
 	TrackNumber int
 	CoverArtId  string
 	DiscNumber  int
+	Kind        QueueItemKind
+	// position in seconds where playback starts, it's reset once applied
+	StartPosition int
+	// position in seconds the server starts streaming at (timeOffset), mpv's
+	// positions are relative to it
+	TimeOffset int
+
+	// set by Shuffler.Queued
+	key uint64
 }
 
 var _ remote.TrackInterface = (*QueueItem)(nil)
@@ -37,6 +57,12 @@ func (q QueueItem) GetDuration() int {
 	return q.Duration
 }
 
+// IsSong reports whether the item is a song from the library, only those can
+// be scrobbled, starred and saved to playlists
+func (q QueueItem) IsSong() bool {
+	return q.Kind == KindSong
+}
+
 func (q QueueItem) IsValid() bool {
 	return q.Id != ""
 }
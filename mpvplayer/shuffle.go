@@ -0,0 +1,205 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+)
+
+// queueKeys tells queued items apart, the same song can be queued twice
+var queueKeys atomic.Uint64
+
+// ShuffleState is what's needed to shuffle a queue the same way again: Order
+// is the upcoming items in the order they were queued, Permutation the order
+// they're played in as indexes into Order.
+type ShuffleState struct {
+	Seed        int64
+	Order       PlayerQueue
+	Permutation []int
+}
+
+// Shuffler is the shuffle mode of a queue. The queue is kept in play order,
+// the Shuffler remembers the order the items were queued in (canonical
+// order) to restore it when shuffle is turned off. The zero value is off.
+type Shuffler struct {
+	enabled bool
+	seed    int64
+	rng     *rand.Rand
+	// keys of the queued items in canonical order, items that were removed
+	// from the queue since are skipped when restoring it
+	canonical []uint64
+}
+
+// Queued must be called for every item before it's added to the queue
+func (s *Shuffler) Queued(item *QueueItem) {
+	item.key = queueKeys.Add(1)
+	if s.enabled {
+		s.canonical = append(s.canonical, item.key)
+	}
+}
+
+func (s *Shuffler) Enabled() bool {
+	return s.enabled
+}
+
+// Enable shuffles queue[from:] in place, the items before from keep their
+// place. history and queue are remembered as canonical order unless shuffle
+// was on already, then the queue is only shuffled again.
+func (s *Shuffler) Enable(history, queue PlayerQueue, from int, seed int64) {
+	s.start(history, queue, seed)
+
+	permutation := make([]int, len(queue))
+	for i := range permutation {
+		permutation[i] = i
+	}
+	if from < len(queue) {
+		shufflePermutation(s.rng, permutation[from:])
+	}
+	applyPermutation(queue, permutation)
+}
+
+// Restore shuffles queue in place like a saved ShuffleState says, queue must
+// hold the items of its Order
+func (s *Shuffler) Restore(history, queue PlayerQueue, seed int64, permutation []int) error {
+	if !isPermutation(permutation, len(queue)) {
+		return errors.New("the shuffled order doesn't match the queue")
+	}
+	// queue is in canonical order, whatever was remembered before doesn't matter
+	s.enabled = false
+	s.start(history, queue, seed)
+	applyPermutation(queue, permutation)
+	return nil
+}
+
+func (s *Shuffler) start(history, queue PlayerQueue, seed int64) {
+	s.seed = seed
+	s.rng = rand.New(rand.NewSource(seed))
+	if s.enabled {
+		return
+	}
+	s.enabled = true
+	s.canonical = make([]uint64, 0, len(history)+len(queue))
+	for _, item := range history {
+		s.canonical = append(s.canonical, item.key)
+	}
+	for _, item := range queue {
+		s.canonical = append(s.canonical, item.key)
+	}
+}
+
+// Insert returns queue with item at a random position after from
+func (s *Shuffler) Insert(queue PlayerQueue, item QueueItem, from int) PlayerQueue {
+	from = min(from, len(queue))
+	return slices.Insert(queue, from+s.rng.Intn(len(queue)-from+1), item)
+}
+
+// Cleared forgets the canonical order of the items that were removed, shuffle
+// stays on
+func (s *Shuffler) Cleared() {
+	s.canonical = nil
+}
+
+// Disable turns shuffle off and sorts the items after queue[0] in place. They
+// follow it in canonical order, the ones queued before it come last.
+func (s *Shuffler) Disable(queue PlayerQueue) {
+	if len(queue) > 1 {
+		rank := s.ranks()
+		n := len(s.canonical)
+		current, ok := rank[queue[0].key]
+		if !ok {
+			current = -1
+		}
+		distance := func(item QueueItem) int {
+			r, ok := rank[item.key]
+			if !ok {
+				// queued before shuffle was on, it was never ranked
+				return n
+			}
+			return (r - current - 1 + n) % n
+		}
+		slices.SortStableFunc(queue[1:], func(a, b QueueItem) int {
+			return cmp.Compare(distance(a), distance(b))
+		})
+	}
+	*s = Shuffler{}
+}
+
+// State returns the order of queue to be saved
+func (s *Shuffler) State(queue PlayerQueue) ShuffleState {
+	rank := s.ranks()
+	order := slices.Clone(queue)
+	slices.SortStableFunc(order, func(a, b QueueItem) int {
+		ra, okA := rank[a.key]
+		rb, okB := rank[b.key]
+		if !okA || !okB {
+			// unranked items keep their place at the end
+			return cmp.Compare(boolToInt(!okA), boolToInt(!okB))
+		}
+		return cmp.Compare(ra, rb)
+	})
+
+	index := make(map[uint64]int, len(order))
+	for i, item := range order {
+		index[item.key] = i
+	}
+	permutation := make([]int, len(queue))
+	for i, item := range queue {
+		permutation[i] = index[item.key]
+	}
+	return ShuffleState{
+		Seed:        s.seed,
+		Order:       order,
+		Permutation: permutation,
+	}
+}
+
+func (s *Shuffler) ranks() map[uint64]int {
+	rank := make(map[uint64]int, len(s.canonical))
+	for i, key := range s.canonical {
+		rank[key] = i
+	}
+	return rank
+}
+
+// shufflePermutation shuffles p in place with the Fisher–Yates algorithm,
+// every order is equally likely
+func shufflePermutation(rng *rand.Rand, p []int) {
+	for i := len(p) - 1; i > 0; i-- {
+		j := rng.Intn(i + 1)
+		p[i], p[j] = p[j], p[i]
+	}
+}
+
+// applyPermutation puts queue[permutation[i]] at index i
+func applyPermutation(queue PlayerQueue, permutation []int) {
+	orig := slices.Clone(queue)
+	for i, j := range permutation {
+		queue[i] = orig[j]
+	}
+}
+
+func isPermutation(permutation []int, n int) bool {
+	if len(permutation) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, i := range permutation {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
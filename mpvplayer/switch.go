@@ -0,0 +1,364 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package mpvplayer
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/spezifisch/stmps/remote"
+)
+
+// Switch plays either locally or on the server's jukebox. Callbacks and the
+// event consumer are registered once on the Switch, only events of the active
+// output reach them.
+type Switch struct {
+	local   QueuePlayer
+	jukebox QueuePlayer
+
+	useJukebox atomic.Bool
+
+	eventConsumer EventConsumer
+
+	Callbacks
+}
+
+var _ QueuePlayer = (*Switch)(nil)
+
+// switchConsumer passes events of output on if it's the active one
+type switchConsumer struct {
+	s      *Switch
+	output QueuePlayer
+}
+
+func (c switchConsumer) SendEvent(event UiEvent) {
+	if c.s.isActive(c.output) && c.s.eventConsumer != nil {
+		c.s.eventConsumer.SendEvent(event)
+	}
+}
+
+func NewSwitch(local QueuePlayer) *Switch {
+	s := &Switch{
+		local: local,
+	}
+	s.attach(local)
+	return s
+}
+
+// AddJukebox makes jukebox available as output, it must be called before the
+// event loop is started.
+func (s *Switch) AddJukebox(jukebox QueuePlayer) {
+	s.jukebox = jukebox
+	s.attach(jukebox)
+}
+
+func (s *Switch) attach(output QueuePlayer) {
+	output.RegisterEventConsumer(switchConsumer{s: s, output: output})
+
+	output.OnPaused(func() {
+		if s.isActive(output) {
+			for _, cb := range s.cbOnPaused {
+				cb()
+			}
+		}
+	})
+	output.OnStopped(func() {
+		if s.isActive(output) {
+			for _, cb := range s.cbOnStopped {
+				cb()
+			}
+		}
+	})
+	output.OnPlaying(func() {
+		if s.isActive(output) {
+			for _, cb := range s.cbOnPlaying {
+				cb()
+			}
+		}
+	})
+	output.OnSeek(func() {
+		if s.isActive(output) {
+			for _, cb := range s.cbOnSeek {
+				cb()
+			}
+		}
+	})
+	output.OnSongChange(func(track remote.TrackInterface) {
+		if s.isActive(output) {
+			for _, cb := range s.cbOnSongChange {
+				cb(track)
+			}
+		}
+	})
+	output.OnInterrupt(func(track QueueItem, position float64) {
+		if s.isActive(output) {
+			s.SendInterrupt(track, position)
+		}
+	})
+}
+
+func (s *Switch) active() QueuePlayer {
+	if s.useJukebox.Load() {
+		return s.jukebox
+	}
+	return s.local
+}
+
+func (s *Switch) isActive(output QueuePlayer) bool {
+	return s.active() == output
+}
+
+// IsJukebox reports whether the server's jukebox is playing instead of mpv
+func (s *Switch) IsJukebox() bool {
+	return s.useJukebox.Load()
+}
+
+// UseJukebox switches between local playback and the jukebox. The output that
+// is switched away from is stopped, its queue, history, shuffle order and
+// modes are handed over to the other one. Modes the other output doesn't
+// support are turned off, see the Get methods.
+func (s *Switch) UseJukebox(enabled bool) error {
+	if enabled == s.useJukebox.Load() {
+		return nil
+	}
+	if enabled && s.jukebox == nil {
+		return errors.New("jukebox isn't available")
+	}
+
+	from := s.active()
+	snapshot := from.GetQueueSnapshot()
+	shuffle, shuffled := from.GetShuffleState()
+	repeatMode, stopAfterCurrent := from.GetRepeatMode(), from.GetStopAfterCurrent()
+	if err := from.Stop(); err != nil {
+		return err
+	}
+
+	s.useJukebox.Store(enabled)
+	from.ClearQueue()
+
+	to := s.active()
+	to.ClearQueue()
+	to.SetHistory(snapshot.Items[:snapshot.Position])
+	queue := snapshot.Upcoming()
+	if shuffled {
+		// queued in canonical order and shuffled the same way again
+		queue = shuffle.Order
+	}
+	for _, item := range queue {
+		to.AddToQueue(&item)
+	}
+	if shuffled {
+		permutation := handOverPermutation(shuffle, to.GetQueueCopy())
+		_ = to.RestoreShuffle(shuffle.Seed, permutation)
+	}
+	// the jukebox can't repeat or stop after the current song, these are off
+	_ = to.SetRepeatMode(repeatMode)
+	_ = to.SetStopAfterCurrent(stopAfterCurrent)
+	return nil
+}
+
+// handOverPermutation returns the permutation of state for accepted, the items
+// of state.Order another output accepted in the same order
+func handOverPermutation(state ShuffleState, accepted PlayerQueue) []int {
+	// index of each item of state.Order in accepted, -1 if it was dropped
+	index := make([]int, len(state.Order))
+	next := 0
+	for i, item := range state.Order {
+		index[i] = -1
+		if next < len(accepted) && accepted[next].Id == item.Id {
+			index[i] = next
+			next++
+		}
+	}
+
+	permutation := make([]int, 0, len(accepted))
+	for _, i := range state.Permutation {
+		if index[i] >= 0 {
+			permutation = append(permutation, index[i])
+		}
+	}
+	return permutation
+}
+
+func (s *Switch) RegisterEventConsumer(consumer EventConsumer) {
+	s.eventConsumer = consumer
+}
+
+func (s *Switch) EventLoop() {
+	if s.jukebox != nil {
+		go s.jukebox.EventLoop()
+	}
+	s.local.EventLoop()
+}
+
+func (s *Switch) Quit() {
+	if s.jukebox != nil {
+		s.jukebox.Quit()
+	}
+	s.local.Quit()
+}
+
+// everything else is done by the active output
+
+func (s *Switch) IsSeeking() (bool, error) {
+	return s.active().IsSeeking()
+}
+
+func (s *Switch) IsPaused() (bool, error) {
+	return s.active().IsPaused()
+}
+
+func (s *Switch) IsPlaying() (bool, error) {
+	return s.active().IsPlaying()
+}
+
+func (s *Switch) GetTimePos() float64 {
+	return s.active().GetTimePos()
+}
+
+func (s *Switch) Play() error {
+	return s.active().Play()
+}
+
+func (s *Switch) Pause() error {
+	return s.active().Pause()
+}
+
+func (s *Switch) Stop() error {
+	return s.active().Stop()
+}
+
+func (s *Switch) SeekAbsolute(position int) error {
+	return s.active().SeekAbsolute(position)
+}
+
+func (s *Switch) NextTrack() error {
+	return s.active().NextTrack()
+}
+
+func (s *Switch) PreviousTrack() error {
+	return s.active().PreviousTrack()
+}
+
+func (s *Switch) SetVolume(percentValue int) error {
+	return s.active().SetVolume(percentValue)
+}
+
+func (s *Switch) PlayNextTrack() error {
+	return s.active().PlayNextTrack()
+}
+
+func (s *Switch) PlayItem(item QueueItem) error {
+	return s.active().PlayItem(item)
+}
+
+func (s *Switch) PlayUri(id, uri, title, artist, album string, duration, track, disc int, coverArtId string) error {
+	return s.PlayItem(QueueItem{
+		Id:          id,
+		Uri:         uri,
+		Title:       title,
+		Artist:      artist,
+		Duration:    duration,
+		Album:       album,
+		TrackNumber: track,
+		CoverArtId:  coverArtId,
+		DiscNumber:  disc,
+	})
+}
+
+func (s *Switch) AdjustVolume(increment int) error {
+	return s.active().AdjustVolume(increment)
+}
+
+func (s *Switch) Seek(increment int) error {
+	return s.active().Seek(increment)
+}
+
+func (s *Switch) ClearQueue() {
+	s.active().ClearQueue()
+}
+
+func (s *Switch) DeleteQueueItem(index int) {
+	s.active().DeleteQueueItem(index)
+}
+
+func (s *Switch) AddToQueue(item *QueueItem) {
+	s.active().AddToQueue(item)
+}
+
+func (s *Switch) UpdateUris(resolve func(item QueueItem) string) {
+	s.active().UpdateUris(resolve)
+}
+
+func (s *Switch) MoveSongUp(index int) {
+	s.active().MoveSongUp(index)
+}
+
+func (s *Switch) MoveSongDown(index int) {
+	s.active().MoveSongDown(index)
+}
+
+func (s *Switch) GetQueueItem(index int) (QueueItem, error) {
+	return s.active().GetQueueItem(index)
+}
+
+func (s *Switch) GetQueueCopy() PlayerQueue {
+	return s.active().GetQueueCopy()
+}
+
+func (s *Switch) GetQueueSnapshot() QueueSnapshot {
+	return s.active().GetQueueSnapshot()
+}
+
+func (s *Switch) GetPlayingTrack() (QueueItem, error) {
+	return s.active().GetPlayingTrack()
+}
+
+func (s *Switch) SetHistory(history PlayerQueue) {
+	s.active().SetHistory(history)
+}
+
+func (s *Switch) SetRepeatMode(mode RepeatMode) error {
+	return s.active().SetRepeatMode(mode)
+}
+
+func (s *Switch) GetRepeatMode() RepeatMode {
+	return s.active().GetRepeatMode()
+}
+
+func (s *Switch) SetStopAfterCurrent(stop bool) error {
+	return s.active().SetStopAfterCurrent(stop)
+}
+
+func (s *Switch) GetStopAfterCurrent() bool {
+	return s.active().GetStopAfterCurrent()
+}
+
+func (s *Switch) GetLoopStatus() string {
+	return s.active().GetLoopStatus()
+}
+
+func (s *Switch) SetLoopStatus(status string) error {
+	return s.active().SetLoopStatus(status)
+}
+
+func (s *Switch) GetShuffle() bool {
+	return s.active().GetShuffle()
+}
+
+func (s *Switch) SetShuffle(enabled bool) error {
+	return s.active().SetShuffle(enabled)
+}
+
+func (s *Switch) SetShuffleSeed(seed int64) error {
+	return s.active().SetShuffleSeed(seed)
+}
+
+func (s *Switch) GetShuffleState() (ShuffleState, bool) {
+	return s.active().GetShuffleState()
+}
+
+func (s *Switch) RestoreShuffle(seed int64, permutation []int) error {
+	return s.active().RestoreShuffle(seed, permutation)
+}
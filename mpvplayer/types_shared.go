@@ -9,3 +9,11 @@ type StatusData struct {
 	Position int64
 	Duration int64
 }
+
+// ModeData is sent when the repeat mode, stop after current or shuffle
+// changed
+type ModeData struct {
+	RepeatMode       RepeatMode
+	StopAfterCurrent bool
+	Shuffle          bool
+}
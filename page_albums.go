@@ -0,0 +1,200 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// number of albums fetched at a time
+const albumListPageSize = 50
+
+type albumListChoice struct {
+	name     string
+	listType subsonic.AlbumListType
+}
+
+var albumListChoices = []albumListChoice{
+	{"newest", subsonic.AlbumListNewest},
+	{"recently played", subsonic.AlbumListRecent},
+	{"most played", subsonic.AlbumListFrequent},
+	{"highest rated", subsonic.AlbumListHighest},
+	{"by name", subsonic.AlbumListAlphabeticalByName},
+	{"by artist", subsonic.AlbumListAlphabeticalByArtist},
+	{"starred", subsonic.AlbumListStarred},
+	{"random", subsonic.AlbumListRandom},
+}
+
+type AlbumsPage struct {
+	Root *tview.Flex
+
+	listTypeList *tview.List
+	albumList    *tview.List
+
+	listType subsonic.AlbumListType
+	albums   []subsonic.Album
+	// there might be more albums on the server
+	hasMore bool
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createAlbumsPage() *AlbumsPage {
+	albumsPage := AlbumsPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	// left: album list types
+	albumsPage.listTypeList = tview.NewList().
+		ShowSecondaryText(false)
+	albumsPage.listTypeList.Box.
+		SetTitle(" list ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	for _, choice := range albumListChoices {
+		albumsPage.listTypeList.AddItem(choice.name, "", 0, nil)
+	}
+
+	// right: albums
+	albumsPage.albumList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	albumsPage.albumList.Box.
+		SetTitle(" album ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	albumsPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(albumsPage.listTypeList, 24, 0, true).
+		AddItem(albumsPage.albumList, 0, 1, false)
+
+	albumsPage.listTypeList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRight, tcell.KeyEnter:
+			ui.app.SetFocus(albumsPage.albumList)
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'R':
+			albumsPage.loadAlbums(albumsPage.listType)
+			return nil
+		}
+
+		return event
+	})
+
+	albumsPage.albumList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(albumsPage.listTypeList)
+			return nil
+		case tcell.KeyEnter:
+			albumsPage.handleAddAlbumToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			albumsPage.handleAddAlbumToQueue()
+			return nil
+		case 'n':
+			albumsPage.loadMoreAlbums()
+			return nil
+		case 'R':
+			albumsPage.loadAlbums(albumsPage.listType)
+			return nil
+		}
+
+		return event
+	})
+
+	albumsPage.listTypeList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		if index >= 0 && index < len(albumListChoices) {
+			albumsPage.loadAlbums(albumListChoices[index].listType)
+		}
+	})
+
+	return &albumsPage
+}
+
+// Load is called when the page is shown, the first list is only fetched then
+// so we don't make requests for a page that is never visited.
+func (a *AlbumsPage) Load() {
+	if a.listType == "" {
+		a.loadAlbums(albumListChoices[a.listTypeList.GetCurrentItem()].listType)
+	}
+}
+
+// loadAlbums replaces the album list with the first page of the given list type
+func (a *AlbumsPage) loadAlbums(listType subsonic.AlbumListType) {
+	a.listType = listType
+	a.albums = nil
+	a.albumList.Clear()
+	a.loadMoreAlbums()
+}
+
+// loadMoreAlbums appends the next page of albums once it's fetched
+func (a *AlbumsPage) loadMoreAlbums() {
+	if a.listType == "" || (len(a.albums) > 0 && !a.hasMore) {
+		return
+	}
+
+	// fetched in the background so moving through the list types doesn't
+	// block the ui
+	listType, offset := a.listType, len(a.albums)
+	go func() {
+		response, err := a.ui.connection.GetAlbumList(listType, albumListPageSize, offset)
+		if err != nil {
+			a.logger.PrintError("GetAlbumList", err)
+			return
+		}
+
+		a.ui.app.QueueUpdateDraw(func() {
+			if a.listType != listType || len(a.albums) != offset {
+				// another list was selected or this page was added already
+				return
+			}
+			for _, album := range response.AlbumList2.Album {
+				a.albumList.AddItem(formatAlbumForList(album), "", 0, nil)
+				a.albums = append(a.albums, album)
+			}
+			a.hasMore = len(response.AlbumList2.Album) == albumListPageSize
+		})
+	}()
+}
+
+func (a *AlbumsPage) handleAddAlbumToQueue() {
+	currentIndex := a.albumList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(a.albums) {
+		return
+	}
+
+	// select next entry
+	if currentIndex+1 < a.albumList.GetItemCount() {
+		a.albumList.SetCurrentItem(currentIndex + 1)
+	}
+
+	a.ui.addAlbumToQueue(a.albums[currentIndex])
+}
+
+func formatAlbumForList(album subsonic.Album) (text string) {
+	text = tview.Escape(stringOr(album.Name, album.Title))
+	if album.Artist != "" {
+		text += " [gray]by [white]" + tview.Escape(album.Artist)
+	}
+	if album.Year > 0 {
+		text += fmt.Sprintf(" [gray](%d)[white]", album.Year)
+	}
+	return
+}
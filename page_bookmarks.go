@@ -0,0 +1,263 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+	"github.com/spf13/viper"
+)
+
+// songs at least this long get a bookmark when they're stopped or skipped,
+// unless client.bookmark-threshold is set
+const defaultBookmarkThreshold = 10 * time.Minute
+
+type BookmarksPage struct {
+	Root        *tview.Flex
+	ResumeModal *tview.Modal
+
+	bookmarkList *tview.List
+
+	bookmarks []subsonic.Bookmark
+	// bookmark positions (in milliseconds) by song id
+	positions map[string]int64
+	loaded    bool
+	threshold time.Duration
+
+	// song the resume modal was opened for and what had focus before
+	resumeId       string
+	resumePosition int64
+	resumeFocus    tview.Primitive
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createBookmarksPage() *BookmarksPage {
+	bookmarksPage := BookmarksPage{
+		positions: map[string]int64{},
+		threshold: defaultBookmarkThreshold,
+
+		ui:     ui,
+		logger: ui.logger,
+	}
+	if viper.IsSet("client.bookmark-threshold") {
+		bookmarksPage.threshold = viper.GetDuration("client.bookmark-threshold")
+	}
+
+	bookmarksPage.bookmarkList = tview.NewList().
+		ShowSecondaryText(false)
+	bookmarksPage.bookmarkList.Box.
+		SetTitle(" bookmarks ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	bookmarksPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(bookmarksPage.bookmarkList, 0, 1, true)
+
+	bookmarksPage.bookmarkList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			bookmarksPage.handlePlayBookmark()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			bookmarksPage.handleAddBookmarkToQueue()
+			return nil
+		case 'd':
+			bookmarksPage.handleDeleteBookmark()
+			return nil
+		case 'R':
+			bookmarksPage.loadBookmarks()
+			return nil
+		}
+
+		return event
+	})
+
+	// asks whether a bookmarked song should continue at the bookmark
+	bookmarksPage.ResumeModal = tview.NewModal().
+		AddButtons([]string{"Resume", "From start"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			ui.pages.HidePage(PageResumeBookmark)
+			ui.app.SetFocus(bookmarksPage.resumeFocus)
+			if buttonLabel == "Resume" {
+				bookmarksPage.resume()
+			}
+		})
+
+	return &bookmarksPage
+}
+
+// Load is called when the page is shown, bookmarks are normally already
+// fetched by the background event loop.
+func (b *BookmarksPage) Load() {
+	if !b.loaded {
+		b.loadBookmarks()
+	}
+}
+
+func (b *BookmarksPage) loadBookmarks() {
+	response, err := b.ui.connection.GetBookmarks()
+	if err != nil {
+		b.logger.PrintError("GetBookmarks", err)
+		return
+	}
+	b.setBookmarks(response.Bookmarks.Bookmark)
+}
+
+// setBookmarks must be called from the UI goroutine
+func (b *BookmarksPage) setBookmarks(bookmarks []subsonic.Bookmark) {
+	b.loaded = true
+	b.bookmarks = bookmarks
+
+	b.positions = make(map[string]int64, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		b.positions[bookmark.Entry.Id] = bookmark.Position
+	}
+
+	current := b.bookmarkList.GetCurrentItem()
+	b.bookmarkList.Clear()
+	for _, bookmark := range b.bookmarks {
+		b.bookmarkList.AddItem(formatBookmarkForList(bookmark), "", 0, nil)
+	}
+	if current < b.bookmarkList.GetItemCount() {
+		b.bookmarkList.SetCurrentItem(current)
+	}
+}
+
+func (b *BookmarksPage) getSelectedBookmark() (subsonic.Bookmark, bool) {
+	index := b.bookmarkList.GetCurrentItem()
+	if index < 0 || index >= len(b.bookmarks) {
+		return subsonic.Bookmark{}, false
+	}
+	return b.bookmarks[index], true
+}
+
+func (b *BookmarksPage) handlePlayBookmark() {
+	bookmark, ok := b.getSelectedBookmark()
+	if !ok {
+		return
+	}
+
+	item := b.ui.makeSongQueueItem(&bookmark.Entry)
+	b.ui.startQueueItemAt(&item, int(bookmark.Position/1000))
+	if err := b.ui.player.PlayItem(item); err != nil {
+		b.logger.PrintError("handlePlayBookmark", err)
+		return
+	}
+	b.ui.queuePage.UpdateQueue()
+}
+
+func (b *BookmarksPage) handleAddBookmarkToQueue() {
+	bookmark, ok := b.getSelectedBookmark()
+	if !ok {
+		return
+	}
+
+	item := b.ui.makeSongQueueItem(&bookmark.Entry)
+	b.ui.startQueueItemAt(&item, int(bookmark.Position/1000))
+	b.ui.player.AddToQueue(&item)
+	b.ui.queuePage.UpdateQueue()
+}
+
+func (b *BookmarksPage) handleDeleteBookmark() {
+	bookmark, ok := b.getSelectedBookmark()
+	if !ok {
+		return
+	}
+
+	if _, err := b.ui.connection.DeleteBookmark(bookmark.Entry.Id); err != nil {
+		b.ui.showErrorMessage("Deleting bookmark", err)
+		return
+	}
+
+	b.loadBookmarks()
+}
+
+// handleInterrupt is registered as player callback, it may be called from any
+// goroutine. The bookmark is created by the background event loop.
+func (b *BookmarksPage) handleInterrupt(track mpvplayer.QueueItem, position float64) {
+	if !track.IsSong() || b.threshold <= 0 {
+		return
+	}
+	if time.Duration(track.Duration)*time.Second < b.threshold {
+		return
+	}
+	if position < 1 || int(position) >= track.Duration {
+		return
+	}
+
+	bookmark := subsonic.Bookmark{
+		Position: int64(position * 1000),
+		Entry: subsonic.SubsonicEntity{
+			Id:       track.Id,
+			Title:    track.Title,
+			Artist:   track.Artist,
+			Duration: track.Duration,
+		},
+	}
+	select {
+	case b.ui.eventLoop.createBookmark <- bookmark:
+	default:
+		b.logger.Printf("not bookmarking %s: too many pending bookmarks", track.Id)
+	}
+}
+
+// OfferResume asks whether track should continue at its bookmark. Must be
+// called from the UI goroutine.
+func (b *BookmarksPage) OfferResume(track mpvplayer.QueueItem) {
+	if !track.IsSong() || track.StartPosition > 0 || track.TimeOffset > 0 {
+		// already starts at a chosen position
+		return
+	}
+	position, ok := b.positions[track.Id]
+	if !ok || position < 1000 {
+		return
+	}
+
+	b.resumeId = track.Id
+	b.resumePosition = position
+	if name, _ := b.ui.pages.GetFrontPage(); name != PageResumeBookmark {
+		b.resumeFocus = b.ui.app.GetFocus()
+	}
+
+	min, sec := iSecondsToMinAndSec(int(position / 1000))
+	b.ResumeModal.SetText(fmt.Sprintf("Resume %s at %d:%02d?", track.Title, min, sec))
+	b.ui.pages.ShowPage(PageResumeBookmark)
+	b.ui.app.SetFocus(b.ResumeModal)
+}
+
+func (b *BookmarksPage) resume() {
+	// the user may have taken a while to decide
+	current, err := b.ui.player.GetQueueItem(0)
+	if err != nil || current.Id != b.resumeId {
+		return
+	}
+
+	if err := b.ui.player.SeekAbsolute(int(b.resumePosition / 1000)); err != nil {
+		b.logger.PrintError("SeekAbsolute", err)
+	}
+}
+
+func formatBookmarkForList(bookmark subsonic.Bookmark) (text string) {
+	min, sec := iSecondsToMinAndSec(int(bookmark.Position / 1000))
+	text = tview.Escape(bookmark.Entry.GetSongTitle())
+	if bookmark.Entry.Artist != "" {
+		text += " [gray]- " + tview.Escape(bookmark.Entry.Artist) + "[white]"
+	}
+	text += fmt.Sprintf(" [green](at %d:%02d)[white]", min, sec)
+	if bookmark.Comment != "" {
+		text += " [gray]" + tview.Escape(bookmark.Comment) + "[white]"
+	}
+	return
+}
@@ -4,7 +4,9 @@
 package main
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -15,6 +17,7 @@ import (
 type BrowserPage struct {
 	Root               *tview.Flex
 	AddToPlaylistModal tview.Primitive
+	MusicFolderModal   tview.Primitive
 
 	artistFlex *tview.Flex
 
@@ -22,8 +25,17 @@ type BrowserPage struct {
 	entityList  *tview.List
 	searchField *tview.InputField
 
+	// artist/album info panel, toggled with 'i'
+	infoWidget *InfoWidget
+	showInfo   bool
+
 	currentDirectory *subsonic.SubsonicDirectory
 	artistIdList     []string
+	artistNameList   []string
+
+	// music folder (library) selection
+	musicFolderList *tview.List
+	musicFolders    []subsonic.MusicFolder
 
 	// external refs
 	ui     *Ui
@@ -37,6 +49,7 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 
 		currentDirectory: nil,
 		artistIdList:     []string{},
+		artistNameList:   []string{},
 	}
 
 	// artist list
@@ -51,6 +64,7 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 		for _, artist := range index.Artists {
 			browserPage.artistList.AddItem(tview.Escape(artist.Name), "", 0, nil)
 			browserPage.artistIdList = append(browserPage.artistIdList, artist.Id)
+			browserPage.artistNameList = append(browserPage.artistNameList, artist.Name)
 		}
 	}
 
@@ -78,9 +92,10 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 			ui.app.SetFocus(browserPage.artistList)
 		})
 
-	browserPage.artistFlex = tview.NewFlex().SetDirection(tview.FlexColumn).
-		AddItem(browserPage.artistList, 0, 1, true).
-		AddItem(browserPage.entityList, 0, 1, false)
+	browserPage.infoWidget = ui.createInfoWidget()
+
+	browserPage.artistFlex = tview.NewFlex().SetDirection(tview.FlexColumn)
+	browserPage.updateArtistFlex()
 
 	browserPage.Root = tview.NewFlex().SetDirection(tview.FlexRow)
 	browserPage.showSearchField(false) // add artist/search items
@@ -115,38 +130,46 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 			return nil
 		case 'S':
 			browserPage.handleAddRandomSongs("similar")
+		case 'i':
+			browserPage.toggleInfo()
+			return nil
+		case 'f':
+			browserPage.showMusicFolders()
+			return nil
 		case 'R':
-			goBackTo := browserPage.artistList.GetCurrentItem()
 			// REFRESH artists
-			indexResponse, err := ui.connection.GetIndexes()
-			if err != nil {
-				ui.logger.Printf("Error fetching indexes from server: %s\n", err)
+			if err := browserPage.reloadArtists(); err != nil {
 				return event
 			}
+			return nil
+		}
+		return event
+	})
 
-			browserPage.artistList.Clear()
-			browserPage.artistIdList = []string{}
-			ui.connection.ClearCache()
-
-			// Sort the indexes before adding to the list
-			for _, index := range indexResponse.Indexes.Index {
-				sort.Slice(index.Artists, func(i, j int) bool {
-					return index.Artists[i].Name < index.Artists[j].Name
-				})
-				for _, artist := range index.Artists {
-					browserPage.artistList.AddItem(tview.Escape(artist.Name), "", 0, nil)
-					browserPage.artistIdList = append(browserPage.artistIdList, artist.Id)
-				}
-			}
-
-			// Try to put the user to about where they were
-			if goBackTo < browserPage.artistList.GetItemCount() {
-				browserPage.artistList.SetCurrentItem(goBackTo)
-			}
+	// music folder selection modal
+	browserPage.musicFolderList = tview.NewList().
+		ShowSecondaryText(false)
+	browserPage.musicFolderList.SetBorder(true).
+		SetTitle(" music folder ")
+	browserPage.MusicFolderModal = makeModal(browserPage.musicFolderList, 40, 12)
+
+	browserPage.musicFolderList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			ui.pages.HidePage(PageSelectMusicFolder)
+			ui.app.SetFocus(browserPage.artistList)
+			return nil
+		case tcell.KeyEnter:
+			ui.pages.HidePage(PageSelectMusicFolder)
+			ui.app.SetFocus(browserPage.artistList)
+			browserPage.selectMusicFolder(browserPage.musicFolderList.GetCurrentItem())
 			return nil
 		}
 		return event
 	})
+	if ui.connection.MusicFolderId() != "" {
+		browserPage.updateMusicFolderTitle()
+	}
 
 	browserPage.artistList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
 		if index < len(browserPage.artistIdList) {
@@ -191,6 +214,14 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 			ui.app.SetFocus(browserPage.artistList)
 			return nil
 		}
+		if event.Key() == tcell.KeyRight && browserPage.showInfo {
+			ui.app.SetFocus(browserPage.infoWidget.text)
+			return nil
+		}
+		if event.Rune() == 'i' {
+			browserPage.toggleInfo()
+			return nil
+		}
 		if event.Rune() == 'a' {
 			browserPage.handleAddEntityToQueue()
 			return nil
@@ -199,6 +230,22 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 			browserPage.handleToggleEntityStar()
 			return nil
 		}
+		if event.Rune() == 'w' {
+			browserPage.handleShareEntity()
+			return nil
+		}
+		if event.Rune() == 'o' {
+			browserPage.handleDownloadEntity()
+			return nil
+		}
+		if event.Rune() == 'O' {
+			browserPage.handleRemoveEntityDownload()
+			return nil
+		}
+		if rating := ratingFromKey(event); rating >= 0 {
+			browserPage.handleSetEntityRating(rating)
+			return nil
+		}
 		if event.Rune() == 'A' {
 			// only makes sense to add to a playlist if there are playlists
 			if ui.playlistPage.GetCount() > 0 {
@@ -224,6 +271,38 @@ func (ui *Ui) createBrowserPage(indexes *[]subsonic.SubsonicIndex) *BrowserPage
 		return event
 	})
 
+	// info panel: biography can be scrolled, similar artists can be jumped to
+	browserPage.infoWidget.text.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(browserPage.entityList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(browserPage.infoWidget.similarList)
+			return nil
+		}
+		if event.Rune() == 'i' {
+			browserPage.toggleInfo()
+			return nil
+		}
+		return event
+	})
+	browserPage.infoWidget.similarList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(browserPage.infoWidget.text)
+			return nil
+		case tcell.KeyEnter:
+			browserPage.handleSimilarArtistSelected()
+			return nil
+		}
+		if event.Rune() == 'i' {
+			browserPage.toggleInfo()
+			return nil
+		}
+		return event
+	})
+
 	// open first artist by default so we don't get stuck when there's only one artist
 	if len(browserPage.artistIdList) > 0 {
 		browserPage.handleEntitySelected(browserPage.artistIdList[0])
@@ -241,6 +320,84 @@ func (b *BrowserPage) showSearchField(visible bool) {
 	}
 }
 
+func (b *BrowserPage) updateArtistFlex() {
+	b.artistFlex.Clear()
+	b.artistFlex.AddItem(b.artistList, 0, 1, true).
+		AddItem(b.entityList, 0, 1, false)
+
+	if b.showInfo {
+		b.artistFlex.AddItem(b.infoWidget.Root, 0, 1, false)
+	}
+}
+
+func (b *BrowserPage) toggleInfo() {
+	b.showInfo = !b.showInfo
+	b.updateArtistFlex()
+
+	if b.showInfo {
+		b.updateInfo()
+	} else {
+		focused := b.ui.app.GetFocus()
+		if focused == b.infoWidget.text || focused == b.infoWidget.similarList {
+			b.ui.app.SetFocus(b.entityList)
+		}
+		b.infoWidget.Clear()
+	}
+}
+
+// updateInfo shows info about the selected artist, and the album if one is open
+func (b *BrowserPage) updateInfo() {
+	if !b.showInfo {
+		return
+	}
+
+	artistIndex := b.artistList.GetCurrentItem()
+	if artistIndex < 0 || artistIndex >= len(b.artistIdList) {
+		return
+	}
+
+	albumId, albumName := "", ""
+	if b.currentDirectory != nil && b.currentDirectory.Parent != "" {
+		albumId = b.currentDirectory.Id
+		albumName = b.currentDirectory.Name
+	}
+
+	b.infoWidget.Load(b.artistIdList[artistIndex], b.artistNameList[artistIndex], albumId, albumName)
+}
+
+// handleSimilarArtistSelected jumps to the selected similar artist, if it's in
+// our index
+func (b *BrowserPage) handleSimilarArtistSelected() {
+	artist, ok := b.infoWidget.GetSelectedSimilarArtist()
+	if !ok {
+		return
+	}
+
+	index := -1
+	for i, id := range b.artistIdList {
+		if artist.Id != "" && id == artist.Id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		// ids of similar artists are ID3 ids, which may not match our folder ids
+		for i, name := range b.artistNameList {
+			if strings.EqualFold(name, artist.Name) {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		b.ui.showMessageBox(fmt.Sprintf("%s is not in your library", artist.Name))
+		return
+	}
+
+	b.artistList.SetCurrentItem(index)
+	b.ui.app.SetFocus(b.artistList)
+}
+
 func (b *BrowserPage) IsSearchFocused(focused tview.Primitive) bool {
 	return focused == b.searchField
 }
@@ -349,7 +506,8 @@ func (b *BrowserPage) handleEntitySelected(directoryId string) {
 
 	for _, entity := range b.currentDirectory.Entities {
 		var handler func()
-		title := entityListTextFormat(entity, b.ui.starIdList) // handles escaping
+		b.ui.rememberRating(entity.Id, entity.UserRating)
+		title := b.formatEntity(entity) // handles escaping
 
 		if entity.IsDirectory {
 			// it's an album/directory
@@ -361,6 +519,8 @@ func (b *BrowserPage) handleEntitySelected(directoryId string) {
 
 		b.entityList.AddItem(title, "", 0, handler)
 	}
+
+	b.updateInfo()
 }
 
 func (b *BrowserPage) makeEntityHandler(directoryId string) func() {
@@ -386,7 +546,7 @@ func (b *BrowserPage) handleToggleEntityStar() {
 	_, remove := b.ui.starIdList[entity.Id]
 
 	if _, err := b.ui.connection.ToggleStar(entity.Id, b.ui.starIdList); err != nil {
-		b.logger.PrintError("ToggleStar", err)
+		b.ui.showErrorMessage("Toggling star", err)
 		return
 	}
 
@@ -397,13 +557,157 @@ func (b *BrowserPage) handleToggleEntityStar() {
 	}
 
 	// update entity list entry
-	text := entityListTextFormat(entity, b.ui.starIdList)
+	text := b.formatEntity(entity)
 	b.entityList.SetItemText(originalIndex, text, "")
 
 	b.ui.queuePage.UpdateQueue()
 }
 
-func entityListTextFormat(entity subsonic.SubsonicEntity, starredItems map[string]struct{}) string {
+func (b *BrowserPage) handleSetEntityRating(rating int) {
+	currentIndex := b.entityList.GetCurrentItem()
+	originalIndex := currentIndex
+	if b.currentDirectory.Parent != "" {
+		// account for [..] entry that we show, see handleEntitySelected()
+		currentIndex--
+	}
+	if currentIndex < 0 {
+		return
+	}
+
+	entity := b.currentDirectory.Entities[currentIndex]
+	if !b.ui.setRating(entity.Id, rating) {
+		return
+	}
+
+	// update entity list entry
+	text := b.formatEntity(entity)
+	b.entityList.SetItemText(originalIndex, text, "")
+
+	b.ui.queuePage.UpdateQueue()
+}
+
+// reloadArtists fetches the artists again, e.g. after the music folder changed
+func (b *BrowserPage) reloadArtists() error {
+	goBackTo := b.artistList.GetCurrentItem()
+	indexResponse, err := b.ui.connection.GetIndexes()
+	if err != nil {
+		b.logger.Printf("Error fetching indexes from server: %s\n", err)
+		return err
+	}
+
+	b.artistList.Clear()
+	b.artistIdList = []string{}
+	b.artistNameList = []string{}
+	b.ui.connection.ClearCache()
+
+	// Sort the indexes before adding to the list
+	for _, index := range indexResponse.Indexes.Index {
+		sort.Slice(index.Artists, func(i, j int) bool {
+			return index.Artists[i].Name < index.Artists[j].Name
+		})
+		for _, artist := range index.Artists {
+			b.artistList.AddItem(tview.Escape(artist.Name), "", 0, nil)
+			b.artistIdList = append(b.artistIdList, artist.Id)
+			b.artistNameList = append(b.artistNameList, artist.Name)
+		}
+	}
+
+	// Try to put the user to about where they were
+	if goBackTo < b.artistList.GetItemCount() {
+		b.artistList.SetCurrentItem(goBackTo)
+	}
+	return nil
+}
+
+func (b *BrowserPage) showMusicFolders() {
+	response, err := b.ui.connection.GetMusicFolders()
+	if err != nil {
+		b.ui.showErrorMessage("Fetching music folders", err)
+		return
+	}
+	b.musicFolders = response.MusicFolders.MusicFolder
+
+	// the first entry removes the restriction
+	b.musicFolderList.Clear()
+	b.musicFolderList.AddItem("All folders", "", 0, nil)
+	for i, folder := range b.musicFolders {
+		b.musicFolderList.AddItem(tview.Escape(folder.Name), "", 0, nil)
+		if string(folder.Id) == b.ui.connection.MusicFolderId() {
+			b.musicFolderList.SetCurrentItem(i + 1)
+		}
+	}
+
+	b.ui.pages.ShowPage(PageSelectMusicFolder)
+	b.ui.app.SetFocus(b.musicFolderList)
+}
+
+// selectMusicFolder limits all requests to the music folder at index of the
+// folder list, index 0 is all folders
+func (b *BrowserPage) selectMusicFolder(index int) {
+	musicFolderId := ""
+	if index > 0 && index <= len(b.musicFolders) {
+		musicFolderId = string(b.musicFolders[index-1].Id)
+	}
+	if musicFolderId == b.ui.connection.MusicFolderId() {
+		return
+	}
+
+	b.ui.connection.SetMusicFolderId(musicFolderId)
+	b.updateMusicFolderTitle()
+	// album lists depend on the folder, reload them when they're shown next
+	b.ui.albumsPage.listType = ""
+	b.artistList.SetCurrentItem(0)
+	if err := b.reloadArtists(); err != nil {
+		b.ui.showErrorMessage("Loading artists", err)
+	}
+}
+
+// updateMusicFolderTitle shows the selected music folder in the artist list title
+func (b *BrowserPage) updateMusicFolderTitle() {
+	if b.ui.connection.MusicFolderId() == "" {
+		b.artistList.SetTitle(" artist ")
+		return
+	}
+
+	folder, ok := subsonic.MusicFolders{MusicFolder: b.musicFolders}.Find(b.ui.connection.MusicFolderId())
+	if !ok {
+		// not fetched yet
+		response, err := b.ui.connection.GetMusicFolders()
+		if err != nil {
+			b.logger.PrintError("GetMusicFolders", err)
+			return
+		}
+		b.musicFolders = response.MusicFolders.MusicFolder
+		folder, _ = response.MusicFolders.Find(b.ui.connection.MusicFolderId())
+	}
+	b.artistList.SetTitle(" artist: " + tview.Escape(folder.Name) + " ")
+}
+
+func (b *BrowserPage) handleShareEntity() {
+	currentIndex := b.entityList.GetCurrentItem()
+	if b.currentDirectory.Parent != "" {
+		// account for [..] entry that we show, see handleEntitySelected()
+		currentIndex--
+	}
+	if currentIndex < 0 || currentIndex >= len(b.currentDirectory.Entities) {
+		return
+	}
+
+	entity := b.currentDirectory.Entities[currentIndex]
+	b.ui.shareWidget.ShowCreate([]string{entity.Id}, entity.Title)
+}
+
+func (b *BrowserPage) formatEntity(entity subsonic.SubsonicEntity) string {
+	cached := false
+	if entity.IsDirectory {
+		cached = b.ui.downloads.IsAlbumCached(entity.Id)
+	} else {
+		cached = b.ui.downloads.IsCached(entity.Id)
+	}
+	return entityListTextFormat(entity, b.ui.starIdList, b.ui.ratings, cached)
+}
+
+func entityListTextFormat(entity subsonic.SubsonicEntity, starredItems map[string]struct{}, ratings map[string]int, cached bool) string {
 	title := entity.Title
 	if entity.IsDirectory {
 		title = "[" + title + "]"
@@ -414,7 +718,97 @@ func entityListTextFormat(entity subsonic.SubsonicEntity, starredItems map[strin
 	if hasStar {
 		star = " [red]♥"
 	}
-	return tview.Escape(title) + star
+	rating := ""
+	if stars := formatRating(ratings[entity.Id]); stars != "" {
+		rating = " [yellow]" + stars
+	}
+	download := ""
+	if cached {
+		download = " [green]↓"
+	}
+	return tview.Escape(title) + star + rating + download
+}
+
+// UpdateDownloads marks songs and albums that finished downloading
+func (b *BrowserPage) UpdateDownloads() {
+	if b.currentDirectory == nil {
+		return
+	}
+
+	offset := 0
+	if b.currentDirectory.Parent != "" {
+		// account for [..] entry that we show, see handleEntitySelected()
+		offset = 1
+	}
+	for i, entity := range b.currentDirectory.Entities {
+		if i+offset < b.entityList.GetItemCount() {
+			b.entityList.SetItemText(i+offset, b.formatEntity(entity), "")
+		}
+	}
+}
+
+func (b *BrowserPage) getSelectedEntity() (subsonic.SubsonicEntity, bool) {
+	currentIndex := b.entityList.GetCurrentItem()
+	if b.currentDirectory.Parent != "" {
+		// account for [..] entry that we show, see handleEntitySelected()
+		currentIndex--
+	}
+	if currentIndex < 0 || currentIndex >= len(b.currentDirectory.Entities) {
+		return subsonic.SubsonicEntity{}, false
+	}
+	return b.currentDirectory.Entities[currentIndex], true
+}
+
+// handleDownloadEntity keeps the selected song or album in the download cache
+func (b *BrowserPage) handleDownloadEntity() {
+	entity, ok := b.getSelectedEntity()
+	if !ok {
+		return
+	}
+
+	if entity.IsDirectory {
+		songs := b.collectDirectorySongs(&entity)
+		b.ui.downloads.EnqueueAlbum(entity.Id, songs)
+		b.logger.Printf("downloading %d songs of %s", len(songs), entity.Title)
+	} else {
+		b.ui.downloads.Enqueue(entity.Id, entity.GetSongTitle())
+	}
+}
+
+// handleRemoveEntityDownload deletes the selected song or album from the
+// download cache
+func (b *BrowserPage) handleRemoveEntityDownload() {
+	entity, ok := b.getSelectedEntity()
+	if !ok {
+		return
+	}
+
+	if entity.IsDirectory {
+		b.ui.downloads.RemoveAlbum(entity.Id)
+	} else {
+		b.ui.downloads.Remove(entity.Id)
+	}
+	b.UpdateDownloads()
+}
+
+// collectDirectorySongs returns the songs of a directory and its
+// subdirectories, like addDirectoryToQueue
+func (b *BrowserPage) collectDirectorySongs(entity *subsonic.SubsonicEntity) (songs []subsonic.SubsonicEntity) {
+	response, err := b.ui.connection.GetMusicDirectory(entity.Id)
+	if err != nil {
+		b.logger.Printf("collectDirectorySongs: GetMusicDirectory %s -- %s", entity.Id, err.Error())
+		return
+	}
+
+	sort.Sort(response.Directory.Entities)
+	for _, e := range response.Directory.Entities {
+		if e.IsDirectory {
+			songs = append(songs, b.collectDirectorySongs(&e)...)
+		} else {
+			songs = append(songs, e)
+		}
+	}
+	return
 }
 
 func (b *BrowserPage) addDirectoryToQueue(entity *subsonic.SubsonicEntity) {
@@ -495,7 +889,7 @@ func (b *BrowserPage) handleAddSongToPlaylist(playlist *subsonic.SubsonicPlaylis
 
 	if !entity.IsDirectory {
 		if err := b.ui.connection.AddSongToPlaylist(string(playlist.Id), entity.Id); err != nil {
-			b.logger.PrintError("AddSongToPlaylist", err)
+			b.ui.showErrorMessage("Adding song to playlist", err)
 			return
 		}
 	}
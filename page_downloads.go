@@ -0,0 +1,130 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/downloads"
+	"github.com/spezifisch/stmps/logger"
+)
+
+type DownloadsPage struct {
+	Root *tview.Flex
+
+	jobList *tview.List
+
+	jobs []downloads.Job
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createDownloadsPage() *DownloadsPage {
+	downloadsPage := DownloadsPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	downloadsPage.jobList = tview.NewList().ShowSecondaryText(false)
+	downloadsPage.jobList.Box.
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	downloadsPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(downloadsPage.jobList, 0, 1, true)
+
+	downloadsPage.jobList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'd':
+			if job, ok := downloadsPage.getSelectedJob(); ok {
+				ui.downloads.Remove(job.Id)
+				downloadsPage.Load()
+			}
+			return nil
+		case 'C':
+			ui.downloads.ClearFinished()
+			downloadsPage.Load()
+			return nil
+		case 'R':
+			ui.downloads.RetryFailed()
+			downloadsPage.Load()
+			return nil
+		}
+
+		return event
+	})
+
+	downloadsPage.Load()
+	return &downloadsPage
+}
+
+// Load shows the current download queue, it's called whenever downloads make
+// progress. Must be called from the UI goroutine.
+func (d *DownloadsPage) Load() {
+	d.jobs = d.ui.downloads.Jobs()
+
+	songs, size, maxSize := d.ui.downloads.Usage()
+	title := fmt.Sprintf(" downloads: %d songs cached, %s", songs, formatBytes(size))
+	if maxSize > 0 {
+		title += " of " + formatBytes(maxSize)
+	}
+	d.jobList.SetTitle(title + " ")
+
+	current := d.jobList.GetCurrentItem()
+	d.jobList.Clear()
+	for _, job := range d.jobs {
+		d.jobList.AddItem(formatJobForList(job), "", 0, nil)
+	}
+	if current < d.jobList.GetItemCount() {
+		d.jobList.SetCurrentItem(current)
+	}
+}
+
+func (d *DownloadsPage) getSelectedJob() (downloads.Job, bool) {
+	index := d.jobList.GetCurrentItem()
+	if index < 0 || index >= len(d.jobs) {
+		return downloads.Job{}, false
+	}
+	return d.jobs[index], true
+}
+
+func formatJobForList(job downloads.Job) (text string) {
+	color, state := "", ""
+	switch job.State {
+	case downloads.JobQueued:
+		color, state = "gray", "queued"
+	case downloads.JobRunning:
+		color, state = "yellow", formatBytes(job.Received)
+		if job.Size > 0 {
+			state = fmt.Sprintf("%3d%%", job.Received*100/job.Size)
+		}
+	case downloads.JobDone:
+		color, state = "green", "done"
+	case downloads.JobFailed:
+		color, state = "red", "failed"
+	}
+
+	text = "[" + color + "]" + tview.Escape("["+state+"]") + "[white] " + tview.Escape(job.Title)
+	if job.Err != nil {
+		text += " [gray]" + tview.Escape(job.Err.Error()) + "[white]"
+	}
+	return
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
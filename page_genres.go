@@ -0,0 +1,306 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// number of albums/songs fetched at a time
+const genrePageSize = 50
+
+type GenresPage struct {
+	Root *tview.Flex
+
+	genreList *tview.List
+	albumList *tview.List
+	songList  *tview.List
+
+	genres []subsonic.SubsonicGenre
+	albums []subsonic.Album
+	songs  []subsonic.SubsonicEntity
+
+	// name of the genre whose albums and songs are shown
+	currentGenre string
+	loaded       bool
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createGenresPage() *GenresPage {
+	genresPage := GenresPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	// genre list
+	genresPage.genreList = tview.NewList().
+		ShowSecondaryText(false)
+	genresPage.genreList.Box.
+		SetTitle(" genre ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	// album list
+	genresPage.albumList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	genresPage.albumList.Box.
+		SetTitle(" album ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	// song list
+	genresPage.songList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	genresPage.songList.Box.
+		SetTitle(" song ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	genresPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(genresPage.genreList, 0, 1, true).
+		AddItem(genresPage.albumList, 0, 1, false).
+		AddItem(genresPage.songList, 0, 1, false)
+
+	genresPage.genreList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(genresPage.songList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(genresPage.albumList)
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'S':
+			genresPage.handleGenreRadio()
+			return nil
+		case 'R':
+			genresPage.loadGenres()
+			return nil
+		}
+
+		return event
+	})
+	genresPage.albumList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(genresPage.genreList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(genresPage.songList)
+			return nil
+		case tcell.KeyEnter:
+			genresPage.handleAddAlbumToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			genresPage.handleAddAlbumToQueue()
+			return nil
+		case 'n':
+			genresPage.loadMoreAlbums()
+			return nil
+		case 'S':
+			genresPage.handleGenreRadio()
+			return nil
+		}
+
+		return event
+	})
+	genresPage.songList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(genresPage.albumList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(genresPage.genreList)
+			return nil
+		case tcell.KeyEnter:
+			genresPage.handleAddSongToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			genresPage.handleAddSongToQueue()
+			return nil
+		case 'n':
+			genresPage.loadMoreSongs()
+			return nil
+		case 'S':
+			genresPage.handleGenreRadio()
+			return nil
+		}
+
+		return event
+	})
+
+	genresPage.genreList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		if index >= 0 && index < len(genresPage.genres) {
+			genresPage.handleGenreSelected(genresPage.genres[index].Name)
+		}
+	})
+
+	return &genresPage
+}
+
+// Load is called when the page is shown, genres are only fetched the first time.
+func (g *GenresPage) Load() {
+	if !g.loaded {
+		g.loadGenres()
+	}
+}
+
+func (g *GenresPage) loadGenres() {
+	response, err := g.ui.connection.GetGenres()
+	if err != nil {
+		g.logger.PrintError("GetGenres", err)
+		return
+	}
+	g.loaded = true
+
+	g.genres = response.Genres.Genre
+	sort.Slice(g.genres, func(i, j int) bool {
+		return strings.ToLower(g.genres[i].Name) < strings.ToLower(g.genres[j].Name)
+	})
+
+	g.genreList.Clear()
+	for _, genre := range g.genres {
+		text := fmt.Sprintf("%s [gray](%d albums, %d songs)", tview.Escape(genre.Name), genre.AlbumCount, genre.SongCount)
+		g.genreList.AddItem(text, "", 0, nil)
+	}
+
+	// the changed func isn't called for the first item
+	if len(g.genres) > 0 {
+		g.handleGenreSelected(g.genres[g.genreList.GetCurrentItem()].Name)
+	}
+}
+
+func (g *GenresPage) handleGenreSelected(genre string) {
+	g.currentGenre = genre
+
+	g.albums = nil
+	g.albumList.Clear()
+	g.loadMoreAlbums()
+
+	g.songs = nil
+	g.songList.Clear()
+	g.loadMoreSongs()
+}
+
+// loadMoreAlbums appends the next page of albums of the current genre. It's
+// fetched in the background so moving through the genres doesn't block the ui.
+func (g *GenresPage) loadMoreAlbums() {
+	if g.currentGenre == "" {
+		return
+	}
+
+	genre, offset := g.currentGenre, len(g.albums)
+	go func() {
+		response, err := g.ui.connection.GetAlbumListByGenre(genre, genrePageSize, offset)
+		if err != nil {
+			g.logger.PrintError("GetAlbumListByGenre", err)
+			return
+		}
+
+		g.ui.app.QueueUpdateDraw(func() {
+			if g.currentGenre != genre || len(g.albums) != offset {
+				// another genre was selected or this page was added already
+				return
+			}
+			for _, album := range response.AlbumList2.Album {
+				g.albumList.AddItem(formatAlbumForList(album), "", 0, nil)
+				g.albums = append(g.albums, album)
+			}
+		})
+	}()
+}
+
+// loadMoreSongs appends the next page of songs of the current genre once it's
+// fetched
+func (g *GenresPage) loadMoreSongs() {
+	if g.currentGenre == "" {
+		return
+	}
+
+	genre, offset := g.currentGenre, len(g.songs)
+	go func() {
+		response, err := g.ui.connection.GetSongsByGenre(genre, genrePageSize, offset)
+		if err != nil {
+			g.logger.PrintError("GetSongsByGenre", err)
+			return
+		}
+
+		g.ui.app.QueueUpdateDraw(func() {
+			if g.currentGenre != genre || len(g.songs) != offset {
+				return
+			}
+			for _, song := range response.SongsByGenre.Song {
+				g.songList.AddItem(formatSongForPlaylistEntry(song), "", 0, nil)
+				g.songs = append(g.songs, song)
+			}
+		})
+	}()
+}
+
+func (g *GenresPage) handleAddAlbumToQueue() {
+	currentIndex := g.albumList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(g.albums) {
+		return
+	}
+
+	// select next entry
+	if currentIndex+1 < g.albumList.GetItemCount() {
+		g.albumList.SetCurrentItem(currentIndex + 1)
+	}
+
+	g.ui.addAlbumToQueue(g.albums[currentIndex])
+}
+
+func (g *GenresPage) handleAddSongToQueue() {
+	currentIndex := g.songList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(g.songs) {
+		return
+	}
+
+	// select next entry
+	if currentIndex+1 < g.songList.GetItemCount() {
+		g.songList.SetCurrentItem(currentIndex + 1)
+	}
+
+	g.ui.addSongToQueue(&g.songs[currentIndex])
+	g.ui.queuePage.UpdateQueue()
+}
+
+// handleGenreRadio adds random songs of the current genre to the queue
+func (g *GenresPage) handleGenreRadio() {
+	if g.currentGenre == "" {
+		return
+	}
+
+	response, err := g.ui.connection.GetRandomSongsByGenre(g.currentGenre)
+	if err != nil {
+		g.logger.PrintError("GetRandomSongsByGenre", err)
+		return
+	}
+
+	for _, e := range response.RandomSongs.Song {
+		g.ui.addSongToQueue(&e)
+	}
+	g.ui.queuePage.UpdateQueue()
+}
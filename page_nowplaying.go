@@ -0,0 +1,125 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// how often the background event loop refreshes the page while it's shown
+const nowPlayingRefreshInterval = 30 * time.Second
+
+type NowPlayingPage struct {
+	Root *tview.Flex
+
+	entryList *tview.List
+
+	entries []subsonic.NowPlayingEntry
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createNowPlayingPage() *NowPlayingPage {
+	nowPlayingPage := NowPlayingPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	nowPlayingPage.entryList = tview.NewList().
+		ShowSecondaryText(false)
+	nowPlayingPage.entryList.Box.
+		SetTitle(" now playing on the server ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	nowPlayingPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(nowPlayingPage.entryList, 0, 1, true)
+
+	nowPlayingPage.entryList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			nowPlayingPage.handleAddEntryToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			nowPlayingPage.handleAddEntryToQueue()
+			return nil
+		case 'R':
+			nowPlayingPage.Load()
+			return nil
+		}
+
+		return event
+	})
+
+	return &nowPlayingPage
+}
+
+// Load is called when the page is shown, afterwards the background event loop
+// keeps it up to date.
+func (n *NowPlayingPage) Load() {
+	response, err := n.ui.connection.GetNowPlaying()
+	if err != nil {
+		n.logger.PrintError("GetNowPlaying", err)
+		return
+	}
+	n.setEntries(response.NowPlaying.Entry)
+}
+
+// setEntries must be called from the UI goroutine
+func (n *NowPlayingPage) setEntries(entries []subsonic.NowPlayingEntry) {
+	// keep the selected entry selected
+	selectedId := ""
+	if index := n.entryList.GetCurrentItem(); index >= 0 && index < len(n.entries) {
+		selectedId = n.entries[index].Id
+	}
+	n.entries = entries
+
+	n.entryList.Clear()
+	for i, entry := range n.entries {
+		n.entryList.AddItem(formatNowPlayingForList(entry), "", 0, nil)
+		if entry.Id == selectedId {
+			n.entryList.SetCurrentItem(i)
+		}
+	}
+	if len(n.entries) == 0 {
+		n.entryList.AddItem("[gray]nobody is playing anything", "", 0, nil)
+	}
+}
+
+func (n *NowPlayingPage) handleAddEntryToQueue() {
+	index := n.entryList.GetCurrentItem()
+	if index < 0 || index >= len(n.entries) {
+		return
+	}
+
+	n.ui.addSongToQueue(&n.entries[index].SubsonicEntity)
+	n.ui.queuePage.UpdateQueue()
+}
+
+func formatNowPlayingForList(entry subsonic.NowPlayingEntry) (text string) {
+	text = "[::b]" + tview.Escape(entry.Username) + "[::-]"
+	if entry.PlayerName != "" {
+		text += " [gray](" + tview.Escape(entry.PlayerName) + ")[white]"
+	}
+	text += " " + tview.Escape(entry.GetSongTitle())
+	if entry.Artist != "" {
+		text += " [gray]by[white] " + tview.Escape(entry.Artist)
+	}
+	if entry.MinutesAgo > 0 {
+		text += fmt.Sprintf(" [gray]%d min ago[white]", entry.MinutesAgo)
+	} else {
+		text += " [gray]just now[white]"
+	}
+	return
+}
@@ -119,6 +119,10 @@ func (ui *Ui) createPlaylistPage() *PlaylistPage {
 			ui.pages.ShowPage(PageDeletePlaylist)
 			return nil
 		}
+		if event.Rune() == 'w' {
+			playlistPage.handleSharePlaylist()
+			return nil
+		}
 
 		return event
 	})
@@ -132,6 +136,10 @@ func (ui *Ui) createPlaylistPage() *PlaylistPage {
 			playlistPage.handleAddPlaylistSongToQueue()
 			return nil
 		}
+		if event.Rune() == 'w' {
+			playlistPage.handleSharePlaylistSong()
+			return nil
+		}
 		return event
 	})
 
@@ -302,6 +310,30 @@ func (p *PlaylistPage) handleAddPlaylistSongToQueue() {
 	p.ui.queuePage.UpdateQueue()
 }
 
+func (p *PlaylistPage) handleSharePlaylist() {
+	currentIndex := p.playlistList.GetCurrentItem()
+	if currentIndex < 0 || currentIndex >= len(p.ui.playlists) {
+		return
+	}
+
+	playlist := p.ui.playlists[currentIndex]
+	p.ui.shareWidget.ShowCreate([]string{string(playlist.Id)}, playlist.Name)
+}
+
+func (p *PlaylistPage) handleSharePlaylistSong() {
+	playlistIndex := p.playlistList.GetCurrentItem()
+	entityIndex := p.selectedPlaylist.GetCurrentItem()
+	if playlistIndex < 0 || playlistIndex >= len(p.ui.playlists) {
+		return
+	}
+	if entityIndex < 0 || entityIndex >= len(p.ui.playlists[playlistIndex].Entries) {
+		return
+	}
+
+	entity := p.ui.playlists[playlistIndex].Entries[entityIndex]
+	p.ui.shareWidget.ShowCreate([]string{entity.Id}, entity.Title)
+}
+
 func (p *PlaylistPage) handleAddPlaylistToQueue() {
 	currentIndex := p.playlistList.GetCurrentItem()
 	if currentIndex < 0 || currentIndex >= p.playlistList.GetItemCount() || currentIndex >= len(p.ui.playlists) {
@@ -335,7 +367,7 @@ func (p *PlaylistPage) handlePlaylistSelected(playlist subsonic.SubsonicPlaylist
 func (p *PlaylistPage) newPlaylist(name string) {
 	response, err := p.ui.connection.CreatePlaylist("", name, nil)
 	if err != nil {
-		p.logger.Printf("newPlaylist: CreatePlaylist %s -- %s", name, err.Error())
+		p.ui.showErrorMessage("Creating playlist", err)
 		return
 	}
 
@@ -351,6 +383,10 @@ func (p *PlaylistPage) deletePlaylist(index int) {
 	}
 
 	playlist := p.ui.playlists[index]
+	if err := p.ui.connection.DeletePlaylist(string(playlist.Id)); err != nil {
+		p.ui.showErrorMessage("Deleting playlist", err)
+		return
+	}
 
 	if index == 0 {
 		p.playlistList.SetCurrentItem(1)
@@ -361,7 +397,4 @@ func (p *PlaylistPage) deletePlaylist(index int) {
 
 	p.playlistList.RemoveItem(index)
 	p.ui.addToPlaylistList.RemoveItem(index)
-	if err := p.ui.connection.DeletePlaylist(string(playlist.Id)); err != nil {
-		p.logger.PrintError("deletePlaylist", err)
-	}
 }
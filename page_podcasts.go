@@ -0,0 +1,488 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+const (
+	// number of episodes in the "newest" pseudo channel
+	newestPodcastCount = 30
+	// an episode played up to this many seconds before its end is finished and
+	// starts from the beginning next time
+	podcastFinishedMargin = 30
+)
+
+type PodcastsPage struct {
+	Root               *tview.Flex
+	NewChannelModal    tview.Primitive
+	DeleteEpisodeModal *tview.Modal
+
+	channelList *tview.List
+	episodeList *tview.List
+	channelForm *tview.Form
+
+	// the first entry of the channel list shows the newest episodes
+	channels []subsonic.PodcastChannel
+	newest   []subsonic.PodcastEpisode
+	// episodes of the selected channel
+	episodes []subsonic.PodcastEpisode
+	loaded   bool
+
+	positions *podcastPositions
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createPodcastsPage() *PodcastsPage {
+	podcastsPage := PodcastsPage{
+		positions: loadPodcastPositions(ui.logger),
+
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	podcastsPage.channelList = tview.NewList().
+		ShowSecondaryText(false)
+	podcastsPage.channelList.Box.
+		SetTitle(" channel ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	podcastsPage.episodeList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	podcastsPage.episodeList.Box.
+		SetTitle(" episode ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	podcastsPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(podcastsPage.channelList, 0, 1, true).
+		AddItem(podcastsPage.episodeList, 0, 2, false)
+
+	podcastsPage.channelList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRight, tcell.KeyEnter:
+			ui.app.SetFocus(podcastsPage.episodeList)
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'n':
+			podcastsPage.showChannelForm()
+			return nil
+		case 'R':
+			podcastsPage.loadChannels()
+			return nil
+		}
+
+		return event
+	})
+	podcastsPage.episodeList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft, tcell.KeyEscape:
+			ui.app.SetFocus(podcastsPage.channelList)
+			return nil
+		case tcell.KeyEnter:
+			podcastsPage.handlePlayEpisode()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			podcastsPage.handleAddEpisodeToQueue()
+			return nil
+		case 'o':
+			// D is taken by clearing the queue
+			podcastsPage.handleDownloadEpisode()
+			return nil
+		case 'd':
+			if episode, ok := podcastsPage.getSelectedEpisode(); ok {
+				podcastsPage.DeleteEpisodeModal.SetText(fmt.Sprintf("Delete episode %s from the server?", episode.Title))
+				ui.pages.ShowPage(PageDeletePodcastEpisode)
+				ui.app.SetFocus(podcastsPage.DeleteEpisodeModal)
+			}
+			return nil
+		case 'R':
+			podcastsPage.loadChannels()
+			return nil
+		}
+
+		return event
+	})
+
+	podcastsPage.channelList.SetChangedFunc(func(index int, _ string, _ string, _ rune) {
+		podcastsPage.handleChannelSelected(index)
+	})
+
+	// "new channel" modal
+	podcastsPage.channelForm = tview.NewForm().
+		AddInputField("Feed URL", "", 50, nil, nil).
+		AddButton("Subscribe", podcastsPage.createChannel).
+		AddButton("Cancel", podcastsPage.hideChannelForm)
+	podcastsPage.channelForm.SetCancelFunc(podcastsPage.hideChannelForm)
+	podcastsPage.channelForm.SetBorder(true)
+
+	podcastsPage.NewChannelModal = makeModal(podcastsPage.channelForm, 70, 7)
+
+	// delete confirmation
+	podcastsPage.DeleteEpisodeModal = tview.NewModal().
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			ui.pages.HidePage(PageDeletePodcastEpisode)
+			ui.app.SetFocus(podcastsPage.episodeList)
+			if buttonLabel == "Delete" {
+				podcastsPage.deleteEpisode()
+			}
+		})
+
+	return &podcastsPage
+}
+
+// Load is called when the page is shown, channels are only fetched the first time.
+func (p *PodcastsPage) Load() {
+	if !p.loaded {
+		p.loadChannels()
+	} else {
+		// positions may have changed while listening
+		p.updateEpisodeList()
+	}
+}
+
+func (p *PodcastsPage) loadChannels() {
+	response, err := p.ui.connection.GetPodcasts(true, "")
+	if err != nil {
+		p.logger.PrintError("GetPodcasts", err)
+		return
+	}
+	p.loaded = true
+	p.channels = response.Podcasts.Channel
+
+	// not all servers implement this, the channels are still usable without it
+	p.newest = nil
+	if response, err := p.ui.connection.GetNewestPodcasts(newestPodcastCount); err != nil {
+		p.logger.PrintError("GetNewestPodcasts", err)
+	} else {
+		p.newest = response.NewestPodcasts.Episode
+	}
+
+	current := p.channelList.GetCurrentItem()
+	p.channelList.Clear()
+	p.channelList.AddItem("[::b]Newest episodes[::-]", "", 0, nil)
+	for _, channel := range p.channels {
+		p.channelList.AddItem(formatChannelForList(channel), "", 0, nil)
+	}
+	if current < p.channelList.GetItemCount() {
+		p.channelList.SetCurrentItem(current)
+	}
+
+	// the changed func isn't called if the selection stays the same
+	p.handleChannelSelected(p.channelList.GetCurrentItem())
+}
+
+func (p *PodcastsPage) handleChannelSelected(index int) {
+	if index == 0 {
+		p.episodes = p.newest
+		p.episodeList.SetTitle(" newest episodes ")
+	} else if index > 0 && index <= len(p.channels) {
+		channel := p.channels[index-1]
+		p.episodes = channel.Episode
+		if channel.Status == subsonic.PodcastStatusError && channel.ErrorMessage != "" {
+			p.episodeList.SetTitle(fmt.Sprintf(" episode [red](%s)[-] ", tview.Escape(channel.ErrorMessage)))
+		} else {
+			p.episodeList.SetTitle(" episode ")
+		}
+	} else {
+		p.episodes = nil
+	}
+
+	p.updateEpisodeList()
+	p.episodeList.SetCurrentItem(0)
+}
+
+func (p *PodcastsPage) updateEpisodeList() {
+	current := p.episodeList.GetCurrentItem()
+	p.episodeList.Clear()
+	for _, episode := range p.episodes {
+		p.episodeList.AddItem(p.formatEpisodeForList(episode), "", 0, nil)
+	}
+	if current < p.episodeList.GetItemCount() {
+		p.episodeList.SetCurrentItem(current)
+	}
+}
+
+func (p *PodcastsPage) getSelectedEpisode() (subsonic.PodcastEpisode, bool) {
+	index := p.episodeList.GetCurrentItem()
+	if index < 0 || index >= len(p.episodes) {
+		return subsonic.PodcastEpisode{}, false
+	}
+	return p.episodes[index], true
+}
+
+// getPlayableEpisode returns the selected episode if it can be streamed, the
+// user is told why otherwise
+func (p *PodcastsPage) getPlayableEpisode() (subsonic.PodcastEpisode, bool) {
+	episode, ok := p.getSelectedEpisode()
+	if !ok {
+		return episode, false
+	}
+	if !episode.IsPlayable() {
+		p.ui.showMessageBox(fmt.Sprintf("Episode is %s on the server, press D to download it first", episode.Status))
+		return episode, false
+	}
+	return episode, true
+}
+
+func (p *PodcastsPage) handlePlayEpisode() {
+	episode, ok := p.getPlayableEpisode()
+	if !ok {
+		return
+	}
+
+	if err := p.ui.player.PlayItem(p.makeEpisodeQueueItem(episode)); err != nil {
+		p.logger.PrintError("handlePlayEpisode", err)
+		return
+	}
+	p.ui.queuePage.UpdateQueue()
+}
+
+func (p *PodcastsPage) handleAddEpisodeToQueue() {
+	episode, ok := p.getPlayableEpisode()
+	if !ok {
+		return
+	}
+
+	item := p.makeEpisodeQueueItem(episode)
+	p.ui.player.AddToQueue(&item)
+	p.ui.queuePage.UpdateQueue()
+}
+
+func (p *PodcastsPage) handleDownloadEpisode() {
+	episode, ok := p.getSelectedEpisode()
+	if !ok {
+		return
+	}
+
+	if _, err := p.ui.connection.DownloadPodcastEpisode(episode.Id); err != nil {
+		p.ui.showErrorMessage("Downloading episode", err)
+		return
+	}
+
+	p.loadChannels()
+}
+
+func (p *PodcastsPage) deleteEpisode() {
+	episode, ok := p.getSelectedEpisode()
+	if !ok {
+		return
+	}
+
+	if _, err := p.ui.connection.DeletePodcastEpisode(episode.Id); err != nil {
+		p.ui.showErrorMessage("Deleting episode", err)
+		return
+	}
+	p.positions.Delete(episode.Id)
+
+	p.loadChannels()
+}
+
+func (p *PodcastsPage) showChannelForm() {
+	p.channelForm.SetTitle(" Subscribe to podcast ")
+	p.channelForm.GetFormItem(0).(*tview.InputField).SetText("")
+	p.channelForm.SetFocus(0)
+
+	p.ui.pages.ShowPage(PageNewPodcastChannel)
+	p.ui.app.SetFocus(p.channelForm)
+}
+
+func (p *PodcastsPage) hideChannelForm() {
+	p.ui.pages.HidePage(PageNewPodcastChannel)
+	p.ui.app.SetFocus(p.channelList)
+}
+
+func (p *PodcastsPage) createChannel() {
+	url := strings.TrimSpace(p.channelForm.GetFormItem(0).(*tview.InputField).GetText())
+	if url == "" {
+		p.channelForm.SetTitle(" Feed URL is required ")
+		return
+	}
+
+	p.hideChannelForm()
+
+	if _, err := p.ui.connection.CreatePodcastChannel(url); err != nil {
+		p.ui.showErrorMessage("Subscribing to podcast", err)
+		return
+	}
+
+	p.loadChannels()
+}
+
+// UpdatePosition remembers position (in seconds) if a podcast episode is
+// playing. Must be called from the UI goroutine.
+func (p *PodcastsPage) UpdatePosition(position int64) {
+	item, err := p.ui.player.GetQueueItem(0)
+	if err != nil || item.Kind != mpvplayer.KindPodcast {
+		return
+	}
+	if item.StartPosition > 0 {
+		// mpv hasn't seeked to the saved position yet
+		return
+	}
+
+	if item.Duration > 0 && int(position) >= item.Duration-podcastFinishedMargin {
+		p.positions.Delete(item.Id)
+	} else {
+		p.positions.Set(item.Id, int(position))
+	}
+}
+
+// SavePositions writes the episode positions to disk if they changed
+func (p *PodcastsPage) SavePositions() {
+	if err := p.positions.Save(); err != nil {
+		p.logger.PrintError("save podcast positions", err)
+	}
+}
+
+func (p *PodcastsPage) makeEpisodeQueueItem(episode subsonic.PodcastEpisode) mpvplayer.QueueItem {
+	channelTitle := ""
+	for _, channel := range p.channels {
+		if channel.Id == episode.ChannelId {
+			channelTitle = channel.Title
+			break
+		}
+	}
+
+	item := mpvplayer.QueueItem{
+		Id:         episode.Id,
+		Uri:        p.ui.connection.GetPlayUrl(&subsonic.SubsonicEntity{Id: episode.StreamId}),
+		Title:      episode.Title,
+		Artist:     stringOr(episode.Artist, channelTitle),
+		Album:      stringOr(episode.Album, channelTitle),
+		Duration:   episode.Duration,
+		CoverArtId: episode.CoverArtId,
+		Kind:       mpvplayer.KindPodcast,
+	}
+	p.ui.startQueueItemAt(&item, p.positions.Get(episode.Id))
+	return item
+}
+
+func (p *PodcastsPage) formatEpisodeForList(episode subsonic.PodcastEpisode) (text string) {
+	text = tview.Escape(episode.Title)
+	if len(episode.PublishDate) >= 10 {
+		// only the date of the timestamp
+		text += " [gray]" + episode.PublishDate[:10] + "[white]"
+	}
+
+	switch episode.Status {
+	case subsonic.PodcastStatusCompleted:
+		if position := p.positions.Get(episode.Id); position > 0 {
+			min, sec := iSecondsToMinAndSec(position)
+			text += fmt.Sprintf(" [green](at %d:%02d)[white]", min, sec)
+		}
+	case subsonic.PodcastStatusDownloading:
+		text += " [yellow](downloading)[white]"
+	case subsonic.PodcastStatusError:
+		text += " [red](error)[white]"
+	default:
+		text += " [gray](" + tview.Escape(episode.Status) + ")[white]"
+	}
+	return
+}
+
+func formatChannelForList(channel subsonic.PodcastChannel) (text string) {
+	text = tview.Escape(stringOr(channel.Title, channel.Url))
+	switch channel.Status {
+	case subsonic.PodcastStatusDownloading:
+		text += " [yellow](updating)[white]"
+	case subsonic.PodcastStatusError:
+		text += " [red](error)[white]"
+	}
+	return
+}
+
+// podcastPositions remembers where episodes were left off, it's stored in the
+// user cache dir so it survives restarts
+type podcastPositions struct {
+	path      string
+	positions map[string]int
+	dirty     bool
+}
+
+func loadPodcastPositions(logger logger.LoggerInterface) *podcastPositions {
+	pp := &podcastPositions{
+		positions: map[string]int{},
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		// positions are only kept until quitting
+		logger.PrintError("podcast positions", err)
+		return pp
+	}
+	pp.path = filepath.Join(cacheDir, "stmps", "podcast-positions.json")
+
+	data, err := os.ReadFile(pp.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.PrintError("podcast positions", err)
+		}
+		return pp
+	}
+	if err := json.Unmarshal(data, &pp.positions); err != nil {
+		logger.PrintError("podcast positions", err)
+	}
+	return pp
+}
+
+func (pp *podcastPositions) Get(id string) int {
+	return pp.positions[id]
+}
+
+func (pp *podcastPositions) Set(id string, position int) {
+	if pp.positions[id] != position {
+		pp.positions[id] = position
+		pp.dirty = true
+	}
+}
+
+func (pp *podcastPositions) Delete(id string) {
+	if _, ok := pp.positions[id]; ok {
+		delete(pp.positions, id)
+		pp.dirty = true
+	}
+}
+
+func (pp *podcastPositions) Save() error {
+	if !pp.dirty || pp.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(pp.positions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(pp.path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(pp.path, data, 0o644); err != nil {
+		return err
+	}
+	pp.dirty = false
+	return nil
+}
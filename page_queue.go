@@ -23,18 +23,24 @@ import (
 
 // TODO show total # of entries somewhere (top?)
 
-// columns: star, title, artist, duration
-const queueDataColumns = 4
+// columns: star, title, artist, rating, duration
+const queueDataColumns = 5
 const starIcon = "♥"
+const ratingIcon = "★"
 
 // data for rendering queue table
 type queueData struct {
 	tview.TableContentReadOnly
 
-	// our copy of the queue
+	// the queue snapshot that is shown, it's shared with the player and must
+	// not be modified. Items before position were played.
 	playerQueue mpvplayer.PlayerQueue
+	position    int
+	version     uint64
 	// we also need to know which elements are starred
 	starIdList map[string]struct{}
+	// and how they are rated
+	ratings map[string]int
 }
 
 var _ tview.TableContent = (*queueData)(nil)
@@ -45,9 +51,14 @@ type QueuePage struct {
 	queueList *tview.Table
 	queueData queueData
 
+	infoFlex *tview.Flex
 	songInfo *tview.TextView
 	coverArt *tview.Image
 
+	// lyrics of the playing song replace song info and cover art if enabled
+	lyrics     *LyricsWidget
+	showLyrics bool
+
 	// external refs
 	ui     *Ui
 	logger logger.LoggerInterface
@@ -57,6 +68,10 @@ type QueuePage struct {
 
 var STMPS_LOGO image.Image
 
+// coverArtSize is the size in pixels cover arts are scaled to by the server,
+// that's plenty for a terminal
+const coverArtSize = 300
+
 // init sets up the default image used for songs for which the server provides
 // no cover art.
 func init() {
@@ -94,22 +109,28 @@ func (ui *Ui) createQueuePage() *QueuePage {
 	queuePage.queueList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyDelete || event.Rune() == 'd' {
 			queuePage.handleDeleteFromQueue()
+		} else if rating := ratingFromKey(event); rating >= 0 {
+			queuePage.handleSetRating(rating)
 		} else {
 			switch event.Rune() {
 			case 'y':
 				queuePage.handleToggleStar()
+			case 'w':
+				queuePage.handleShare()
 			case 'j':
 				queuePage.moveSongDown()
 			case 'k':
 				queuePage.moveSongUp()
 			case 's':
-				if len(queuePage.queueData.playerQueue) == 0 {
+				if len(queuePage.queueData.upcoming()) == 0 {
 					queuePage.logger.Print("no items in queue to save")
 					return nil
 				}
 				queuePage.ui.ShowSelectPlaylist()
 			case 'S':
 				queuePage.shuffle()
+			case 'L':
+				queuePage.toggleLyrics()
 			case 'l':
 				go func() {
 					ssr, err := queuePage.ui.connection.LoadPlayQueue()
@@ -117,21 +138,38 @@ func (ui *Ui) createQueuePage() *QueuePage {
 						queuePage.logger.Printf("unable to load play queue from server: %s", err)
 						return
 					}
-					queuePage.queueList.Clear()
-					queuePage.queueData.Clear()
-					if ssr.PlayQueue.Entries != nil {
-						for _, ent := range ssr.PlayQueue.Entries {
-							ui.addSongToQueue(&ent)
-						}
-						ui.queuePage.UpdateQueue()
-						if err := ui.player.Play(); err != nil {
-							queuePage.logger.Printf("error playing: %s", err)
-						}
-						if err = ui.player.Seek(ssr.PlayQueue.Position); err != nil {
-							queuePage.logger.Printf("unable to seek to position %s: %s", time.Duration(ssr.PlayQueue.Position)*time.Second, err)
-						}
-						_ = ui.player.Pause()
+					// the album lookups block, only the queue itself is filled
+					// on the ui goroutine
+					items := make([]mpvplayer.QueueItem, 0, len(ssr.PlayQueue.Entries))
+					ids := make([]string, 0, len(ssr.PlayQueue.Entries))
+					for _, ent := range ssr.PlayQueue.Entries {
+						items = append(items, ui.newSongQueueItem(&ent))
+						ids = append(ids, ent.Id)
 					}
+					ui.app.QueueUpdateDraw(func() {
+						queuePage.queueList.Clear()
+						queuePage.queueData.Clear()
+						if len(items) > 0 {
+							// the songs are queued in order, shuffled afterwards
+							if err := ui.player.SetShuffle(false); err != nil {
+								queuePage.logger.PrintError("SetShuffle", err)
+							}
+							for i := range items {
+								ent := &ssr.PlayQueue.Entries[i]
+								ui.rememberRating(ent.Id, ent.UserRating)
+								ui.player.AddToQueue(&items[i])
+							}
+							ui.restoreShuffle(ids)
+							ui.queuePage.UpdateQueue()
+							if err := ui.player.Play(); err != nil {
+								queuePage.logger.Printf("error playing: %s", err)
+							}
+							if err := ui.player.Seek(ssr.PlayQueue.Position); err != nil {
+								queuePage.logger.Printf("unable to seek to position %s: %s", time.Duration(ssr.PlayQueue.Position)*time.Second, err)
+							}
+							_ = ui.player.Pause()
+						}
+					})
 				}()
 
 			default:
@@ -154,20 +192,21 @@ func (ui *Ui) createQueuePage() *QueuePage {
 	queuePage.coverArt = tview.NewImage()
 	queuePage.coverArt.SetImage(STMPS_LOGO)
 
-	infoFlex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(queuePage.songInfo, 0, 1, false).
-		AddItem(queuePage.coverArt, 0, 1, false)
-	infoFlex.SetBorder(true)
-	infoFlex.SetTitle(" song info ")
+	queuePage.lyrics = ui.createLyricsWidget()
+
+	queuePage.infoFlex = tview.NewFlex().SetDirection(tview.FlexRow)
+	queuePage.infoFlex.SetBorder(true)
+	queuePage.setInfoPanel()
 
 	// flex wrapper
 	queuePage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(queuePage.queueList, 0, 2, true).
-		AddItem(infoFlex, 0, 1, false)
+		AddItem(queuePage.infoFlex, 0, 1, false)
 
 	// private data
 	queuePage.queueData = queueData{
 		starIdList: ui.starIdList,
+		ratings:    ui.ratings,
 	}
 
 	return &queuePage
@@ -182,24 +221,81 @@ func (q *QueuePage) changeSelection(row, column int) {
 	currentSong := q.queueData.playerQueue[row]
 	art := STMPS_LOGO
 	if currentSong.CoverArtId != "" {
-		if nart, err := q.ui.connection.GetCoverArt(currentSong.CoverArtId); err == nil {
-			if nart != nil {
-				art = nart
-			} else {
-				q.logger.Printf("%q cover art %s was unexpectedly nil", currentSong.Title, currentSong.CoverArtId)
-			}
+		if cached, ok := q.ui.connection.CachedCoverArt(currentSong.CoverArtId, coverArtSize); ok {
+			art = cached
 		} else {
-			q.logger.Printf("error fetching cover art for %s: %v", currentSong.Title, err)
+			// show the logo until the cover art is there
+			go q.fetchCoverArt(currentSong)
 		}
 	}
 	q.coverArt.SetImage(art)
 	_ = q.songInfoTemplate.Execute(q.songInfo, currentSong)
 }
 
+// fetchCoverArt shows the cover art of song once it's loaded, if song is still
+// selected by then
+func (q *QueuePage) fetchCoverArt(song mpvplayer.QueueItem) {
+	art, err := q.ui.connection.GetCoverArt(song.CoverArtId, coverArtSize)
+	if err != nil {
+		q.logger.Printf("error fetching cover art for %s: %v", song.Title, err)
+		return
+	}
+
+	q.ui.app.QueueUpdateDraw(func() {
+		row, _ := q.queueList.GetSelection()
+		if row >= 0 && row < len(q.queueData.playerQueue) && q.queueData.playerQueue[row].CoverArtId == song.CoverArtId {
+			q.coverArt.SetImage(art)
+		}
+	})
+}
+
 func (q *QueuePage) UpdateQueue() {
 	q.updateQueue()
 }
 
+// setInfoPanel fills the right panel with either song info and cover art, or
+// the lyrics of the playing song
+func (q *QueuePage) setInfoPanel() {
+	q.infoFlex.Clear()
+	if q.showLyrics {
+		q.infoFlex.SetTitle(" lyrics ")
+		q.infoFlex.AddItem(q.lyrics.Root, 0, 1, false)
+	} else {
+		q.infoFlex.SetTitle(" song info ")
+		q.infoFlex.AddItem(q.songInfo, 0, 1, false).
+			AddItem(q.coverArt, 0, 1, false)
+	}
+}
+
+func (q *QueuePage) toggleLyrics() {
+	q.showLyrics = !q.showLyrics
+	q.setInfoPanel()
+	if q.showLyrics {
+		q.loadLyrics()
+	} else {
+		// don't keep stale lyrics around while they aren't updated
+		q.lyrics.Clear()
+	}
+}
+
+// loadLyrics shows the lyrics of the first song in the queue, which is the
+// one that is playing
+func (q *QueuePage) loadLyrics() {
+	var playing mpvplayer.QueueItem
+	if upcoming := q.queueData.upcoming(); len(upcoming) > 0 {
+		playing = upcoming[0]
+	}
+	q.lyrics.Load(playing)
+}
+
+// UpdateLyricsPosition highlights the current line of synced lyrics,
+// position is in seconds
+func (q *QueuePage) UpdateLyricsPosition(position int64) {
+	if q.showLyrics {
+		q.lyrics.UpdatePosition(position)
+	}
+}
+
 func (q *QueuePage) getSelectedItem() (index int, err error) {
 	index, _ = q.queueList.GetSelection()
 	if index < 0 {
@@ -209,9 +305,35 @@ func (q *QueuePage) getSelectedItem() (index int, err error) {
 	return
 }
 
+// getSelectedEntity returns the selected item as it's shown, the player's
+// queue may have changed since
+func (q *QueuePage) getSelectedEntity() (mpvplayer.QueueItem, error) {
+	index, err := q.getSelectedItem()
+	if err != nil {
+		return mpvplayer.QueueItem{}, err
+	}
+	if index >= len(q.queueData.playerQueue) {
+		return mpvplayer.QueueItem{}, errors.New("invalid queue entry")
+	}
+	return q.queueData.playerQueue[index], nil
+}
+
+// getSelectedQueueIndex returns the player's queue index of the selected
+// item, played items have none
+func (q *QueuePage) getSelectedQueueIndex() (int, error) {
+	row, err := q.getSelectedItem()
+	if err != nil {
+		return 0, err
+	}
+	if row < q.queueData.position {
+		return 0, errors.New("played item")
+	}
+	return row - q.queueData.position, nil
+}
+
 // button handler
 func (q *QueuePage) handleDeleteFromQueue() {
-	currentIndex, err := q.getSelectedItem()
+	currentIndex, err := q.getSelectedQueueIndex()
 	if err != nil {
 		return
 	}
@@ -222,18 +344,29 @@ func (q *QueuePage) handleDeleteFromQueue() {
 }
 
 // button handler
+func (q *QueuePage) handleShare() {
+	entity, err := q.getSelectedEntity()
+	if err != nil {
+		q.logger.PrintError("handleShare", err)
+		return
+	}
+	if !entity.IsSong() {
+		// radio stations and podcast episodes can't be shared
+		return
+	}
+
+	q.ui.shareWidget.ShowCreate([]string{entity.Id}, entity.Title)
+}
+
 func (q *QueuePage) handleToggleStar() {
 	starIdList := q.queueData.starIdList
 
-	currentIndex, err := q.getSelectedItem()
+	entity, err := q.getSelectedEntity()
 	if err != nil {
 		q.logger.PrintError("handleToggleStar", err)
 		return
 	}
-
-	entity, err := q.ui.player.GetQueueItem(currentIndex)
-	if err != nil {
-		q.logger.PrintError("handleToggleStar", err)
+	if !entity.IsSong() {
 		return
 	}
 
@@ -242,7 +375,7 @@ func (q *QueuePage) handleToggleStar() {
 
 	// update on server
 	if _, err = q.ui.connection.ToggleStar(entity.Id, starIdList); err != nil {
-		q.ui.showMessageBox("ToggleStar failed")
+		q.ui.showErrorMessage("Toggling star", err)
 		return // fail, assume not toggled
 	}
 
@@ -255,13 +388,33 @@ func (q *QueuePage) handleToggleStar() {
 	q.ui.browserPage.UpdateStars()
 }
 
+func (q *QueuePage) handleSetRating(rating int) {
+	entity, err := q.getSelectedEntity()
+	if err != nil {
+		q.logger.PrintError("handleSetRating", err)
+		return
+	}
+	if !entity.IsSong() {
+		return
+	}
+
+	if q.ui.setRating(entity.Id, rating) {
+		q.ui.browserPage.UpdateStars()
+	}
+}
+
 // re-read queue data from mpvplayer which is the authoritative source for the queue
 func (q *QueuePage) updateQueue() {
 	queueWasEmpty := len(q.queueData.playerQueue) == 0
 
-	// tell tview table to update its data
-	q.queueData.playerQueue = q.ui.player.GetQueueCopy()
-	q.queueList.SetContent(&q.queueData)
+	// tell tview table to update its data if the queue changed
+	snapshot := q.ui.player.GetQueueSnapshot()
+	if snapshot.Version != q.queueData.version {
+		q.queueData.playerQueue = snapshot.Items
+		q.queueData.position = snapshot.Position
+		q.queueData.version = snapshot.Version
+		q.queueList.SetContent(&q.queueData)
+	}
 
 	// by default we're scrolled down after initially adding rows, fix this
 	if queueWasEmpty {
@@ -270,23 +423,29 @@ func (q *QueuePage) updateQueue() {
 
 	r, c := q.queueList.GetSelection()
 	q.changeSelection(r, c)
+
+	if q.showLyrics {
+		q.loadLyrics()
+	}
 }
 
 // moveSongUp moves the currently selected song up in the queue
 // If the selected song isn't the third or higher, this is a NOP
 // and no error is reported.
 func (q *QueuePage) moveSongUp() {
-	if len(q.queueData.playerQueue) == 0 {
+	if len(q.queueData.upcoming()) == 0 {
 		return
 	}
 
-	currentIndex, column := q.queueList.GetSelection()
-	if currentIndex < 0 || column < 0 {
-		q.logger.Printf("moveSongUp: invalid selection (%d, %d)", currentIndex, column)
+	row, column := q.queueList.GetSelection()
+	if row < 0 || column < 0 {
+		q.logger.Printf("moveSongUp: invalid selection (%d, %d)", row, column)
 		return
 	}
 
-	if currentIndex == 0 {
+	// played songs stay where they are
+	currentIndex := row - q.queueData.position
+	if currentIndex <= 0 {
 		return
 	}
 
@@ -297,7 +456,7 @@ func (q *QueuePage) moveSongUp() {
 
 	// remove the item from the queue
 	q.ui.player.MoveSongUp(currentIndex)
-	q.queueList.Select(currentIndex-1, column)
+	q.queueList.Select(row-1, column)
 	q.updateQueue()
 }
 
@@ -305,14 +464,20 @@ func (q *QueuePage) moveSongUp() {
 // If the selected song is not the second-to-the-last or lower, this is a NOP,
 // and no error is reported
 func (q *QueuePage) moveSongDown() {
-	queueLen := len(q.queueData.playerQueue)
+	queueLen := len(q.queueData.upcoming())
 	if queueLen == 0 {
 		return
 	}
 
-	currentIndex, column := q.queueList.GetSelection()
-	if currentIndex < 0 || column < 0 {
-		q.logger.Printf("moveSongDown: invalid selection (%d, %d)", currentIndex, column)
+	row, column := q.queueList.GetSelection()
+	if row < 0 || column < 0 {
+		q.logger.Printf("moveSongDown: invalid selection (%d, %d)", row, column)
+		return
+	}
+
+	// played songs stay where they are
+	currentIndex := row - q.queueData.position
+	if currentIndex < 0 {
 		return
 	}
 
@@ -328,7 +493,7 @@ func (q *QueuePage) moveSongDown() {
 
 	// remove the item from the queue
 	q.ui.player.MoveSongDown(currentIndex)
-	q.queueList.Select(currentIndex+1, column)
+	q.queueList.Select(row+1, column)
 	q.updateQueue()
 }
 
@@ -351,9 +516,13 @@ func (q *QueuePage) saveQueue(playlistName string) {
 	// a more complex diffing algorithm, and much more code.
 	// Consequently, this version of save() uses the more simple
 	// brute-force approach of always using createPlaylist().
-	songIds := make([]string, len(q.queueData.playerQueue))
-	for i, it := range q.queueData.playerQueue {
-		songIds[i] = it.Id
+	// radio stations can't be part of a playlist
+	upcoming := q.queueData.upcoming()
+	songIds := make([]string, 0, len(upcoming))
+	for _, it := range upcoming {
+		if it.IsSong() {
+			songIds = append(songIds, it.Id)
+		}
 	}
 
 	var playlistId string
@@ -366,16 +535,14 @@ func (q *QueuePage) saveQueue(playlistName string) {
 	var response *subsonic.SubsonicResponse
 	var err error
 	if playlistId == "" {
-		q.logger.Printf("Saving %d items to playlist %s", len(q.queueData.playerQueue), playlistName)
+		q.logger.Printf("Saving %d items to playlist %s", len(upcoming), playlistName)
 		response, err = q.ui.connection.CreatePlaylist("", playlistName, songIds)
 	} else {
-		q.logger.Printf("Replacing playlist %s with %d", playlistId, len(q.queueData.playerQueue))
+		q.logger.Printf("Replacing playlist %s with %d", playlistId, len(upcoming))
 		response, err = q.ui.connection.CreatePlaylist(playlistId, "", songIds)
 	}
 	if err != nil {
-		message := fmt.Sprintf("Error saving queue: %s", err)
-		q.ui.showMessageBox(message)
-		q.logger.Print(message)
+		q.ui.showErrorMessage("Saving queue", err)
 	} else {
 		if playlistId != "" {
 			for i, pl := range q.ui.playlists {
@@ -392,19 +559,20 @@ func (q *QueuePage) saveQueue(playlistName string) {
 	}
 }
 
-// shuffle randomly shuffles entries in the queue, updates it, and moves
-// the selected-item to the new first entry.
+// shuffle toggles shuffle mode, the playing song keeps playing
 func (q *QueuePage) shuffle() {
-	if len(q.queueData.playerQueue) == 0 {
+	if err := q.ui.player.SetShuffle(!q.ui.player.GetShuffle()); err != nil {
+		q.ui.showErrorMessage("Shuffle", err)
 		return
 	}
 
-	// An error here won't affect re-arranging the queue.
-	_ = q.ui.player.Stop()
-	q.ui.player.Shuffle()
-
-	q.queueList.Select(0, 0)
 	q.updateQueue()
+	q.queueList.Select(q.queueData.position, 0)
+}
+
+// upcoming returns the current item and the ones after it
+func (q *queueData) upcoming() mpvplayer.PlayerQueue {
+	return q.playerQueue[q.position:]
 }
 
 // queueData methods, used by tview to lazily render the table
@@ -413,6 +581,11 @@ func (q *queueData) GetCell(row, column int) *tview.TableCell {
 		return nil
 	}
 	song := q.playerQueue[row]
+	// played items are greyed out
+	textColor := tcell.ColorDefault
+	if row < q.position {
+		textColor = tcell.ColorGray
+	}
 
 	switch column {
 	case 0: // star
@@ -422,6 +595,9 @@ func (q *queueData) GetCell(row, column int) *tview.TableCell {
 			text = starIcon
 			color = tcell.ColorRed
 		}
+		if textColor != tcell.ColorDefault {
+			color = textColor
+		}
 		return &tview.TableCell{
 			Text:        text,
 			Color:       color,
@@ -432,20 +608,42 @@ func (q *queueData) GetCell(row, column int) *tview.TableCell {
 	case 1: // title
 		return &tview.TableCell{
 			Text:        tview.Escape(song.Title),
+			Color:       textColor,
 			Expansion:   1,
 			Transparent: true,
 		}
 	case 2: // artist
 		return &tview.TableCell{
 			Text:        tview.Escape(song.Artist),
+			Color:       textColor,
 			Expansion:   1,
 			Transparent: true,
 		}
-	case 3: // duration
+	case 3: // rating
+		text := ""
+		if song.IsSong() {
+			text = formatRating(q.ratings[song.Id])
+		}
+		color := tcell.ColorYellow
+		if textColor != tcell.ColorDefault {
+			color = textColor
+		}
+		return &tview.TableCell{
+			Text:        text,
+			Color:       color,
+			Expansion:   0,
+			MaxWidth:    5,
+			Transparent: true,
+		}
+	case 4: // duration
 		min, sec := iSecondsToMinAndSec(song.Duration)
 		text := fmt.Sprintf("%3d:%02d", min, sec)
+		if song.Kind == mpvplayer.KindRadio {
+			text = "radio"
+		}
 		return &tview.TableCell{
 			Text:        text,
+			Color:       textColor,
 			Align:       tview.AlignRight,
 			Expansion:   0,
 			MaxWidth:    6,
@@ -0,0 +1,250 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+type RadioPage struct {
+	Root               *tview.Flex
+	StationModal       tview.Primitive
+	DeleteStationModal *tview.Modal
+
+	stationList *tview.List
+	stationForm *tview.Form
+
+	stations []subsonic.InternetRadioStation
+	loaded   bool
+	// id of the station being edited, empty when adding a new one
+	editingId string
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createRadioPage() *RadioPage {
+	radioPage := RadioPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	radioPage.stationList = tview.NewList().
+		ShowSecondaryText(false)
+	radioPage.stationList.Box.
+		SetTitle(" radio ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	radioPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(radioPage.stationList, 0, 1, true)
+
+	radioPage.stationList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			radioPage.handlePlayStation()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			radioPage.handleAddStationToQueue()
+			return nil
+		case 'n':
+			radioPage.showStationForm(nil)
+			return nil
+		case 'e':
+			if station, ok := radioPage.getSelectedStation(); ok {
+				radioPage.showStationForm(&station)
+			}
+			return nil
+		case 'd':
+			if station, ok := radioPage.getSelectedStation(); ok {
+				radioPage.DeleteStationModal.SetText(fmt.Sprintf("Delete station %s?", station.Name))
+				ui.pages.ShowPage(PageDeleteRadioStation)
+				ui.app.SetFocus(radioPage.DeleteStationModal)
+			}
+			return nil
+		case 'R':
+			radioPage.loadStations()
+			return nil
+		}
+
+		return event
+	})
+
+	// "new/edit station" modal
+	radioPage.stationForm = tview.NewForm().
+		AddInputField("Name", "", 50, nil, nil).
+		AddInputField("Stream URL", "", 50, nil, nil).
+		AddInputField("Homepage URL", "", 50, nil, nil).
+		AddButton("Save", radioPage.saveStation).
+		AddButton("Cancel", radioPage.hideStationForm)
+	radioPage.stationForm.SetCancelFunc(radioPage.hideStationForm)
+	radioPage.stationForm.SetBorder(true)
+
+	radioPage.StationModal = makeModal(radioPage.stationForm, 70, 11)
+
+	// delete confirmation
+	radioPage.DeleteStationModal = tview.NewModal().
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			ui.pages.HidePage(PageDeleteRadioStation)
+			ui.app.SetFocus(radioPage.stationList)
+			if buttonLabel == "Delete" {
+				radioPage.deleteStation()
+			}
+		})
+
+	return &radioPage
+}
+
+// Load is called when the page is shown, stations are only fetched the first time.
+func (r *RadioPage) Load() {
+	if !r.loaded {
+		r.loadStations()
+	}
+}
+
+func (r *RadioPage) loadStations() {
+	response, err := r.ui.connection.GetInternetRadioStations()
+	if err != nil {
+		r.logger.PrintError("GetInternetRadioStations", err)
+		return
+	}
+	r.loaded = true
+
+	current := r.stationList.GetCurrentItem()
+	r.stations = response.InternetRadioStations.InternetRadioStation
+	r.stationList.Clear()
+	for _, station := range r.stations {
+		r.stationList.AddItem(formatStationForList(station), "", 0, nil)
+	}
+	if current < r.stationList.GetItemCount() {
+		r.stationList.SetCurrentItem(current)
+	}
+}
+
+func (r *RadioPage) getSelectedStation() (subsonic.InternetRadioStation, bool) {
+	index := r.stationList.GetCurrentItem()
+	if index < 0 || index >= len(r.stations) {
+		return subsonic.InternetRadioStation{}, false
+	}
+	return r.stations[index], true
+}
+
+func (r *RadioPage) handlePlayStation() {
+	station, ok := r.getSelectedStation()
+	if !ok {
+		return
+	}
+
+	if err := r.ui.player.PlayItem(makeRadioQueueItem(station)); err != nil {
+		r.logger.PrintError("handlePlayStation", err)
+		return
+	}
+	r.ui.queuePage.UpdateQueue()
+}
+
+func (r *RadioPage) handleAddStationToQueue() {
+	station, ok := r.getSelectedStation()
+	if !ok {
+		return
+	}
+
+	item := makeRadioQueueItem(station)
+	r.ui.player.AddToQueue(&item)
+	r.ui.queuePage.UpdateQueue()
+}
+
+// showStationForm opens the modal to edit station, or to add a new station if
+// it's nil
+func (r *RadioPage) showStationForm(station *subsonic.InternetRadioStation) {
+	name, streamUrl, homePageUrl := "", "", ""
+	r.editingId = ""
+	if station != nil {
+		r.editingId = station.Id
+		name, streamUrl, homePageUrl = station.Name, station.StreamUrl, station.HomePageUrl
+		r.stationForm.SetTitle(" Edit radio station ")
+	} else {
+		r.stationForm.SetTitle(" New radio station ")
+	}
+
+	r.stationForm.GetFormItem(0).(*tview.InputField).SetText(name)
+	r.stationForm.GetFormItem(1).(*tview.InputField).SetText(streamUrl)
+	r.stationForm.GetFormItem(2).(*tview.InputField).SetText(homePageUrl)
+	r.stationForm.SetFocus(0)
+
+	r.ui.pages.ShowPage(PageRadioStation)
+	r.ui.app.SetFocus(r.stationForm)
+}
+
+func (r *RadioPage) hideStationForm() {
+	r.ui.pages.HidePage(PageRadioStation)
+	r.ui.app.SetFocus(r.stationList)
+}
+
+func (r *RadioPage) saveStation() {
+	name := strings.TrimSpace(r.stationForm.GetFormItem(0).(*tview.InputField).GetText())
+	streamUrl := strings.TrimSpace(r.stationForm.GetFormItem(1).(*tview.InputField).GetText())
+	homePageUrl := strings.TrimSpace(r.stationForm.GetFormItem(2).(*tview.InputField).GetText())
+
+	if name == "" || streamUrl == "" {
+		r.stationForm.SetTitle(" Name and stream URL are required ")
+		return
+	}
+
+	r.hideStationForm()
+
+	var err error
+	if r.editingId == "" {
+		_, err = r.ui.connection.CreateInternetRadioStation(streamUrl, name, homePageUrl)
+	} else {
+		_, err = r.ui.connection.UpdateInternetRadioStation(r.editingId, streamUrl, name, homePageUrl)
+	}
+	if err != nil {
+		r.ui.showErrorMessage("Saving radio station", err)
+		return
+	}
+
+	r.loadStations()
+}
+
+func (r *RadioPage) deleteStation() {
+	station, ok := r.getSelectedStation()
+	if !ok {
+		return
+	}
+
+	if _, err := r.ui.connection.DeleteInternetRadioStation(station.Id); err != nil {
+		r.ui.showErrorMessage("Deleting radio station", err)
+		return
+	}
+
+	r.loadStations()
+}
+
+func makeRadioQueueItem(station subsonic.InternetRadioStation) mpvplayer.QueueItem {
+	return mpvplayer.QueueItem{
+		Id:    station.Id,
+		Uri:   station.StreamUrl,
+		Title: station.Name,
+		Kind:  mpvplayer.KindRadio,
+	}
+}
+
+func formatStationForList(station subsonic.InternetRadioStation) (text string) {
+	text = tview.Escape(station.Name)
+	if station.HomePageUrl != "" {
+		text += " [gray]" + tview.Escape(station.HomePageUrl) + "[white]"
+	}
+	return
+}
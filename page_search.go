@@ -123,7 +123,7 @@ func (ui *Ui) createSearchPage() *SearchPage {
 			return nil
 		case tcell.KeyEnter:
 			idx := searchPage.albumList.GetCurrentItem()
-			searchPage.addAlbumToQueue(searchPage.albums[idx])
+			ui.addAlbumToQueue(searchPage.albums[idx])
 			return nil
 		}
 
@@ -131,7 +131,7 @@ func (ui *Ui) createSearchPage() *SearchPage {
 		case 'a':
 			idx := searchPage.albumList.GetCurrentItem()
 			searchPage.logger.Printf("albumList adding (%d) %s", idx, searchPage.albums[idx].Name)
-			searchPage.addAlbumToQueue(searchPage.albums[idx])
+			ui.addAlbumToQueue(searchPage.albums[idx])
 			return nil
 		case '/':
 			searchPage.ui.app.SetFocus(searchPage.searchField)
@@ -270,19 +270,6 @@ func (s *SearchPage) addArtistToQueue(entity subsonic.Ider) {
 	s.ui.queuePage.UpdateQueue()
 }
 
-func (s *SearchPage) addAlbumToQueue(entity subsonic.Ider) {
-	response, err := s.ui.connection.GetAlbum(entity.ID())
-	if err != nil {
-		s.logger.Printf("addToQueue: GetMusicDirectory %s -- %s", entity.ID(), err.Error())
-		return
-	}
-	sort.Sort(response.Album.Song)
-	for _, e := range response.Album.Song {
-		s.ui.addSongToQueue(&e)
-	}
-	s.ui.queuePage.UpdateQueue()
-}
-
 func (s *SearchPage) aproposFocus() {
 	if len(s.artists) != 0 {
 		s.ui.app.SetFocus(s.artistList)
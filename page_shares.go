@@ -0,0 +1,161 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+type SharesPage struct {
+	Root             *tview.Flex
+	DeleteShareModal *tview.Modal
+
+	shareList *tview.List
+
+	shares []subsonic.Share
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createSharesPage() *SharesPage {
+	sharesPage := SharesPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	sharesPage.shareList = tview.NewList()
+	sharesPage.shareList.Box.
+		SetTitle(" shares ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	sharesPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(sharesPage.shareList, 0, 1, true)
+
+	sharesPage.shareList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter {
+			sharesPage.handleShowUrl()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'c':
+			sharesPage.handleShowUrl()
+			return nil
+		case 'e':
+			if share, ok := sharesPage.getSelectedShare(); ok {
+				ui.shareWidget.ShowEdit(share)
+			}
+			return nil
+		case 'd':
+			if share, ok := sharesPage.getSelectedShare(); ok {
+				sharesPage.DeleteShareModal.SetText(fmt.Sprintf("Revoke share %s?\nThe link stops working.", share.Url))
+				ui.pages.ShowPage(PageDeleteShare)
+				ui.app.SetFocus(sharesPage.DeleteShareModal)
+			}
+			return nil
+		case 'R':
+			sharesPage.Load()
+			return nil
+		}
+
+		return event
+	})
+
+	// revoke confirmation
+	sharesPage.DeleteShareModal = tview.NewModal().
+		AddButtons([]string{"Revoke", "Cancel"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			ui.pages.HidePage(PageDeleteShare)
+			ui.app.SetFocus(sharesPage.shareList)
+			if buttonLabel == "Revoke" {
+				sharesPage.deleteShare()
+			}
+		})
+
+	return &sharesPage
+}
+
+// Load is called when the page is shown. Shares are created on the other
+// pages, so they are fetched every time.
+func (s *SharesPage) Load() {
+	response, err := s.ui.connection.GetShares()
+	if err != nil {
+		s.logger.PrintError("GetShares", err)
+		return
+	}
+	s.shares = response.Shares.Share
+
+	current := s.shareList.GetCurrentItem()
+	s.shareList.Clear()
+	for _, share := range s.shares {
+		s.shareList.AddItem(formatShareForList(share), "  [gray]"+tview.Escape(share.Url), 0, nil)
+	}
+	if current < s.shareList.GetItemCount() {
+		s.shareList.SetCurrentItem(current)
+	}
+}
+
+func (s *SharesPage) getSelectedShare() (subsonic.Share, bool) {
+	index := s.shareList.GetCurrentItem()
+	if index < 0 || index >= len(s.shares) {
+		return subsonic.Share{}, false
+	}
+	return s.shares[index], true
+}
+
+func (s *SharesPage) handleShowUrl() {
+	if share, ok := s.getSelectedShare(); ok {
+		s.ui.shareWidget.ShowUrl(share.Url)
+	}
+}
+
+func (s *SharesPage) deleteShare() {
+	share, ok := s.getSelectedShare()
+	if !ok {
+		return
+	}
+
+	if _, err := s.ui.connection.DeleteShare(share.Id); err != nil {
+		s.ui.showErrorMessage("Revoking share", err)
+		return
+	}
+
+	s.Load()
+}
+
+func formatShareForList(share subsonic.Share) (text string) {
+	if share.Description != "" {
+		text = tview.Escape(share.Description)
+	} else if len(share.Entry) > 0 {
+		text = tview.Escape(share.Entry[0].GetSongTitle())
+		if len(share.Entry) > 1 {
+			text += fmt.Sprintf(" [gray]and %d more[white]", len(share.Entry)-1)
+		}
+	} else {
+		text = "[gray]no description[white]"
+	}
+
+	if share.Expires != "" {
+		text += " [gray]expires " + tview.Escape(formatShareDate(share.Expires)) + "[white]"
+	}
+	text += fmt.Sprintf(" [gray](%d visits)[white]", share.VisitCount)
+	return
+}
+
+// formatShareDate shortens the timestamps of the server to the date
+func formatShareDate(date string) string {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t.Local().Format(time.DateOnly)
+	}
+	return date
+}
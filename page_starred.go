@@ -0,0 +1,329 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/logger"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+type StarredPage struct {
+	Root *tview.Flex
+
+	artistList *tview.List
+	albumList  *tview.List
+	songList   *tview.List
+
+	artists []subsonic.Artist
+	albums  []subsonic.Album
+	songs   []subsonic.SubsonicEntity
+
+	// artists and albums are organized by ID3 tags (getStarred2), otherwise
+	// their ids are folder ids
+	id3 bool
+
+	// external refs
+	ui     *Ui
+	logger logger.LoggerInterface
+}
+
+func (ui *Ui) createStarredPage() *StarredPage {
+	starredPage := StarredPage{
+		ui:     ui,
+		logger: ui.logger,
+	}
+
+	// artist list
+	starredPage.artistList = tview.NewList().
+		ShowSecondaryText(false)
+	starredPage.artistList.Box.
+		SetTitle(" artist ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	// album list
+	starredPage.albumList = tview.NewList().
+		ShowSecondaryText(false)
+	starredPage.albumList.Box.
+		SetTitle(" album ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	// song list
+	starredPage.songList = tview.NewList().
+		ShowSecondaryText(false)
+	starredPage.songList.Box.
+		SetTitle(" song ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	starredPage.Root = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(starredPage.artistList, 0, 1, true).
+		AddItem(starredPage.albumList, 0, 1, false).
+		AddItem(starredPage.songList, 0, 1, false)
+
+	starredPage.artistList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(starredPage.songList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(starredPage.albumList)
+			return nil
+		case tcell.KeyEnter:
+			starredPage.handleAddArtistToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			starredPage.handleAddArtistToQueue()
+			return nil
+		case 'y':
+			starredPage.handleUnstarArtist()
+			return nil
+		case 'R':
+			starredPage.loadStarred()
+			return nil
+		}
+
+		return event
+	})
+	starredPage.albumList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(starredPage.artistList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(starredPage.songList)
+			return nil
+		case tcell.KeyEnter:
+			starredPage.handleAddAlbumToQueue()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			starredPage.handleAddAlbumToQueue()
+			return nil
+		case 'y':
+			starredPage.handleUnstarAlbum()
+			return nil
+		case 'R':
+			starredPage.loadStarred()
+			return nil
+		}
+
+		return event
+	})
+	starredPage.songList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			ui.app.SetFocus(starredPage.albumList)
+			return nil
+		case tcell.KeyRight:
+			ui.app.SetFocus(starredPage.artistList)
+			return nil
+		case tcell.KeyEnter:
+			starredPage.handlePlaySong()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'a':
+			starredPage.handleAddSongToQueue()
+			return nil
+		case 'y':
+			starredPage.handleUnstarSong()
+			return nil
+		case 'R':
+			starredPage.loadStarred()
+			return nil
+		}
+
+		return event
+	})
+
+	return &starredPage
+}
+
+// Load is called when the page is shown. Stars may have been changed on the
+// other pages, so they are fetched every time.
+func (s *StarredPage) Load() {
+	s.loadStarred()
+}
+
+func (s *StarredPage) loadStarred() {
+	// getStarred2 was added in API version 1.8.0
+	var results subsonic.SubsonicResults
+	s.id3 = s.ui.connection.SupportsApiVersion("1.8.0")
+	if s.id3 {
+		response, err := s.ui.connection.GetStarred2()
+		if err != nil {
+			s.logger.PrintError("GetStarred2", err)
+			return
+		}
+		results = response.Starred2
+	} else {
+		response, err := s.ui.connection.GetStarred()
+		if err != nil {
+			s.logger.PrintError("GetStarred", err)
+			return
+		}
+		results = response.Starred
+	}
+
+	s.artists = results.Artist
+	s.albums = results.Album
+	s.songs = results.Song
+	s.updateLists()
+}
+
+func (s *StarredPage) updateLists() {
+	updateList(s.artistList, len(s.artists), func(i int) string {
+		return tview.Escape(s.artists[i].Name)
+	})
+	updateList(s.albumList, len(s.albums), func(i int) string {
+		return formatAlbumForList(s.albums[i])
+	})
+	updateList(s.songList, len(s.songs), func(i int) string {
+		song := s.songs[i]
+		text := tview.Escape(song.GetSongTitle())
+		if song.Artist != "" {
+			text += " [gray]" + tview.Escape(song.Artist) + "[white]"
+		}
+		return text
+	})
+}
+
+// updateList fills list with count items, keeping the selection if possible
+func updateList(list *tview.List, count int, text func(int) string) {
+	current := list.GetCurrentItem()
+	list.Clear()
+	for i := 0; i < count; i++ {
+		list.AddItem(text(i), "", 0, nil)
+	}
+	if current < count {
+		list.SetCurrentItem(current)
+	}
+}
+
+func (s *StarredPage) handleAddArtistToQueue() {
+	index := s.artistList.GetCurrentItem()
+	if index < 0 || index >= len(s.artists) {
+		return
+	}
+
+	artist := s.artists[index]
+	if s.id3 {
+		s.ui.searchPage.addArtistToQueue(artist)
+	} else {
+		s.ui.browserPage.addDirectoryToQueue(&subsonic.SubsonicEntity{Id: artist.Id, IsDirectory: true})
+		s.ui.queuePage.UpdateQueue()
+	}
+}
+
+func (s *StarredPage) handleAddAlbumToQueue() {
+	index := s.albumList.GetCurrentItem()
+	if index < 0 || index >= len(s.albums) {
+		return
+	}
+
+	album := s.albums[index]
+	if s.id3 {
+		s.ui.addAlbumToQueue(album)
+	} else {
+		s.ui.browserPage.addDirectoryToQueue(&subsonic.SubsonicEntity{Id: album.Id, IsDirectory: true})
+		s.ui.queuePage.UpdateQueue()
+	}
+}
+
+func (s *StarredPage) handlePlaySong() {
+	index := s.songList.GetCurrentItem()
+	if index < 0 || index >= len(s.songs) {
+		return
+	}
+
+	makeSongHandler(&s.songs[index], s.ui, "")()
+}
+
+func (s *StarredPage) handleAddSongToQueue() {
+	index := s.songList.GetCurrentItem()
+	if index < 0 || index >= len(s.songs) {
+		return
+	}
+
+	s.ui.addSongToQueue(&s.songs[index])
+	s.ui.queuePage.UpdateQueue()
+}
+
+func (s *StarredPage) handleUnstarArtist() {
+	index := s.artistList.GetCurrentItem()
+	if index < 0 || index >= len(s.artists) {
+		return
+	}
+
+	id := s.artists[index].Id
+	var err error
+	if s.id3 {
+		_, err = s.ui.connection.Unstar("", "", id)
+	} else {
+		_, err = s.ui.connection.Unstar(id, "", "")
+	}
+	if err != nil {
+		s.ui.showErrorMessage("Removing star", err)
+		return
+	}
+
+	s.artists = append(s.artists[:index], s.artists[index+1:]...)
+	s.unstarred(id)
+}
+
+func (s *StarredPage) handleUnstarAlbum() {
+	index := s.albumList.GetCurrentItem()
+	if index < 0 || index >= len(s.albums) {
+		return
+	}
+
+	id := s.albums[index].Id
+	var err error
+	if s.id3 {
+		_, err = s.ui.connection.Unstar("", id, "")
+	} else {
+		_, err = s.ui.connection.Unstar(id, "", "")
+	}
+	if err != nil {
+		s.ui.showErrorMessage("Removing star", err)
+		return
+	}
+
+	s.albums = append(s.albums[:index], s.albums[index+1:]...)
+	s.unstarred(id)
+}
+
+func (s *StarredPage) handleUnstarSong() {
+	index := s.songList.GetCurrentItem()
+	if index < 0 || index >= len(s.songs) {
+		return
+	}
+
+	id := s.songs[index].Id
+	if _, err := s.ui.connection.Unstar(id, "", ""); err != nil {
+		s.ui.showErrorMessage("Removing star", err)
+		return
+	}
+
+	s.songs = append(s.songs[:index], s.songs[index+1:]...)
+	s.unstarred(id)
+}
+
+// unstarred updates the other pages after the star was removed from id
+func (s *StarredPage) unstarred(id string) {
+	delete(s.ui.starIdList, id)
+	s.updateLists()
+	s.ui.queuePage.UpdateQueue()
+	s.ui.browserPage.UpdateStars()
+}
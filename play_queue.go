@@ -0,0 +1,151 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spezifisch/stmps/mpvplayer"
+)
+
+// savedShuffle is the shuffled order of the play queue saved on the server,
+// which only keeps the songs in the order they were queued in. It's stored
+// locally.
+type savedShuffle struct {
+	Ids         []string `json:"ids"`
+	Seed        int64    `json:"seed"`
+	Permutation []int    `json:"permutation"`
+}
+
+func shuffleStatePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "stmps", "shuffle.json"), nil
+}
+
+// songsOnly drops everything the server can't store from a shuffle state
+func songsOnly(state mpvplayer.ShuffleState) savedShuffle {
+	saved := savedShuffle{Seed: state.Seed}
+	// index of each item of state.Order in saved.Ids, -1 if it's dropped
+	index := make([]int, len(state.Order))
+	for i, item := range state.Order {
+		index[i] = -1
+		if item.IsSong() {
+			index[i] = len(saved.Ids)
+			saved.Ids = append(saved.Ids, item.Id)
+		}
+	}
+	for _, i := range state.Permutation {
+		if index[i] >= 0 {
+			saved.Permutation = append(saved.Permutation, index[i])
+		}
+	}
+	return saved
+}
+
+// savePlayQueue saves the songs of the queue on the server, the shuffled
+// order is saved locally. Played songs aren't saved.
+func (ui *Ui) savePlayQueue() {
+	if ui.connection.IsOffline() {
+		// can't be saved, the server keeps the previous play queue
+		return
+	}
+
+	// the server only stores songs, radio stations and podcasts are dropped
+	upcoming := ui.queuePage.queueData.upcoming()
+	ids := make([]string, 0, len(upcoming))
+	for _, it := range upcoming {
+		if it.IsSong() {
+			ids = append(ids, it.Id)
+		}
+	}
+
+	state, shuffled := ui.player.GetShuffleState()
+	saved := songsOnly(state)
+	if len(ids) > 0 {
+		// stmps always only ever plays the first song in the queue
+		pos := 0.0
+		if upcoming[0].IsSong() {
+			pos = ui.player.GetTimePos()
+		}
+		current := ids[0]
+		if shuffled {
+			// other clients get the order the songs were queued in
+			ids = saved.Ids
+		}
+		if err := ui.connection.SavePlayQueue(ids, current, int(pos)); err != nil {
+			log.Printf("error stashing play queue: %s", err)
+		}
+	} else {
+		// The only way to purge a saved play queue is to force an error by providing
+		// bad data. Therefore, we ignore errors.
+		_ = ui.connection.SavePlayQueue([]string{"XXX"}, "XXX", 0)
+		shuffled = false
+	}
+
+	if err := saveShuffle(saved, shuffled); err != nil {
+		ui.logger.PrintError("saving shuffled order", err)
+	}
+}
+
+// saveShuffle stores saved, or removes what was stored if the queue isn't
+// shuffled
+func saveShuffle(saved savedShuffle, shuffled bool) error {
+	path, err := shuffleStatePath()
+	if err != nil {
+		return err
+	}
+	if !shuffled {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// restoreShuffle shuffles the queue like it was when the play queue with the
+// songs ids was saved. The queue must hold exactly these songs.
+func (ui *Ui) restoreShuffle(ids []string) {
+	path, err := shuffleStatePath()
+	if err != nil {
+		ui.logger.PrintError("restoring shuffled order", err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	} else if err != nil {
+		ui.logger.PrintError("restoring shuffled order", err)
+		return
+	}
+
+	var saved savedShuffle
+	if err := json.Unmarshal(data, &saved); err != nil {
+		ui.logger.PrintError("restoring shuffled order", err)
+		return
+	}
+	if !slices.Equal(saved.Ids, ids) {
+		// another client saved a different play queue since
+		return
+	}
+	if err := ui.player.RestoreShuffle(saved.Seed, saved.Permutation); err != nil {
+		ui.logger.PrintError("restoring shuffled order", err)
+	}
+}
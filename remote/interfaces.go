@@ -33,8 +33,22 @@ type ControlledPlayer interface {
 	PreviousTrack() error
 
 	SetVolume(percentValue int) error
+
+	// see the LoopStatus constants
+	GetLoopStatus() string
+	SetLoopStatus(status string) error
+
+	GetShuffle() bool
+	SetShuffle(enabled bool) error
 }
 
+// values of the MPRIS LoopStatus property
+const (
+	LoopStatusNone     = "None"
+	LoopStatusTrack    = "Track"
+	LoopStatusPlaylist = "Playlist"
+)
+
 type TrackInterface interface {
 	GetId() string
 	GetArtist() string
@@ -15,6 +15,7 @@ import (
 
 type MprisPlayer struct {
 	dbus   *dbus.Conn
+	props  *prop.Properties
 	player ControlledPlayer
 	logger logger.LoggerInterface
 
@@ -50,10 +51,12 @@ func RegisterMprisPlayer(player ControlledPlayer, logger_ logger.LoggerInterface
 		"CanPause":       {Value: true, Writable: false, Emit: prop.EmitFalse, Callback: nil},
 		"CanPlay":        {Value: true, Writable: false, Emit: prop.EmitFalse, Callback: nil},
 		"CanSeek":        {Value: false, Writable: false, Emit: prop.EmitFalse, Callback: nil},
-		"CanGoPrevious":  {Value: false, Writable: false, Emit: prop.EmitFalse, Callback: nil},
+		"CanGoPrevious":  {Value: true, Writable: false, Emit: prop.EmitFalse, Callback: nil},
 		"Metadata":       {Value: mpp.metadata, Writable: false, Emit: prop.EmitTrue, Callback: nil},
 		"Volume":         {Value: float64(0.0), Writable: true, Emit: prop.EmitTrue, Callback: mpp.volumeChange},
 		"PlaybackStatus": {Value: "", Writable: false, Emit: prop.EmitFalse, Callback: nil},
+		"LoopStatus":     {Value: player.GetLoopStatus(), Writable: true, Emit: prop.EmitTrue, Callback: mpp.loopStatusChange},
+		"Shuffle":        {Value: player.GetShuffle(), Writable: true, Emit: prop.EmitTrue, Callback: mpp.shuffleChange},
 	}
 
 	var mediaPlayer = map[string]*prop.Prop{
@@ -66,7 +69,7 @@ func RegisterMprisPlayer(player ControlledPlayer, logger_ logger.LoggerInterface
 		"SupportedMimeTypes":  {Value: []string{}, Writable: false, Emit: prop.EmitFalse, Callback: nil},
 	}
 
-	props, err := prop.Export(
+	mpp.props, err = prop.Export(
 		conn,
 		"/org/mpris/MediaPlayer2",
 		map[string]map[string]*prop.Prop{
@@ -116,12 +119,12 @@ func RegisterMprisPlayer(player ControlledPlayer, logger_ logger.LoggerInterface
 						},
 					},
 				},
-				Properties: props.Introspection("org.mpris.MediaPlayer2.Player"), // we implement the standard interface
+				Properties: mpp.props.Introspection("org.mpris.MediaPlayer2.Player"), // we implement the standard interface
 			},
 			{
 				Name:       "org.mpris.MediaPlayer2",
 				Methods:    []introspect.Method{},
-				Properties: props.Introspection("org.mpris.MediaPlayer2"),
+				Properties: mpp.props.Introspection("org.mpris.MediaPlayer2"),
 			},
 		},
 	}
@@ -221,7 +224,10 @@ func (m *MprisPlayer) PlayPause() *dbus.Error {
 }
 
 func (m *MprisPlayer) Previous() *dbus.Error {
-	// TODO not implemented
+	if err := m.player.PreviousTrack(); err != nil {
+		m.logger.PrintError("mpp Previous", err)
+		return dbus.MakeFailedError(err)
+	}
 	return nil
 }
 
@@ -249,6 +255,31 @@ func (m *MprisPlayer) volumeChange(c *prop.Change) *dbus.Error {
 	return nil
 }
 
+func (m *MprisPlayer) loopStatusChange(c *prop.Change) *dbus.Error {
+	status := c.Value.(string)
+	if err := m.player.SetLoopStatus(status); err != nil {
+		m.logger.PrintError("loopStatusChange", err)
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (m *MprisPlayer) shuffleChange(c *prop.Change) *dbus.Error {
+	shuffle := c.Value.(bool)
+	if err := m.player.SetShuffle(shuffle); err != nil {
+		m.logger.PrintError("shuffleChange", err)
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// OnModeChange method to be called by eventLoop when the repeat mode or
+// shuffle changed
+func (m *MprisPlayer) OnModeChange(loopStatus string, shuffle bool) {
+	m.props.SetMust("org.mpris.MediaPlayer2.Player", "LoopStatus", loopStatus)
+	m.props.SetMust("org.mpris.MediaPlayer2.Player", "Shuffle", shuffle)
+}
+
 // OnSongChange method to be called by eventLoop
 func (m *MprisPlayer) OnSongChange(currentSong TrackInterface) {
 	m.metadata["mpris:trackid"] = "/org/mpris/MediaPlayer2/track/" + currentSong.GetId()
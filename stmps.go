@@ -4,15 +4,19 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
 
+	"github.com/spezifisch/stmps/downloads"
+	"github.com/spezifisch/stmps/jukebox"
 	"github.com/spezifisch/stmps/logger"
 	"github.com/spezifisch/stmps/mpvplayer"
 	"github.com/spezifisch/stmps/remote"
@@ -26,10 +30,13 @@ var headlessMode bool // This can be set to true during tests
 var testMode bool     // This can be set to true during tests, too
 const DEVELOPMENT = "development"
 
+// size limit of the download cache in MiB
+const defaultDownloadsMaxSize = 4096
+
 var Version string = DEVELOPMENT
 
 func readConfig(configFile *string) error {
-	required_properties := []string{"auth.username", "auth.password", "server.host"}
+	required_properties := []string{"server.host"}
 
 	if configFile != nil && *configFile != "" {
 		// use custom config file
@@ -55,6 +62,14 @@ func readConfig(configFile *string) error {
 			return fmt.Errorf("Config property %s is required\n", prop)
 		}
 	}
+	// either an OpenSubsonic API key or username and password are needed
+	if !viper.IsSet("auth.api-key") {
+		for _, prop := range []string{"auth.username", "auth.password"} {
+			if !viper.IsSet(prop) {
+				return fmt.Errorf("Config property %s is required\n", prop)
+			}
+		}
+	}
 
 	return nil
 }
@@ -99,6 +114,25 @@ func initCommandHandler(logger *logger.Logger) {
 	//keybinding.RegisterCommands(env)
 }
 
+// newDownloadManager uses downloads.directory as cache directory, it defaults
+// to the user's cache directory. downloads.max-size is in MiB.
+func newDownloadManager(connection *subsonic.SubsonicConnection, logger logger.LoggerInterface) (*downloads.Manager, error) {
+	dir := viper.GetString("downloads.directory")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cacheDir, "stmps", "downloads")
+	}
+
+	maxSize := int64(defaultDownloadsMaxSize)
+	if viper.IsSet("downloads.max-size") {
+		maxSize = viper.GetInt64("downloads.max-size")
+	}
+	return downloads.NewManager(dir, maxSize*1024*1024, connection, logger)
+}
+
 // return codes:
 // 0 - OK
 // 1 - generic errors
@@ -161,11 +195,13 @@ func main() {
 	initCommandHandler(logger)
 
 	// init mpv engine
-	player, err := mpvplayer.NewPlayer(logger)
+	mpvPlayer, err := mpvplayer.NewPlayer(logger)
 	if err != nil {
 		fmt.Println("Unable to initialize mpv. Is mpv installed?")
 		osExit(1)
 	}
+	// plays locally until the user switches to the jukebox
+	player := mpvplayer.NewSwitch(mpvPlayer)
 
 	var mprisPlayer *remote.MprisPlayer
 	// init mpris2 player control (linux only but fails gracefully on other systems)
@@ -199,10 +235,70 @@ func main() {
 	connection.SetClientInfo(clientName, clientVersion)
 	connection.Username = viper.GetString("auth.username")
 	connection.Password = viper.GetString("auth.password")
+	connection.ApiKey = viper.GetString("auth.api-key")
 	connection.Host = viper.GetString("server.host")
 	connection.PlaintextAuth = viper.GetBool("auth.plaintext")
 	connection.Scrobble = viper.GetBool("server.scrobble")
 	connection.RandomSongNumber = viper.GetUint("client.random-songs")
+	if viper.IsSet("server.timeout") {
+		connection.Timeout = viper.GetDuration("server.timeout")
+	}
+
+	streamProfiles, streamProfileIndex, err := loadStreamProfiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config error: %s\n", err)
+		osExit(2)
+	}
+
+	player.AddJukebox(jukebox.NewPlayer(connection, logger))
+
+	// remember what the server sent to be able to start without it, and keep
+	// cover arts across restarts
+	if cacheDir, err := os.UserCacheDir(); err != nil {
+		logger.PrintError("UserCacheDir", err)
+	} else {
+		if err := connection.EnableSnapshot(filepath.Join(cacheDir, "stmps", "offline")); err != nil {
+			logger.PrintError("EnableSnapshot", err)
+		}
+		if err := connection.EnableCoverArtCache(filepath.Join(cacheDir, "stmps", "coverart"), subsonic.DefaultCoverArtDiskSize); err != nil {
+			logger.PrintError("EnableCoverArtCache", err)
+		}
+	}
+
+	// find out what the server supports, plain Subsonic servers don't know about extensions
+	if _, err := connection.GetOpenSubsonicExtensions(); err != nil {
+		// the server answering with an error isn't a reason to go offline
+		var apiError *subsonic.APIError
+		if errors.As(err, &apiError) {
+			logger.Printf("Server doesn't support OpenSubsonic extensions: %v", err)
+			if _, err := connection.GetServerInfo(); err != nil {
+				fmt.Printf("Error connecting to server: %s\n", err)
+				osExit(1)
+			}
+		} else if !connection.HasSnapshot() {
+			fmt.Printf("Error connecting to server: %s\n", err)
+			osExit(1)
+		} else {
+			// don't wait for a second timeout
+			fmt.Printf("Server unreachable, starting offline: %s\n", err)
+			connection.SetOffline(true)
+		}
+	}
+
+	// only show one music folder (library), given by id or name
+	if musicFolder := viper.GetString("server.music-folder"); musicFolder != "" {
+		response, err := connection.GetMusicFolders()
+		if err != nil {
+			fmt.Printf("Error fetching music folders from server: %s\n", err)
+			osExit(1)
+		}
+		folder, ok := response.MusicFolders.Find(musicFolder)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Music folder '%s' doesn't exist on the server\n", musicFolder)
+			osExit(2)
+		}
+		connection.SetMusicFolderId(string(folder.Id))
+	}
 
 	indexResponse, err := connection.GetIndexes()
 	if err != nil {
@@ -252,12 +348,29 @@ func main() {
 		return
 	}
 
+	downloadManager, err := newDownloadManager(connection, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up download cache: %s\n", err)
+		osExit(2)
+	}
+
 	ui := InitGui(&indexResponse.Indexes.Index,
 		connection,
+		streamProfiles,
+		streamProfileIndex,
 		player,
+		downloadManager,
 		logger,
 		mprisPlayer)
 
+	// start shuffled, songs are put in the same order every time they're
+	// added in the same order
+	if viper.IsSet("player.shuffle-seed") {
+		if err := player.SetShuffleSeed(viper.GetInt64("player.shuffle-seed")); err != nil {
+			logger.PrintError("SetShuffleSeed", err)
+		}
+	}
+
 	// run main loop
 	if err := ui.Run(); err != nil {
 		panic(err)
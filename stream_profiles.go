@@ -0,0 +1,108 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+	"github.com/spf13/viper"
+)
+
+// streamProfileConfig is a [[stream-profiles]] table of the config file
+type streamProfileConfig struct {
+	Name       string `mapstructure:"name"`
+	MaxBitRate int    `mapstructure:"max-bit-rate"`
+	Format     string `mapstructure:"format"`
+	TimeOffset bool   `mapstructure:"time-offset"`
+}
+
+// loadStreamProfiles reads the stream profiles from the config and returns the
+// index of the one given by client.stream-profile, it defaults to the first.
+func loadStreamProfiles() (profiles []subsonic.StreamProfile, active int, err error) {
+	var configs []streamProfileConfig
+	if err = viper.UnmarshalKey("stream-profiles", &configs); err != nil {
+		return nil, 0, err
+	}
+
+	names := map[string]int{}
+	for i, config := range configs {
+		if config.Name == "" {
+			return nil, 0, fmt.Errorf("stream profile %d has no name", i+1)
+		}
+		if _, ok := names[config.Name]; ok {
+			return nil, 0, fmt.Errorf("stream profile '%s' is defined twice", config.Name)
+		}
+		if config.MaxBitRate < 0 {
+			return nil, 0, fmt.Errorf("stream profile '%s' has a negative max-bit-rate", config.Name)
+		}
+		names[config.Name] = i
+
+		profiles = append(profiles, subsonic.StreamProfile{
+			Name:       config.Name,
+			MaxBitRate: config.MaxBitRate,
+			Format:     config.Format,
+			TimeOffset: config.TimeOffset,
+		})
+	}
+
+	if name := viper.GetString("client.stream-profile"); name != "" {
+		var ok bool
+		if active, ok = names[name]; !ok {
+			return nil, 0, fmt.Errorf("stream profile '%s' doesn't exist", name)
+		}
+	}
+	return profiles, active, nil
+}
+
+func formatStreamProfile(profile subsonic.StreamProfile) string {
+	if profile.Name == "" {
+		return ""
+	}
+	return "[gray]" + tview.Escape("["+profile.Name+"]") + "[-]"
+}
+
+// streamProfileStatusWidth fits the longest profile name into the status bar
+func streamProfileStatusWidth(profiles []subsonic.StreamProfile) (width int) {
+	for _, profile := range profiles {
+		width = max(width, len([]rune(profile.Name))+3)
+	}
+	return
+}
+
+// cycleStreamProfile switches to the next stream profile
+func (ui *Ui) cycleStreamProfile() {
+	if len(ui.streamProfiles) < 2 {
+		return
+	}
+	ui.setStreamProfile((ui.streamProfileIndex + 1) % len(ui.streamProfiles))
+}
+
+// setStreamProfile makes new and queued streams use the profile at index. The
+// playing song keeps its stream.
+func (ui *Ui) setStreamProfile(index int) {
+	profile := ui.streamProfiles[index]
+	ui.streamProfileIndex = index
+	ui.connection.StreamProfile = profile
+
+	ui.player.UpdateUris(func(item mpvplayer.QueueItem) string {
+		return ui.connection.ResolvePlayUrl(item.Uri)
+	})
+
+	ui.streamProfileStatus.SetText(formatStreamProfile(profile))
+	ui.logger.Printf("stream profile: %s", profile.Name)
+}
+
+// startQueueItemAt makes item start at position seconds. If the stream profile
+// allows it the server starts the stream there, otherwise mpv seeks.
+func (ui *Ui) startQueueItemAt(item *mpvplayer.QueueItem, position int) {
+	if position > 0 && ui.connection.IsPlayUrl(item.Uri) && ui.connection.CanTimeOffset() {
+		item.Uri = ui.connection.PlayUrlWithTimeOffset(item.Uri, position)
+		item.TimeOffset = position
+		return
+	}
+	item.StartPosition = position
+}
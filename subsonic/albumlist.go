@@ -0,0 +1,84 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// AlbumListType selects the order of albums returned by GetAlbumList
+type AlbumListType string
+
+const (
+	AlbumListRandom               AlbumListType = "random"
+	AlbumListNewest               AlbumListType = "newest"
+	AlbumListHighest              AlbumListType = "highest"
+	AlbumListFrequent             AlbumListType = "frequent"
+	AlbumListRecent               AlbumListType = "recent"
+	AlbumListAlphabeticalByName   AlbumListType = "alphabeticalByName"
+	AlbumListAlphabeticalByArtist AlbumListType = "alphabeticalByArtist"
+	AlbumListStarred              AlbumListType = "starred"
+	AlbumListByYear               AlbumListType = "byYear"
+	AlbumListByGenre              AlbumListType = "byGenre"
+)
+
+// MaxAlbumListSize is the largest page size the server accepts
+const MaxAlbumListSize = 500
+
+type AlbumList struct {
+	Album []Album `json:"album"`
+}
+
+// GetAlbumList uses getAlbumList2 to fetch a page of albums in the given order.
+// The byYear and byGenre types need additional parameters, use
+// GetAlbumListByYear and GetAlbumListByGenre for those.
+// https://www.subsonic.org/pages/api.jsp#getAlbumList2
+func (connection *SubsonicConnection) GetAlbumList(listType AlbumListType, size, offset int) (*SubsonicResponse, error) {
+	return connection.GetAlbumListContext(context.Background(), listType, size, offset)
+}
+
+func (connection *SubsonicConnection) GetAlbumListContext(ctx context.Context, listType AlbumListType, size, offset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("type", string(listType))
+	return connection.getAlbumList2(ctx, query, size, offset)
+}
+
+// GetAlbumListByYear fetches a page of albums released between fromYear and
+// toYear. If fromYear is larger than toYear, the albums are in reverse order.
+func (connection *SubsonicConnection) GetAlbumListByYear(fromYear, toYear, size, offset int) (*SubsonicResponse, error) {
+	return connection.GetAlbumListByYearContext(context.Background(), fromYear, toYear, size, offset)
+}
+
+func (connection *SubsonicConnection) GetAlbumListByYearContext(ctx context.Context, fromYear, toYear, size, offset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("type", string(AlbumListByYear))
+	query.Set("fromYear", strconv.Itoa(fromYear))
+	query.Set("toYear", strconv.Itoa(toYear))
+	return connection.getAlbumList2(ctx, query, size, offset)
+}
+
+// GetAlbumListByGenre fetches a page of albums of the given genre.
+func (connection *SubsonicConnection) GetAlbumListByGenre(genre string, size, offset int) (*SubsonicResponse, error) {
+	return connection.GetAlbumListByGenreContext(context.Background(), genre, size, offset)
+}
+
+func (connection *SubsonicConnection) GetAlbumListByGenreContext(ctx context.Context, genre string, size, offset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("type", string(AlbumListByGenre))
+	query.Set("genre", genre)
+	return connection.getAlbumList2(ctx, query, size, offset)
+}
+
+func (connection *SubsonicConnection) getAlbumList2(ctx context.Context, query url.Values, size, offset int) (*SubsonicResponse, error) {
+	if size <= 0 || size > MaxAlbumListSize {
+		size = MaxAlbumListSize
+	}
+	query.Set("size", strconv.Itoa(size))
+	query.Set("offset", strconv.Itoa(offset))
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getAlbumList2" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetAlbumList", requestUrl)
+}
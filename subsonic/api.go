@@ -4,48 +4,84 @@
 package subsonic
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"image"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/spezifisch/stmps/logger"
 )
 
+// DefaultTimeout is the time a single request may take before it is aborted,
+// unless configured otherwise.
+const DefaultTimeout = 30 * time.Second
+
 type SubsonicConnection struct {
 	Username         string
 	Password         string
+	ApiKey           string // OpenSubsonic API key, replaces Username and Password if set
 	Host             string
 	PlaintextAuth    bool
 	Scrobble         bool
 	RandomSongNumber uint
+	// StreamProfile sets the format of play urls, see ResolvePlayUrl
+	StreamProfile StreamProfile
+	// Timeout bounds every single request made to the server, 0 disables it
+	Timeout time.Duration
 
 	clientName    string
 	clientVersion string
 
+	// server capabilities, see GetOpenSubsonicExtensions
+	openSubsonic bool
+	apiVersion   string
+	extensions   map[string][]int
+
+	// see SetMusicFolderId
+	musicFolderId    string
+	musicFolderMutex sync.Mutex
+
+	client         *http.Client
 	logger         logger.LoggerInterface
 	directoryCache map[string]SubsonicResponse
-	coverArts      map[string]image.Image
+	cacheMutex     sync.Mutex
+	coverArts      coverArtCache
+
+	// offline mode, see SetOffline
+	offline      atomic.Bool
+	offlineMutex sync.Mutex
+	offlineDir   string
+	snapshot     snapshot
+	journal      []journalEntry
+	// responses were added since the snapshot was saved
+	snapshotChanged bool
+	// only one Reconnect replays the journal at a time
+	reconnectMutex    sync.Mutex
+	snapshotSaveMutex sync.Mutex
 }
 
 func Init(logger logger.LoggerInterface) *SubsonicConnection {
 	return &SubsonicConnection{
+		Timeout: DefaultTimeout,
+
 		clientName:    "example",
 		clientVersion: "1.8.0",
 
+		extensions: make(map[string][]int),
+
+		client:         &http.Client{},
 		logger:         logger,
 		directoryCache: make(map[string]SubsonicResponse),
-		coverArts:      make(map[string]image.Image),
+		coverArts:      newCoverArtCache(),
 	}
 }
 
@@ -54,24 +90,61 @@ func (s *SubsonicConnection) SetClientInfo(name, version string) {
 	s.clientVersion = version
 }
 
+// SetHttpClient replaces the client used for all requests, e.g. to use a
+// custom transport.
+func (s *SubsonicConnection) SetHttpClient(client *http.Client) {
+	s.client = client
+}
+
+func (s *SubsonicConnection) httpClient() *http.Client {
+	if s.client == nil {
+		return http.DefaultClient
+	}
+	return s.client
+}
+
 func (s *SubsonicConnection) ClearCache() {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
 	s.directoryCache = make(map[string]SubsonicResponse)
 }
 
 func (s *SubsonicConnection) RemoveCacheEntry(key string) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
 	delete(s.directoryCache, key)
 }
 
+// cachedResponse returns the cached response of the directory, artist or
+// album id, it's looked up from background goroutines too
+func (s *SubsonicConnection) cachedResponse(id string) (SubsonicResponse, bool) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	response, ok := s.directoryCache[id]
+	return response, ok
+}
+
+func (s *SubsonicConnection) cacheResponse(id string, response SubsonicResponse) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	s.directoryCache[id] = response
+}
+
 func defaultQuery(connection *SubsonicConnection) url.Values {
 	query := url.Values{}
-	if connection.PlaintextAuth {
-		query.Set("p", connection.Password)
+	if connection.ApiKey != "" {
+		// the username must not be sent along with an API key
+		query.Set("apiKey", connection.ApiKey)
 	} else {
-		token, salt := authToken(connection.Password)
-		query.Set("t", token)
-		query.Set("s", salt)
+		if connection.PlaintextAuth {
+			query.Set("p", connection.Password)
+		} else {
+			token, salt := authToken(connection.Password)
+			query.Set("t", token)
+			query.Set("s", salt)
+		}
+		query.Set("u", connection.Username)
 	}
-	query.Set("u", connection.Username)
 	query.Set("v", connection.clientVersion)
 	query.Set("c", connection.clientName)
 	query.Set("f", "json")
@@ -160,6 +233,9 @@ type Album struct {
 	Year          int              `json:"year"`
 	Song          SubsonicEntities `json:"song"`
 	CoverArt      string           `json:"coverArt"`
+	UserRating    int              `json:"userRating"`
+	AverageRating float64          `json:"averageRating"`
+	Starred       string           `json:"starred"`
 }
 
 func (s Album) ID() string {
@@ -183,6 +259,11 @@ type SubsonicEntity struct {
 	DiscNumber  int      `json:"discNumber"`
 	Path        string   `json:"path"`
 	CoverArtId  string   `json:"coverArt"`
+	// rating of the user (1-5, 0 if unrated) and of all users
+	UserRating    int     `json:"userRating"`
+	AverageRating float64 `json:"averageRating"`
+	// time the item was starred, empty if it isn't
+	Starred string `json:"starred"`
 }
 
 func (s SubsonicEntity) ID() string {
@@ -262,14 +343,23 @@ type SubsonicPlaylist struct {
 	Entries   SubsonicEntities `json:"entry"`
 }
 
+type OpenSubsonicExtension struct {
+	Name     string `json:"name"`
+	Versions []int  `json:"versions"`
+}
+
 type SubsonicResponse struct {
 	Status        string            `json:"status"`
 	Version       string            `json:"version"`
+	OpenSubsonic  bool              `json:"openSubsonic"`
+	Type          string            `json:"type"`
+	ServerVersion string            `json:"serverVersion"`
 	Indexes       SubsonicIndexes   `json:"indexes"`
 	Directory     SubsonicDirectory `json:"directory"`
 	RandomSongs   SubsonicSongs     `json:"randomSongs"`
 	SimilarSongs  SubsonicSongs     `json:"similarSongs"`
 	Starred       SubsonicResults   `json:"starred"`
+	Starred2      SubsonicResults   `json:"starred2"`
 	Playlists     SubsonicPlaylists `json:"playlists"`
 	Playlist      SubsonicPlaylist  `json:"playlist"`
 	Error         SubsonicError     `json:"error"`
@@ -278,6 +368,25 @@ type SubsonicResponse struct {
 	SearchResults SubsonicResults   `json:"searchResult3"`
 	ScanStatus    ScanStatus        `json:"scanStatus"`
 	PlayQueue     PlayQueue         `json:"playQueue"`
+	AlbumList2    AlbumList         `json:"albumList2"`
+	Genres        SubsonicGenres    `json:"genres"`
+	SongsByGenre  SubsonicSongs     `json:"songsByGenre"`
+	Lyrics        SubsonicLyrics    `json:"lyrics"`
+	LyricsList    LyricsList        `json:"lyricsList"`
+	ArtistInfo    ArtistInfo        `json:"artistInfo"`
+	ArtistInfo2   ArtistInfo        `json:"artistInfo2"`
+	AlbumInfo     AlbumInfo         `json:"albumInfo"`
+
+	InternetRadioStations  InternetRadioStations   `json:"internetRadioStations"`
+	Podcasts               Podcasts                `json:"podcasts"`
+	NewestPodcasts         NewestPodcasts          `json:"newestPodcasts"`
+	Bookmarks              Bookmarks               `json:"bookmarks"`
+	NowPlaying             NowPlaying              `json:"nowPlaying"`
+	Shares                 Shares                  `json:"shares"`
+	MusicFolders           MusicFolders            `json:"musicFolders"`
+	JukeboxStatus          JukeboxStatus           `json:"jukeboxStatus"`
+	JukeboxPlaylist        JukeboxPlaylist         `json:"jukeboxPlaylist"`
+	OpenSubsonicExtensions []OpenSubsonicExtension `json:"openSubsonicExtensions"`
 }
 
 type responseWrapper struct {
@@ -300,43 +409,72 @@ func (si *SubsonicId) UnmarshalJSON(b []byte) error {
 }
 
 // requests
+//
+// Every request has a variant taking a context.Context, which can be used to
+// cancel requests that aren't needed anymore. The variants without context
+// use context.Background(). In both cases the request is bounded by Timeout.
+
 func (connection *SubsonicConnection) GetServerInfo() (*SubsonicResponse, error) {
+	return connection.GetServerInfoContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetServerInfoContext(ctx context.Context) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	requestUrl := connection.Host + "/rest/ping" + "?" + query.Encode()
-	return connection.getResponse("GetServerInfo", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetServerInfo", requestUrl)
+	if err != nil {
+		return resp, err
+	}
+
+	connection.openSubsonic = resp.OpenSubsonic
+	connection.apiVersion = resp.Version
+	return resp, nil
 }
 
 func (connection *SubsonicConnection) GetIndexes() (*SubsonicResponse, error) {
+	return connection.GetIndexesContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetIndexesContext(ctx context.Context) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
+	connection.setMusicFolder(query)
 	requestUrl := connection.Host + "/rest/getIndexes" + "?" + query.Encode()
-	return connection.getResponse("GetIndexes", requestUrl)
+	return connection.getResponseContext(ctx, "GetIndexes", requestUrl)
 }
 
 func (connection *SubsonicConnection) GetArtist(id string) (*SubsonicResponse, error) {
-	if cachedResponse, present := connection.directoryCache[id]; present {
+	return connection.GetArtistContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetArtistContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	if cachedResponse, present := connection.cachedResponse(id); present {
 		return &cachedResponse, nil
 	}
 
 	query := defaultQuery(connection)
 	query.Set("id", id)
 	requestUrl := connection.Host + "/rest/getArtist" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetMusicDirectory", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetMusicDirectory", requestUrl)
 	if err != nil {
 		return resp, err
 	}
 
+	sort.Sort(resp.Directory.Entities)
+
 	// on a sucessful request, cache the response
 	if resp.Status == "ok" {
-		connection.directoryCache[id] = *resp
+		connection.cacheResponse(id, *resp)
 	}
 
-	sort.Sort(resp.Directory.Entities)
-
 	return resp, nil
 }
 
 func (connection *SubsonicConnection) GetAlbum(id string) (*SubsonicResponse, error) {
-	if cachedResponse, present := connection.directoryCache[id]; present {
+	return connection.GetAlbumContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetAlbumContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	if cachedResponse, present := connection.cachedResponse(id); present {
 		// This is because Albums that were fetched as Directories aren't populated correctly
 		if cachedResponse.Album.Name != "" {
 			return &cachedResponse, nil
@@ -346,129 +484,92 @@ func (connection *SubsonicConnection) GetAlbum(id string) (*SubsonicResponse, er
 	query := defaultQuery(connection)
 	query.Set("id", id)
 	requestUrl := connection.Host + "/rest/getAlbum" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetAlbum", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetAlbum", requestUrl)
 	if err != nil {
 		return resp, err
 	}
 
+	sort.Sort(resp.Directory.Entities)
+
 	// on a sucessful request, cache the response
 	if resp.Status == "ok" {
-		connection.directoryCache[id] = *resp
+		connection.cacheResponse(id, *resp)
 	}
 
-	sort.Sort(resp.Directory.Entities)
-
 	return resp, nil
 }
 
 func (connection *SubsonicConnection) GetMusicDirectory(id string) (*SubsonicResponse, error) {
-	if cachedResponse, present := connection.directoryCache[id]; present {
+	return connection.GetMusicDirectoryContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetMusicDirectoryContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	if cachedResponse, present := connection.cachedResponse(id); present {
 		return &cachedResponse, nil
 	}
 
 	query := defaultQuery(connection)
 	query.Set("id", id)
 	requestUrl := connection.Host + "/rest/getMusicDirectory" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetMusicDirectory", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetMusicDirectory", requestUrl)
 	if err != nil {
 		return resp, err
 	}
 
+	sort.Sort(resp.Directory.Entities)
+
 	// on a sucessful request, cache the response
 	if resp.Status == "ok" {
-		connection.directoryCache[id] = *resp
+		connection.cacheResponse(id, *resp)
 	}
 
-	sort.Sort(resp.Directory.Entities)
-
 	return resp, nil
 }
 
-// GetCoverArt fetches album art from the server, by ID. The results are cached,
-// so it is safe to call this function repeatedly. If id is empty, an error
-// is returned. If, for some reason, the server response can't be parsed into
-// an image, an error is returned. This function can parse GIF, JPEG, and PNG
-// images.
-func (connection *SubsonicConnection) GetCoverArt(id string) (image.Image, error) {
-	if id == "" {
-		return nil, fmt.Errorf("GetCoverArt: no ID provided")
-	}
-	if rv, ok := connection.coverArts[id]; ok {
-		return rv, nil
-	}
-	query := defaultQuery(connection)
-	query.Set("id", id)
-	query.Set("f", "image/png")
-	caller := "GetCoverArt"
-	res, err := http.Get(connection.Host + "/rest/getCoverArt" + "?" + query.Encode())
-	if err != nil {
-		return nil, fmt.Errorf("[%s] failed to make GET request: %v", caller, err)
-	}
-
-	if res.Body != nil {
-		defer res.Body.Close()
-	} else {
-		return nil, fmt.Errorf("[%s] response body is nil", caller)
-	}
-
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("[%s] unexpected status code: %d, status: %s", caller, res.StatusCode, res.Status)
-	}
-
-	if len(res.Header["Content-Type"]) == 0 {
-		return nil, fmt.Errorf("[%s] unknown image type (no content-type from server)", caller)
-	}
-	responseBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("[%s] failed to read response body: %v", caller, err)
-	}
-	var art image.Image
-	switch res.Header["Content-Type"][0] {
-	case "image/png":
-		art, err = png.Decode(bytes.NewReader(responseBody))
-	case "image/jpeg":
-		art, err = jpeg.Decode(bytes.NewReader(responseBody))
-	case "image/gif":
-		art, err = gif.Decode(bytes.NewReader(responseBody))
-	default:
-		return nil, fmt.Errorf("[%s] unhandled image type %s: %v", caller, res.Header["Content-Type"][0], err)
-	}
-	if art != nil {
-		// FIXME connection.coverArts shouldn't grow indefinitely. Add some LRU cleanup after loading a few hundred cover arts.
-		connection.coverArts[id] = art
-	}
-	return art, err
+func (connection *SubsonicConnection) GetRandomSongs(Id string, randomType string) (*SubsonicResponse, error) {
+	return connection.GetRandomSongsContext(context.Background(), Id, randomType)
 }
 
-func (connection *SubsonicConnection) GetRandomSongs(Id string, randomType string) (*SubsonicResponse, error) {
+func (connection *SubsonicConnection) GetRandomSongsContext(ctx context.Context, Id string, randomType string) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
-
-	// Set the default size for random/similar songs, clamped to 500
-	size := "50"
-	if connection.RandomSongNumber > 0 && connection.RandomSongNumber < 500 {
-		size = strconv.FormatInt(int64(connection.RandomSongNumber), 10)
-	}
+	size := connection.randomSongSize()
 
 	switch randomType {
 	case "random":
 		query.Set("size", size)
+		connection.setMusicFolder(query)
 		requestUrl := connection.Host + "/rest/getRandomSongs?" + query.Encode()
-		return connection.getResponse("GetRandomSongs", requestUrl)
+		return connection.getResponseContext(ctx, "GetRandomSongs", requestUrl)
 
 	case "similar":
 		query.Set("id", Id)
 		query.Set("count", size)
 		requestUrl := connection.Host + "/rest/getSimilarSongs?" + query.Encode()
-		return connection.getResponse("GetSimilar", requestUrl)
+		return connection.getResponseContext(ctx, "GetSimilar", requestUrl)
 
 	default:
 		query.Set("size", size)
+		connection.setMusicFolder(query)
 		requestUrl := connection.Host + "/rest/getRandomSongs?" + query.Encode()
-		return connection.getResponse("GetRandomSongs", requestUrl)
+		return connection.getResponseContext(ctx, "GetRandomSongs", requestUrl)
 	}
 }
 
+// randomSongSize returns the number of random/similar songs to request
+func (connection *SubsonicConnection) randomSongSize() string {
+	// Set the default size for random/similar songs, clamped to 500
+	size := "50"
+	if connection.RandomSongNumber > 0 && connection.RandomSongNumber < 500 {
+		size = strconv.FormatInt(int64(connection.RandomSongNumber), 10)
+	}
+	return size
+}
+
 func (connection *SubsonicConnection) ScrobbleSubmission(id string, isSubmission bool) (resp *SubsonicResponse, err error) {
+	return connection.ScrobbleSubmissionContext(context.Background(), id, isSubmission)
+}
+
+func (connection *SubsonicConnection) ScrobbleSubmissionContext(ctx context.Context, id string, isSubmission bool) (resp *SubsonicResponse, err error) {
 	query := defaultQuery(connection)
 	query.Set("id", id)
 
@@ -476,21 +577,65 @@ func (connection *SubsonicConnection) ScrobbleSubmission(id string, isSubmission
 	query.Set("submission", strconv.FormatBool(isSubmission))
 
 	requestUrl := connection.Host + "/rest/scrobble" + "?" + query.Encode()
-	resp, err = connection.getResponse("ScrobbleSubmission", requestUrl)
+	resp, err = connection.getResponseContext(ctx, "ScrobbleSubmission", requestUrl)
 	return
 }
 
 func (connection *SubsonicConnection) GetStarred() (*SubsonicResponse, error) {
+	return connection.GetStarredContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetStarredContext(ctx context.Context) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
+	connection.setMusicFolder(query)
 	requestUrl := connection.Host + "/rest/getStarred" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetStarred", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetStarred", requestUrl)
 	if err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
 
+// GetStarred2 is like GetStarred but organizes artists and albums by ID3 tags.
+// https://www.subsonic.org/pages/api.jsp#getStarred2
+func (connection *SubsonicConnection) GetStarred2() (*SubsonicResponse, error) {
+	return connection.GetStarred2Context(context.Background())
+}
+
+func (connection *SubsonicConnection) GetStarred2Context(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getStarred2" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetStarred2", requestUrl)
+}
+
+// Unstar removes the star from a song or folder (id), or from an album or
+// artist organized by ID3 tags (albumId, artistId). Unused ids are empty.
+// https://www.subsonic.org/pages/api.jsp#unstar
+func (connection *SubsonicConnection) Unstar(id, albumId, artistId string) (*SubsonicResponse, error) {
+	return connection.UnstarContext(context.Background(), id, albumId, artistId)
+}
+
+func (connection *SubsonicConnection) UnstarContext(ctx context.Context, id, albumId, artistId string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	if id != "" {
+		query.Set("id", id)
+	}
+	if albumId != "" {
+		query.Set("albumId", albumId)
+	}
+	if artistId != "" {
+		query.Set("artistId", artistId)
+	}
+	requestUrl := connection.Host + "/rest/unstar" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "Unstar", requestUrl)
+}
+
 func (connection *SubsonicConnection) ToggleStar(id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
+	return connection.ToggleStarContext(context.Background(), id, starredItems)
+}
+
+func (connection *SubsonicConnection) ToggleStarContext(ctx context.Context, id string, starredItems map[string]struct{}) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	query.Set("id", id)
 
@@ -502,7 +647,7 @@ func (connection *SubsonicConnection) ToggleStar(id string, starredItems map[str
 	}
 
 	requestUrl := connection.Host + "/rest/" + action + "?" + query.Encode()
-	resp, err := connection.getResponse("ToggleStar", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "ToggleStar", requestUrl)
 	if err != nil {
 		if ok {
 			delete(starredItems, id)
@@ -514,10 +659,33 @@ func (connection *SubsonicConnection) ToggleStar(id string, starredItems map[str
 	return resp, nil
 }
 
+// SetRating rates a song, album or artist from 1 to 5 stars, 0 removes the
+// rating.
+// https://www.subsonic.org/pages/api.jsp#setRating
+func (connection *SubsonicConnection) SetRating(id string, rating int) (*SubsonicResponse, error) {
+	return connection.SetRatingContext(context.Background(), id, rating)
+}
+
+func (connection *SubsonicConnection) SetRatingContext(ctx context.Context, id string, rating int) (*SubsonicResponse, error) {
+	if rating < 0 || rating > 5 {
+		return nil, fmt.Errorf("[SetRating] invalid rating %d, must be between 0 and 5", rating)
+	}
+
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("rating", strconv.Itoa(rating))
+	requestUrl := connection.Host + "/rest/setRating" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "SetRating", requestUrl)
+}
+
 func (connection *SubsonicConnection) GetPlaylists() (*SubsonicResponse, error) {
+	return connection.GetPlaylistsContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetPlaylistsContext(ctx context.Context) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	requestUrl := connection.Host + "/rest/getPlaylists" + "?" + query.Encode()
-	resp, err := connection.getResponse("GetPlaylists", requestUrl)
+	resp, err := connection.getResponseContext(ctx, "GetPlaylists", requestUrl)
 	if err != nil {
 		return resp, err
 	}
@@ -529,7 +697,7 @@ func (connection *SubsonicConnection) GetPlaylists() (*SubsonicResponse, error)
 			continue
 		}
 
-		response, err := connection.GetPlaylist(string(playlist.Id))
+		response, err := connection.GetPlaylistContext(ctx, string(playlist.Id))
 
 		if err != nil {
 			return nil, err
@@ -542,11 +710,15 @@ func (connection *SubsonicConnection) GetPlaylists() (*SubsonicResponse, error)
 }
 
 func (connection *SubsonicConnection) GetPlaylist(id string) (*SubsonicResponse, error) {
+	return connection.GetPlaylistContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetPlaylistContext(ctx context.Context, id string) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	query.Set("id", id)
 
 	requestUrl := connection.Host + "/rest/getPlaylist" + "?" + query.Encode()
-	return connection.getResponse("GetPlaylist", requestUrl)
+	return connection.getResponseContext(ctx, "GetPlaylist", requestUrl)
 }
 
 // CreatePlaylist creates or updates a playlist on the server.
@@ -557,6 +729,10 @@ func (connection *SubsonicConnection) GetPlaylist(id string) (*SubsonicResponse,
 // songIds may be nil, in which case the new playlist is created empty, or all
 // songs are removed from the existing playlist.
 func (connection *SubsonicConnection) CreatePlaylist(id, name string, songIds []string) (*SubsonicResponse, error) {
+	return connection.CreatePlaylistContext(context.Background(), id, name, songIds)
+}
+
+func (connection *SubsonicConnection) CreatePlaylistContext(ctx context.Context, id, name string, songIds []string) (*SubsonicResponse, error) {
 	if (id == "" && name == "") || (id != "" && name != "") {
 		return nil, errors.New("CreatePlaylist: exactly one of id or name must be provided")
 	}
@@ -570,62 +746,128 @@ func (connection *SubsonicConnection) CreatePlaylist(id, name string, songIds []
 		query.Add("songId", sid)
 	}
 	requestUrl := connection.Host + "/rest/createPlaylist" + "?" + query.Encode()
-	return connection.getResponse("GetPlaylist", requestUrl)
+	return connection.getResponseContext(ctx, "GetPlaylist", requestUrl)
 }
 
 func (connection *SubsonicConnection) getResponse(caller, requestUrl string) (*SubsonicResponse, error) {
-	res, err := http.Get(requestUrl)
+	return connection.getResponseContext(context.Background(), caller, requestUrl)
+}
+
+func (connection *SubsonicConnection) getResponseContext(ctx context.Context, caller, requestUrl string) (*SubsonicResponse, error) {
+	if connection.IsOffline() {
+		return connection.offlineResponse(ctx, caller, requestUrl)
+	}
+
+	resp, err := connection.request(ctx, caller, requestUrl)
+	if err == nil {
+		connection.remember(requestUrl, resp)
+	}
+	return resp, err
+}
+
+// request makes a request even if the connection is offline
+func (connection *SubsonicConnection) request(ctx context.Context, caller, requestUrl string) (*SubsonicResponse, error) {
+	responseBody, _, err := connection.fetchOnline(ctx, caller, requestUrl)
 	if err != nil {
-		return nil, fmt.Errorf("[%s] failed to make GET request: %v", caller, err)
+		return nil, err
+	}
+
+	var decodedBody responseWrapper
+	err = json.Unmarshal(responseBody, &decodedBody)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to unmarshal response body: %v", caller, err)
+	}
+
+	if decodedBody.Response.Status == "failed" {
+		return &decodedBody.Response, &APIError{
+			Caller:  caller,
+			Code:    decodedBody.Response.Error.Code,
+			Message: decodedBody.Response.Error.Message,
+		}
+	}
+
+	return &decodedBody.Response, nil
+}
+
+// fetch makes a GET request bounded by ctx and the connection timeout and
+// returns the body and header of a successful response.
+func (connection *SubsonicConnection) fetch(ctx context.Context, caller, requestUrl string) ([]byte, http.Header, error) {
+	if connection.IsOffline() {
+		return nil, nil, fmt.Errorf("[%s] %w", caller, ErrOffline)
+	}
+	return connection.fetchOnline(ctx, caller, requestUrl)
+}
+
+func (connection *SubsonicConnection) fetchOnline(ctx context.Context, caller, requestUrl string) ([]byte, http.Header, error) {
+	if connection.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, connection.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[%s] failed to create GET request: %w", caller, err)
+	}
+
+	res, err := connection.httpClient().Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[%s] failed to make GET request: %w", caller, err)
 	}
 
 	if res.Body != nil {
 		defer res.Body.Close()
 	} else {
-		return nil, fmt.Errorf("[%s] response body is nil", caller)
+		return nil, nil, fmt.Errorf("[%s] response body is nil", caller)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("[%s] unexpected status code: %d, status: %s", caller, res.StatusCode, res.Status)
+		return nil, nil, fmt.Errorf("[%s] unexpected status code: %d, status: %s", caller, res.StatusCode, res.Status)
 	}
 
 	responseBody, readErr := io.ReadAll(res.Body)
 	if readErr != nil {
-		return nil, fmt.Errorf("[%s] failed to read response body: %v", caller, readErr)
+		return nil, nil, fmt.Errorf("[%s] failed to read response body: %w", caller, readErr)
 	}
 
-	var decodedBody responseWrapper
-	err = json.Unmarshal(responseBody, &decodedBody)
-	if err != nil {
-		return nil, fmt.Errorf("[%s] failed to unmarshal response body: %v", caller, err)
-	}
-
-	return &decodedBody.Response, nil
+	return responseBody, res.Header, nil
 }
 
 func (connection *SubsonicConnection) DeletePlaylist(id string) error {
+	return connection.DeletePlaylistContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DeletePlaylistContext(ctx context.Context, id string) error {
 	query := defaultQuery(connection)
 	query.Set("id", id)
 	requestUrl := connection.Host + "/rest/deletePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	_, err := connection.getResponseContext(ctx, "DeletePlaylist", requestUrl)
 	return err
 }
 
 func (connection *SubsonicConnection) AddSongToPlaylist(playlistId string, songId string) error {
+	return connection.AddSongToPlaylistContext(context.Background(), playlistId, songId)
+}
+
+func (connection *SubsonicConnection) AddSongToPlaylistContext(ctx context.Context, playlistId string, songId string) error {
 	query := defaultQuery(connection)
 	query.Set("playlistId", playlistId)
 	query.Set("songIdToAdd", songId)
 	requestUrl := connection.Host + "/rest/updatePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	_, err := connection.getResponseContext(ctx, "AddSongToPlaylist", requestUrl)
 	return err
 }
 
 func (connection *SubsonicConnection) RemoveSongFromPlaylist(playlistId string, songIndex int) error {
+	return connection.RemoveSongFromPlaylistContext(context.Background(), playlistId, songIndex)
+}
+
+func (connection *SubsonicConnection) RemoveSongFromPlaylistContext(ctx context.Context, playlistId string, songIndex int) error {
 	query := defaultQuery(connection)
 	query.Set("playlistId", playlistId)
 	query.Set("songIndexToRemove", strconv.Itoa(songIndex))
 	requestUrl := connection.Host + "/rest/updatePlaylist" + "?" + query.Encode()
-	_, err := http.Get(requestUrl)
+	_, err := connection.getResponseContext(ctx, "RemoveSongFromPlaylist", requestUrl)
 	return err
 }
 
@@ -639,6 +881,7 @@ func (connection *SubsonicConnection) GetPlayUrl(entity *SubsonicEntity) string
 
 	query := defaultQuery(connection)
 	query.Set("id", entity.Id)
+	connection.StreamProfile.apply(query)
 	return connection.Host + "/rest/stream" + "?" + query.Encode()
 }
 
@@ -647,13 +890,18 @@ func (connection *SubsonicConnection) GetPlayUrl(entity *SubsonicEntity) string
 // ID3 field.
 // https://www.subsonic.org/pages/api.jsp#search3
 func (connection *SubsonicConnection) Search(searchTerm string, artistOffset, albumOffset, songOffset int) (*SubsonicResponse, error) {
+	return connection.SearchContext(context.Background(), searchTerm, artistOffset, albumOffset, songOffset)
+}
+
+func (connection *SubsonicConnection) SearchContext(ctx context.Context, searchTerm string, artistOffset, albumOffset, songOffset int) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	query.Set("query", searchTerm)
 	query.Set("artistOffset", strconv.Itoa(artistOffset))
 	query.Set("albumOffset", strconv.Itoa(albumOffset))
 	query.Set("songOffset", strconv.Itoa(songOffset))
+	connection.setMusicFolder(query)
 	requestUrl := connection.Host + "/rest/search3" + "?" + query.Encode()
-	res, err := connection.getResponse("Search", requestUrl)
+	res, err := connection.getResponseContext(ctx, "Search", requestUrl)
 	return res, err
 }
 
@@ -661,9 +909,13 @@ func (connection *SubsonicConnection) Search(searchTerm string, artistOffset, al
 // this is a deep or surface scan is dependent on the server implementation.
 // https://subsonic.org/pages/api.jsp#startScan
 func (connection *SubsonicConnection) StartScan() error {
+	return connection.StartScanContext(context.Background())
+}
+
+func (connection *SubsonicConnection) StartScanContext(ctx context.Context) error {
 	query := defaultQuery(connection)
 	requestUrl := fmt.Sprintf("%s/rest/startScan?%s", connection.Host, query.Encode())
-	if res, err := connection.getResponse("StartScan", requestUrl); err != nil {
+	if res, err := connection.getResponseContext(ctx, "StartScan", requestUrl); err != nil {
 		return err
 	} else if !res.ScanStatus.Scanning {
 		return fmt.Errorf("server returned false for scan status on scan attempt")
@@ -672,6 +924,10 @@ func (connection *SubsonicConnection) StartScan() error {
 }
 
 func (connection *SubsonicConnection) SavePlayQueue(queueIds []string, current string, position int) error {
+	return connection.SavePlayQueueContext(context.Background(), queueIds, current, position)
+}
+
+func (connection *SubsonicConnection) SavePlayQueueContext(ctx context.Context, queueIds []string, current string, position int) error {
 	query := defaultQuery(connection)
 	for _, songId := range queueIds {
 		query.Add("id", songId)
@@ -679,12 +935,16 @@ func (connection *SubsonicConnection) SavePlayQueue(queueIds []string, current s
 	query.Set("current", current)
 	query.Set("position", fmt.Sprintf("%d", position))
 	requestUrl := fmt.Sprintf("%s/rest/savePlayQueue?%s", connection.Host, query.Encode())
-	_, err := connection.getResponse("SavePlayQueue", requestUrl)
+	_, err := connection.getResponseContext(ctx, "SavePlayQueue", requestUrl)
 	return err
 }
 
 func (connection *SubsonicConnection) LoadPlayQueue() (*SubsonicResponse, error) {
+	return connection.LoadPlayQueueContext(context.Background())
+}
+
+func (connection *SubsonicConnection) LoadPlayQueueContext(ctx context.Context) (*SubsonicResponse, error) {
 	query := defaultQuery(connection)
 	requestUrl := fmt.Sprintf("%s/rest/getPlayQueue?%s", connection.Host, query.Encode())
-	return connection.getResponse("GetPlayQueue", requestUrl)
+	return connection.getResponseContext(ctx, "GetPlayQueue", requestUrl)
 }
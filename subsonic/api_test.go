@@ -1,10 +1,19 @@
 package subsonic
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetResponse(t *testing.T) {
@@ -36,6 +45,13 @@ func TestGetResponse(t *testing.T) {
 			expectError:  true,
 			caller:       "TestCaller",
 		},
+		{
+			name:         "Failed Status",
+			serverStatus: http.StatusOK,
+			serverBody:   `{"subsonic-response": {"status": "failed", "error": {"code": 40, "message": "Wrong username or password"}}}`,
+			expectError:  true,
+			caller:       "TestCaller",
+		},
 		{
 			name:         "Empty Caller",
 			serverStatus: http.StatusOK,
@@ -81,6 +97,617 @@ func TestGetResponse(t *testing.T) {
 	}
 }
 
+func TestGetResponseAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 50, "message": "User is not authorized"}}}`))
+	}))
+	defer server.Close()
+
+	connection := &SubsonicConnection{Host: server.URL}
+
+	err := connection.DeletePlaylist("123")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError but got: %v", err)
+	}
+	if apiErr.Code != ErrorNotAuthorized || apiErr.Caller != "DeletePlaylist" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if !IsErrorCode(err, ErrorNotAuthorized) {
+		t.Errorf("expected IsErrorCode to match")
+	}
+	if ErrorDescription(err) != "permission denied" {
+		t.Errorf("unexpected description %q", ErrorDescription(err))
+	}
+}
+
+func TestGetResponseTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	connection := &SubsonicConnection{Timeout: 10 * time.Millisecond}
+
+	_, err := connection.getResponse("TestCaller", server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error but got: %v", err)
+	}
+}
+
+func TestGetResponseCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should not have been made")
+	}))
+	defer server.Close()
+
+	connection := &SubsonicConnection{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := connection.getResponseContext(ctx, "TestCaller", server.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error but got: %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSetHttpClient(t *testing.T) {
+	var requestedPath string
+	connection := Init(nil)
+	connection.Host = "http://subsonic.invalid"
+	connection.SetHttpClient(&http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requestedPath = r.URL.Path
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"subsonic-response": {"status": "ok", "version": "1.16.1"}}`)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	})
+
+	response, err := connection.GetServerInfo()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if requestedPath != "/rest/ping" {
+		t.Errorf("expected request to /rest/ping but got %s", requestedPath)
+	}
+	if response.Status != "ok" || response.Version != "1.16.1" {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
+
+func TestGetOpenSubsonicExtensions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("apiKey") != "secret" || query.Has("u") || query.Has("p") || query.Has("t") {
+			t.Errorf("unexpected auth parameters: %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1", "openSubsonic": true,
+			"openSubsonicExtensions": [{"name": "songLyrics", "versions": [1]}, {"name": "formPost", "versions": [1]}]}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+	connection.Username = "user"
+	connection.ApiKey = "secret"
+
+	if _, err := connection.GetOpenSubsonicExtensions(); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !connection.IsOpenSubsonic() {
+		t.Errorf("expected server to be OpenSubsonic")
+	}
+	if !connection.HasExtension(ExtensionSongLyrics) || !connection.HasExtensionVersion(ExtensionFormPost, 1) {
+		t.Errorf("expected extensions to be recorded")
+	}
+	if connection.HasExtension(ExtensionTranscodeOffset) {
+		t.Errorf("unexpected extension %s", ExtensionTranscodeOffset)
+	}
+	if !connection.SupportsApiVersion("1.8.0") || connection.SupportsApiVersion("1.16.2") {
+		t.Errorf("wrong api version comparison for %s", connection.apiVersion)
+	}
+}
+
+func TestGetAlbumList(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/getAlbumList2" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+			"albumList2": {"album": [{"id": "7", "name": "Album", "artist": "Artist", "year": 1999}]}}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	response, err := connection.GetAlbumList(AlbumListNewest, 50, 100)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("type") != "newest" || query.Get("size") != "50" || query.Get("offset") != "100" {
+		t.Errorf("unexpected query %v", query)
+	}
+	albums := response.AlbumList2.Album
+	if len(albums) != 1 || albums[0].Id != "7" || albums[0].Name != "Album" || albums[0].Year != 1999 {
+		t.Errorf("unexpected albums %+v", albums)
+	}
+
+	// out of range sizes are clamped
+	if _, err := connection.GetAlbumList(AlbumListRandom, 0, 0); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("size") != strconv.Itoa(MaxAlbumListSize) {
+		t.Errorf("expected size %d but got %q", MaxAlbumListSize, query.Get("size"))
+	}
+
+	if _, err := connection.GetAlbumListByGenre("Rock & Roll", 10, 0); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("type") != "byGenre" || query.Get("genre") != "Rock & Roll" {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	if _, err := connection.GetAlbumListByYear(2000, 1990, 10, 0); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("type") != "byYear" || query.Get("fromYear") != "2000" || query.Get("toYear") != "1990" {
+		t.Errorf("unexpected query %v", query)
+	}
+}
+
+func TestGetGenres(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		switch r.URL.Path {
+		case "/rest/getGenres":
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+				"genres": {"genre": [{"value": "Jazz", "songCount": 12, "albumCount": 3}]}}}`))
+		case "/rest/getSongsByGenre":
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+				"songsByGenre": {"song": [{"id": "42", "title": "Song", "genre": "Jazz"}]}}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	response, err := connection.GetGenres()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	genres := response.Genres.Genre
+	if len(genres) != 1 || genres[0].Name != "Jazz" || genres[0].SongCount != 12 || genres[0].AlbumCount != 3 {
+		t.Errorf("unexpected genres %+v", genres)
+	}
+
+	response, err = connection.GetSongsByGenre("Jazz", 25, 50)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("genre") != "Jazz" || query.Get("count") != "25" || query.Get("offset") != "50" {
+		t.Errorf("unexpected query %v", query)
+	}
+	songs := response.SongsByGenre.Song
+	if len(songs) != 1 || songs[0].Id != "42" || songs[0].Title != "Song" {
+		t.Errorf("unexpected songs %+v", songs)
+	}
+
+	// out of range counts are clamped
+	if _, err := connection.GetSongsByGenre("Jazz", 1000, 0); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if query.Get("count") != strconv.Itoa(MaxSongsByGenreCount) {
+		t.Errorf("expected count %d but got %q", MaxSongsByGenreCount, query.Get("count"))
+	}
+}
+
+func TestParseLrc(t *testing.T) {
+	lyrics := ParseLrc("[ar:Someone]\r\n[00:01.50]first\n[00:10.00][00:30.00]chorus\n[00:20]second\n")
+	if !lyrics.Synced {
+		t.Fatalf("expected synced lyrics")
+	}
+
+	expected := []LyricLine{{1500, "first"}, {10000, "chorus"}, {20000, "second"}, {30000, "chorus"}}
+	if len(lyrics.Lines) != len(expected) {
+		t.Fatalf("expected %d lines but got %+v", len(expected), lyrics.Lines)
+	}
+	for i := range expected {
+		if lyrics.Lines[i] != expected[i] {
+			t.Errorf("line %d: expected %+v but got %+v", i, expected[i], lyrics.Lines[i])
+		}
+	}
+
+	for _, tc := range []struct{ position, line int }{{0, -1}, {1500, 0}, {19999, 1}, {45000, 3}} {
+		if line := lyrics.CurrentLine(tc.position); line != tc.line {
+			t.Errorf("position %d: expected line %d but got %d", tc.position, tc.line, line)
+		}
+	}
+
+	plain := ParseLrc("\nno timestamps\nhere\n\n")
+	if plain.Synced || len(plain.Lines) != 2 || plain.CurrentLine(1000) != -1 {
+		t.Errorf("unexpected unsynced lyrics %+v", plain)
+	}
+}
+
+func TestGetSongLyrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/getLyricsBySongId":
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+				"lyricsList": {"structuredLyrics": [{"lang": "eng", "offset": 100, "synced": true,
+				"line": [{"start": 0, "value": "one"}, {"start": 2000, "value": "two"}]}]}}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+	connection.extensions[ExtensionSongLyrics] = []int{1}
+
+	lyrics, err := connection.GetSongLyrics("1", "artist", "title")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(lyrics) != 1 || !lyrics[0].Synced || len(lyrics[0].Lines) != 2 {
+		t.Fatalf("unexpected lyrics %+v", lyrics)
+	}
+	if line := lyrics[0].CurrentLine(1900); line != 1 {
+		t.Errorf("expected offset to apply, got line %d", line)
+	}
+}
+
+func TestGetNowPlaying(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+			"nowPlaying": {"entry": [{"id": "42", "title": "Song", "artist": "Artist",
+			"username": "alice", "minutesAgo": 3, "playerId": 1, "playerName": "stmps"}]}}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	response, err := connection.GetNowPlaying()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	entries := response.NowPlaying.Entry
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Id != "42" || entries[0].Title != "Song" || entries[0].Username != "alice" || entries[0].MinutesAgo != 3 {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+}
+
+func TestSetRating(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/rest/setRating" || r.URL.Query().Get("rating") != "4" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1"}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	if _, err := connection.SetRating("1", 4); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if _, err := connection.SetRating("1", 6); !containsCallerInError(err, "SetRating") {
+		t.Errorf("expected invalid rating error but got: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestCreateShare(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/rest/createShare" || len(query["id"]) != 2 || query.Get("expires") != strconv.FormatInt(expires.UnixMilli(), 10) {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+			"shares": {"share": [{"id": "7", "url": "https://example.com/share/7", "visitCount": 0,
+			"entry": [{"id": "1", "title": "Song"}, {"id": "2", "title": "Song 2"}]}]}}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	response, err := connection.CreateShare([]string{"1", "2"}, "", expires)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(response.Shares.Share) != 1 || response.Shares.Share[0].Url != "https://example.com/share/7" {
+		t.Errorf("unexpected shares %+v", response.Shares)
+	}
+}
+
+func TestJukeboxSkip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/rest/jukeboxControl" || query.Get("action") != "skip" || query.Get("index") != "2" || query.Get("offset") != "30" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1",
+			"jukeboxStatus": {"currentIndex": 2, "playing": true, "gain": 0.5, "position": 30}}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	response, err := connection.JukeboxSkip(2, 30)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	status := response.JukeboxStatus
+	if status.CurrentIndex != 2 || !status.Playing || status.Gain != 0.5 || status.Position != 30 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestMusicFolderId(t *testing.T) {
+	musicFolderId := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("musicFolderId"); got != musicFolderId {
+			t.Errorf("expected musicFolderId %q but got %q in %s", musicFolderId, got, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1"}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	for _, id := range []string{"", "3"} {
+		musicFolderId = id
+		connection.SetMusicFolderId(id)
+		if _, err := connection.GetIndexes(); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if _, err := connection.GetRandomSongs("", "random"); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if _, err := connection.Search("query", 0, 0, 0); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+	}
+}
+
+func TestResolvePlayUrl(t *testing.T) {
+	connection := Init(nil)
+	connection.Host = "http://music.example"
+
+	uri := connection.GetPlayUrl(&SubsonicEntity{Id: "42"})
+	connection.StreamProfile = StreamProfile{Name: "vpn", MaxBitRate: 128, Format: "opus"}
+	parsed, err := url.Parse(connection.ResolvePlayUrl(uri))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("id") != "42" || query.Get("maxBitRate") != "128" || query.Get("format") != "opus" {
+		t.Errorf("unexpected query: %s", parsed.RawQuery)
+	}
+
+	connection.StreamProfile = StreamProfile{Name: "home", Format: "raw"}
+	parsed, err = url.Parse(connection.ResolvePlayUrl(parsed.String()))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	query = parsed.Query()
+	if query.Has("maxBitRate") || query.Get("format") != "raw" {
+		t.Errorf("unexpected query: %s", parsed.RawQuery)
+	}
+
+	radio := "http://radio.example/stream?id=1"
+	if got := connection.ResolvePlayUrl(radio); got != radio {
+		t.Errorf("expected %s to be unchanged but got %s", radio, got)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := "0123456789abcdef"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "42" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "version": "1.16.1", "error": {"code": 70, "message": "not found"}}}`))
+			return
+		}
+		http.ServeContent(w, r, "song.flac", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+
+	download, err := connection.Download("42", 10)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	defer download.Body.Close()
+	body, err := io.ReadAll(download.Body)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if download.Offset != 10 || download.Size != int64(len(content)) || string(body) != content[10:] {
+		t.Errorf("unexpected download at %d of %d bytes: %q", download.Offset, download.Size, body)
+	}
+
+	_, err = connection.Download("43", 0)
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.Code != 70 {
+		t.Errorf("expected API error 70 but got: %v", err)
+	}
+}
+
+func TestOfflineSnapshot(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1", "indexes": {"index": [{"name": "A"}]}}}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	connection := Init(nil)
+	connection.Host = server.URL
+	if err := connection.EnableSnapshot(dir); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if _, err := connection.GetIndexes(); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := connection.SaveSnapshot(); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	// a new run starts offline with the saved snapshot
+	connection = Init(nil)
+	connection.Host = server.URL
+	if err := connection.EnableSnapshot(dir); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	connection.SetOffline(true)
+	requests = nil
+
+	response, err := connection.GetIndexes()
+	if err != nil || len(response.Indexes.Index) != 1 {
+		t.Errorf("expected indexes from snapshot but got %v, %v", response, err)
+	}
+	if _, err := connection.GetRandomSongs("", "random"); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline but got: %v", err)
+	}
+	if _, err := connection.ToggleStar("42", map[string]struct{}{}); err != nil {
+		t.Errorf("expected star to be journaled but got: %v", err)
+	}
+	if len(requests) != 0 || connection.JournalLength() != 1 {
+		t.Fatalf("expected no requests and a journal entry but got %v, %d", requests, connection.JournalLength())
+	}
+
+	replayed, err := connection.Reconnect()
+	if err != nil || replayed != 1 || connection.IsOffline() {
+		t.Errorf("expected to replay 1 change but got %d, %v", replayed, err)
+	}
+	if len(requests) != 2 || requests[1] != "/rest/star" {
+		t.Errorf("expected ping and star but got %v", requests)
+	}
+}
+
+func TestReconnectKeepsOrder(t *testing.T) {
+	var requests []string
+	failStar := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/star" && failStar {
+			failStar = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		requests = append(requests, r.URL.Path)
+		_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "version": "1.16.1"}}`))
+	}))
+	defer server.Close()
+
+	connection := Init(nil)
+	connection.Host = server.URL
+	if err := connection.EnableSnapshot(t.TempDir()); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	connection.SetOffline(true)
+	starred := map[string]struct{}{}
+	if _, err := connection.ToggleStar("42", starred); err != nil {
+		t.Fatalf("expected star to be journaled but got: %v", err)
+	}
+	starred["42"] = struct{}{}
+	if _, err := connection.ToggleStar("42", starred); err != nil {
+		t.Fatalf("expected unstar to be journaled but got: %v", err)
+	}
+
+	// the star fails, nothing is replayed out of order
+	replayed, err := connection.Reconnect()
+	if err == nil || replayed != 0 || !connection.IsOffline() || connection.JournalLength() != 2 {
+		t.Errorf("expected to stay offline with 2 changes but got %d, %v, %d", replayed, err, connection.JournalLength())
+	}
+
+	requests = nil
+	replayed, err = connection.Reconnect()
+	if err != nil || replayed != 2 || connection.IsOffline() || connection.JournalLength() != 0 {
+		t.Errorf("expected to replay 2 changes but got %d, %v", replayed, err)
+	}
+	if len(requests) != 3 || requests[1] != "/rest/star" || requests[2] != "/rest/unstar" {
+		t.Errorf("expected ping, star and unstar but got %v", requests)
+	}
+}
+
+func TestCoverArtCache(t *testing.T) {
+	var art bytes.Buffer
+	if err := png.Encode(&art, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("size") != "300" {
+			t.Errorf("expected size 300 but got %q", r.URL.Query().Get("size"))
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(art.Bytes())
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for run := 0; run < 2; run++ {
+		// the second run gets the cover art from disk
+		connection := Init(nil)
+		connection.Host = server.URL
+		if err := connection.EnableCoverArtCache(dir, DefaultCoverArtDiskSize); err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if _, ok := connection.CachedCoverArt("al-1", 300); ok {
+			t.Errorf("expected cover art not to be in memory yet")
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := connection.GetCoverArt("al-1", 300); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+		}
+		if _, ok := connection.CachedCoverArt("al-1", 300); !ok {
+			t.Errorf("expected cover art to be in memory")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request but got %d", requests)
+	}
+}
+
 // Helper function to check if the error contains the caller
 func containsCallerInError(err error, caller string) bool {
 	return err != nil && (caller == "" || strings.Contains(err.Error(), "["+caller+"]"))
@@ -0,0 +1,70 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"strconv"
+)
+
+type Bookmarks struct {
+	Bookmark []Bookmark `json:"bookmark"`
+}
+
+type Bookmark struct {
+	// position in milliseconds
+	Position int64          `json:"position"`
+	Username string         `json:"username"`
+	Comment  string         `json:"comment"`
+	Created  string         `json:"created"`
+	Changed  string         `json:"changed"`
+	Entry    SubsonicEntity `json:"entry"`
+}
+
+func (b Bookmark) ID() string {
+	return b.Entry.Id
+}
+
+// GetBookmarks lists the bookmarks of the user.
+// https://www.subsonic.org/pages/api.jsp#getBookmarks
+func (connection *SubsonicConnection) GetBookmarks() (*SubsonicResponse, error) {
+	return connection.GetBookmarksContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetBookmarksContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getBookmarks" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetBookmarks", requestUrl)
+}
+
+// CreateBookmark creates or replaces the bookmark of a song, position is in
+// milliseconds. comment is optional.
+// https://www.subsonic.org/pages/api.jsp#createBookmark
+func (connection *SubsonicConnection) CreateBookmark(id string, position int64, comment string) (*SubsonicResponse, error) {
+	return connection.CreateBookmarkContext(context.Background(), id, position, comment)
+}
+
+func (connection *SubsonicConnection) CreateBookmarkContext(ctx context.Context, id string, position int64, comment string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("position", strconv.FormatInt(position, 10))
+	if comment != "" {
+		query.Set("comment", comment)
+	}
+	requestUrl := connection.Host + "/rest/createBookmark" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "CreateBookmark", requestUrl)
+}
+
+// DeleteBookmark deletes the bookmark of a song.
+// https://www.subsonic.org/pages/api.jsp#deleteBookmark
+func (connection *SubsonicConnection) DeleteBookmark(id string) (*SubsonicResponse, error) {
+	return connection.DeleteBookmarkContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DeleteBookmarkContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteBookmark" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "DeleteBookmark", requestUrl)
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// decoded cover arts kept in memory
+	coverArtMemoryEntries = 64
+	// DefaultCoverArtDiskSize limits the on-disk cover art cache in bytes
+	DefaultCoverArtDiskSize = 100 * 1024 * 1024
+)
+
+// coverArtCache keeps the most recently used decoded cover arts in memory and
+// the original bytes on disk, if a directory is set with EnableCoverArtCache
+type coverArtCache struct {
+	mutex sync.Mutex
+
+	// values are *coverArtEntry, front is the most recently used
+	lru     *list.List
+	entries map[string]*list.Element
+
+	dir         string
+	maxDiskSize int64
+	diskSize    int64
+}
+
+type coverArtEntry struct {
+	key string
+	art image.Image
+}
+
+func newCoverArtCache() coverArtCache {
+	return coverArtCache{
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func coverArtKey(id string, size int) string {
+	// ids are opaque strings, they can't be used as file names as they are
+	return base64.RawURLEncoding.EncodeToString([]byte(id)) + "_" + strconv.Itoa(size)
+}
+
+// EnableCoverArtCache keeps fetched cover arts in dir, so they survive
+// restarts. The least recently used ones are deleted when the files exceed
+// maxSize bytes.
+func (connection *SubsonicConnection) EnableCoverArtCache(dir string, maxSize int64) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	cache := &connection.coverArts
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.dir = dir
+	cache.maxDiskSize = maxSize
+	cache.diskSize = 0
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && info.Mode().IsRegular() {
+			cache.diskSize += info.Size()
+		}
+	}
+	cache.evictFiles()
+	return nil
+}
+
+// CachedCoverArt returns a cover art if it's in memory, it never blocks on the
+// disk or the network
+func (connection *SubsonicConnection) CachedCoverArt(id string, size int) (image.Image, bool) {
+	cache := &connection.coverArts
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.get(coverArtKey(id, size))
+}
+
+// GetCoverArt fetches album art from the server, by ID. size is the width and
+// height in pixels the server scales the image to, 0 requests the original.
+// The results are cached, so it is safe to call this function repeatedly. If id
+// is empty, an error is returned. If, for some reason, the server response
+// can't be parsed into an image, an error is returned. This function can parse
+// GIF, JPEG, and PNG images.
+// https://www.subsonic.org/pages/api.jsp#getCoverArt
+func (connection *SubsonicConnection) GetCoverArt(id string, size int) (image.Image, error) {
+	return connection.GetCoverArtContext(context.Background(), id, size)
+}
+
+func (connection *SubsonicConnection) GetCoverArtContext(ctx context.Context, id string, size int) (image.Image, error) {
+	caller := "GetCoverArt"
+	if id == "" {
+		return nil, fmt.Errorf("[%s] no ID provided", caller)
+	}
+
+	cache := &connection.coverArts
+	key := coverArtKey(id, size)
+	cache.mutex.Lock()
+	art, ok := cache.get(key)
+	if !ok {
+		art, ok = cache.load(key)
+	}
+	cache.mutex.Unlock()
+	if ok {
+		return art, nil
+	}
+
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("f", "image/png")
+	if size > 0 {
+		query.Set("size", strconv.Itoa(size))
+	}
+	responseBody, _, err := connection.fetch(ctx, caller, connection.Host+"/rest/getCoverArt"+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	art, format, err := image.Decode(bytes.NewReader(responseBody))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to decode image: %w", caller, err)
+	}
+	if format != "png" && format != "jpeg" && format != "gif" {
+		return nil, fmt.Errorf("[%s] unhandled image type %s", caller, format)
+	}
+
+	cache.mutex.Lock()
+	cache.put(key, art)
+	cache.store(key, responseBody)
+	cache.mutex.Unlock()
+	return art, nil
+}
+
+// get returns a decoded cover art from memory and marks it as used
+func (c *coverArtCache) get(key string) (image.Image, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*coverArtEntry).art, true
+}
+
+func (c *coverArtCache) put(key string, art image.Image) {
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*coverArtEntry).art = art
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&coverArtEntry{key: key, art: art})
+	for c.lru.Len() > coverArtMemoryEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*coverArtEntry).key)
+	}
+}
+
+// load decodes a cover art from disk and keeps it in memory
+func (c *coverArtCache) load(key string) (image.Image, bool) {
+	if c.dir == "" {
+		return nil, false
+	}
+	path := filepath.Join(c.dir, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	art, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	// the modification time tells which files were used least recently
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	c.put(key, art)
+	return art, true
+}
+
+// store writes the original bytes of a cover art to disk
+func (c *coverArtCache) store(key string, data []byte) {
+	if c.dir == "" {
+		return
+	}
+	path := filepath.Join(c.dir, key)
+	if info, err := os.Stat(path); err == nil {
+		c.diskSize -= info.Size()
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return
+	}
+	c.diskSize += int64(len(data))
+	c.evictFiles()
+}
+
+// evictFiles deletes the least recently used files until the cache fits into
+// maxDiskSize
+func (c *coverArtCache) evictFiles() {
+	if c.maxDiskSize <= 0 || c.diskSize <= c.maxDiskSize {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			files = append(files, info)
+		}
+	}
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+
+	for _, file := range files {
+		if c.diskSize <= c.maxDiskSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err == nil {
+			c.diskSize -= file.Size()
+		}
+	}
+}
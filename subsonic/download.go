@@ -0,0 +1,118 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Download is a file being downloaded, Body has to be closed
+type Download struct {
+	Body io.ReadCloser
+	// Offset of the first byte of Body in the file, 0 if the server ignored
+	// the requested offset
+	Offset int64
+	// Size of the whole file, -1 if the server didn't tell
+	Size int64
+}
+
+// Download fetches the original file of a song starting at offset bytes, if the
+// server supports range requests.
+// https://www.subsonic.org/pages/api.jsp#download
+func (connection *SubsonicConnection) Download(id string, offset int64) (*Download, error) {
+	return connection.DownloadContext(context.Background(), id, offset)
+}
+
+// DownloadContext isn't bounded by the connection timeout because files can be
+// large, ctx has to be canceled to abort it.
+func (connection *SubsonicConnection) DownloadContext(ctx context.Context, id string, offset int64) (*Download, error) {
+	caller := "Download"
+	if connection.IsOffline() {
+		return nil, fmt.Errorf("[%s] %w", caller, ErrOffline)
+	}
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/download" + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to create GET request: %w", caller, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := connection.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to make GET request: %w", caller, err)
+	}
+
+	download := &Download{
+		Body: res.Body,
+		Size: res.ContentLength,
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		// errors are reported as a regular response instead of the file
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+			defer res.Body.Close()
+			return nil, decodeDownloadError(caller, res.Body)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is complete already
+		res.Body.Close()
+		_, size := parseContentRange(res.Header.Get("Content-Range"))
+		if size != offset {
+			return nil, fmt.Errorf("[%s] can't resume at %d, file has %d bytes", caller, offset, size)
+		}
+		download.Body = http.NoBody
+		download.Offset = offset
+		download.Size = size
+	case http.StatusPartialContent:
+		download.Offset, download.Size = parseContentRange(res.Header.Get("Content-Range"))
+		if download.Offset != offset {
+			res.Body.Close()
+			return nil, fmt.Errorf("[%s] server sent range starting at %d instead of %d", caller, download.Offset, offset)
+		}
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("[%s] unexpected status code: %d, status: %s", caller, res.StatusCode, res.Status)
+	}
+	return download, nil
+}
+
+func decodeDownloadError(caller string, body io.Reader) error {
+	var decodedBody responseWrapper
+	if err := json.NewDecoder(body).Decode(&decodedBody); err != nil {
+		return fmt.Errorf("[%s] failed to unmarshal response body: %v", caller, err)
+	}
+	return &APIError{
+		Caller:  caller,
+		Code:    decodedBody.Response.Error.Code,
+		Message: decodedBody.Response.Error.Message,
+	}
+}
+
+// parseContentRange returns start and total size of "bytes 100-199/1000",
+// the size is -1 if it's unknown
+func parseContentRange(contentRange string) (start, size int64) {
+	size = -1
+	rangeSpec, total, ok := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "/")
+	if !ok {
+		return
+	}
+	if first, _, ok := strings.Cut(rangeSpec, "-"); ok {
+		start, _ = strconv.ParseInt(first, 10, 64)
+	}
+	if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+		size = n
+	}
+	return
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Subsonic protocol error codes
+// https://www.subsonic.org/pages/api.jsp#error-handling
+const (
+	ErrorGeneric               = 0
+	ErrorMissingParameter      = 10
+	ErrorClientTooOld          = 20
+	ErrorServerTooOld          = 30
+	ErrorWrongCredentials      = 40
+	ErrorTokenAuthNotSupported = 41
+	ErrorAuthNotSupported      = 42 // OpenSubsonic
+	ErrorConflictingAuth       = 43 // OpenSubsonic
+	ErrorInvalidApiKey         = 44 // OpenSubsonic
+	ErrorNotAuthorized         = 50
+	ErrorTrialExpired          = 60
+	ErrorNotFound              = 70
+)
+
+// APIError is returned by requests when the server answers with
+// status="failed".
+type APIError struct {
+	// Caller is the name of the request method that failed
+	Caller  string
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("[%s] server error %d: %s", e.Caller, e.Code, e.Description())
+	}
+	return fmt.Sprintf("[%s] server error %d: %s", e.Caller, e.Code, e.Message)
+}
+
+// Description returns a short explanation of the error code that is suitable
+// to be shown to the user.
+func (e *APIError) Description() string {
+	switch e.Code {
+	case ErrorMissingParameter:
+		return "required parameter is missing"
+	case ErrorClientTooOld:
+		return "client is too old for this server"
+	case ErrorServerTooOld:
+		return "server is too old for this client"
+	case ErrorWrongCredentials:
+		return "wrong username or password"
+	case ErrorTokenAuthNotSupported:
+		return "token authentication not supported, try plaintext auth"
+	case ErrorAuthNotSupported:
+		return "authentication method not supported by server"
+	case ErrorConflictingAuth:
+		return "conflicting authentication methods, use either API key or password"
+	case ErrorInvalidApiKey:
+		return "invalid API key"
+	case ErrorNotAuthorized:
+		return "permission denied"
+	case ErrorTrialExpired:
+		return "server trial period is over"
+	case ErrorNotFound:
+		return "requested data not found"
+	default:
+		return "generic server error"
+	}
+}
+
+// IsErrorCode reports whether err is an APIError with the given code.
+func IsErrorCode(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// ErrorDescription returns the user-facing description of err. For errors
+// other than APIError, the error text is returned.
+func ErrorDescription(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Description()
+	}
+	return err.Error()
+}
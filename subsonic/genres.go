@@ -0,0 +1,68 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"strconv"
+)
+
+// MaxSongsByGenreCount is the largest page size the server accepts
+const MaxSongsByGenreCount = 500
+
+type SubsonicGenres struct {
+	Genre []SubsonicGenre `json:"genre"`
+}
+
+type SubsonicGenre struct {
+	Name       string `json:"value"`
+	SongCount  int    `json:"songCount"`
+	AlbumCount int    `json:"albumCount"`
+}
+
+// GetGenres returns all genres with their song and album counts.
+// https://www.subsonic.org/pages/api.jsp#getGenres
+func (connection *SubsonicConnection) GetGenres() (*SubsonicResponse, error) {
+	return connection.GetGenresContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetGenresContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getGenres" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetGenres", requestUrl)
+}
+
+// GetSongsByGenre fetches a page of songs of the given genre.
+// https://www.subsonic.org/pages/api.jsp#getSongsByGenre
+func (connection *SubsonicConnection) GetSongsByGenre(genre string, count, offset int) (*SubsonicResponse, error) {
+	return connection.GetSongsByGenreContext(context.Background(), genre, count, offset)
+}
+
+func (connection *SubsonicConnection) GetSongsByGenreContext(ctx context.Context, genre string, count, offset int) (*SubsonicResponse, error) {
+	if count <= 0 || count > MaxSongsByGenreCount {
+		count = MaxSongsByGenreCount
+	}
+	query := defaultQuery(connection)
+	query.Set("genre", genre)
+	query.Set("count", strconv.Itoa(count))
+	query.Set("offset", strconv.Itoa(offset))
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getSongsByGenre" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetSongsByGenre", requestUrl)
+}
+
+// GetRandomSongsByGenre returns random songs of the given genre, as many as
+// GetRandomSongs would return.
+func (connection *SubsonicConnection) GetRandomSongsByGenre(genre string) (*SubsonicResponse, error) {
+	return connection.GetRandomSongsByGenreContext(context.Background(), genre)
+}
+
+func (connection *SubsonicConnection) GetRandomSongsByGenreContext(ctx context.Context, genre string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("genre", genre)
+	query.Set("size", connection.randomSongSize())
+	connection.setMusicFolder(query)
+	requestUrl := connection.Host + "/rest/getRandomSongs" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetRandomSongsByGenre", requestUrl)
+}
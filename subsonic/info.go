@@ -0,0 +1,87 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"strconv"
+)
+
+// number of similar artists requested by default
+const DefaultSimilarArtistCount = 20
+
+type ArtistInfo struct {
+	Biography      string   `json:"biography"`
+	MusicBrainzId  string   `json:"musicBrainzId"`
+	LastFmUrl      string   `json:"lastFmUrl"`
+	SmallImageUrl  string   `json:"smallImageUrl"`
+	MediumImageUrl string   `json:"mediumImageUrl"`
+	LargeImageUrl  string   `json:"largeImageUrl"`
+	SimilarArtist  []Artist `json:"similarArtist"`
+}
+
+type AlbumInfo struct {
+	Notes          string `json:"notes"`
+	MusicBrainzId  string `json:"musicBrainzId"`
+	LastFmUrl      string `json:"lastFmUrl"`
+	SmallImageUrl  string `json:"smallImageUrl"`
+	MediumImageUrl string `json:"mediumImageUrl"`
+	LargeImageUrl  string `json:"largeImageUrl"`
+}
+
+// GetArtistInfo2 fetches biography and similar artists of an artist, organized
+// by ID3 tags. count limits the number of similar artists.
+// https://www.subsonic.org/pages/api.jsp#getArtistInfo2
+func (connection *SubsonicConnection) GetArtistInfo2(id string, count int) (*SubsonicResponse, error) {
+	return connection.GetArtistInfo2Context(context.Background(), id, count)
+}
+
+func (connection *SubsonicConnection) GetArtistInfo2Context(ctx context.Context, id string, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getArtistInfo2" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetArtistInfo2", requestUrl)
+}
+
+// GetArtistInfo is like GetArtistInfo2 but takes the id of an artist folder,
+// as returned by GetIndexes.
+// https://www.subsonic.org/pages/api.jsp#getArtistInfo
+func (connection *SubsonicConnection) GetArtistInfo(id string, count int) (*SubsonicResponse, error) {
+	return connection.GetArtistInfoContext(context.Background(), id, count)
+}
+
+func (connection *SubsonicConnection) GetArtistInfoContext(ctx context.Context, id string, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getArtistInfo" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetArtistInfo", requestUrl)
+}
+
+// GetAlbumInfo2 fetches notes of an album, organized by ID3 tags.
+// https://www.subsonic.org/pages/api.jsp#getAlbumInfo2
+func (connection *SubsonicConnection) GetAlbumInfo2(id string) (*SubsonicResponse, error) {
+	return connection.GetAlbumInfo2Context(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetAlbumInfo2Context(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getAlbumInfo2" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetAlbumInfo2", requestUrl)
+}
+
+// GetAlbumInfo is like GetAlbumInfo2 but takes the id of an album folder.
+// https://www.subsonic.org/pages/api.jsp#getAlbumInfo
+func (connection *SubsonicConnection) GetAlbumInfo(id string) (*SubsonicResponse, error) {
+	return connection.GetAlbumInfoContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetAlbumInfoContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getAlbumInfo" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetAlbumInfo", requestUrl)
+}
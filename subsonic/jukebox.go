@@ -0,0 +1,150 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// JukeboxStatus is the state of the server-side player
+type JukeboxStatus struct {
+	// index of the playing song in the jukebox playlist, -1 if there's none
+	CurrentIndex int     `json:"currentIndex"`
+	Playing      bool    `json:"playing"`
+	Gain         float64 `json:"gain"`
+	// position in seconds
+	Position int `json:"position"`
+}
+
+type JukeboxPlaylist struct {
+	JukeboxStatus
+	Entry SubsonicEntities `json:"entry"`
+}
+
+// All jukebox actions share one endpoint, the status of the jukebox is
+// returned by all actions except "get".
+// https://www.subsonic.org/pages/api.jsp#jukeboxControl
+func (connection *SubsonicConnection) jukeboxControlContext(ctx context.Context, caller, action string, query url.Values) (*SubsonicResponse, error) {
+	query.Set("action", action)
+	requestUrl := connection.Host + "/rest/jukeboxControl" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, caller, requestUrl)
+}
+
+// JukeboxGet returns the jukebox playlist and status.
+func (connection *SubsonicConnection) JukeboxGet() (*SubsonicResponse, error) {
+	return connection.JukeboxGetContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxGetContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxGet", "get", defaultQuery(connection))
+}
+
+// JukeboxStatus returns the jukebox status.
+func (connection *SubsonicConnection) JukeboxStatus() (*SubsonicResponse, error) {
+	return connection.JukeboxStatusContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxStatusContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxStatus", "status", defaultQuery(connection))
+}
+
+// JukeboxSet replaces the jukebox playlist with the songs with the given ids.
+func (connection *SubsonicConnection) JukeboxSet(ids []string) (*SubsonicResponse, error) {
+	return connection.JukeboxSetContext(context.Background(), ids)
+}
+
+func (connection *SubsonicConnection) JukeboxSetContext(ctx context.Context, ids []string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	return connection.jukeboxControlContext(ctx, "JukeboxSet", "set", query)
+}
+
+// JukeboxAdd appends the songs with the given ids to the jukebox playlist.
+func (connection *SubsonicConnection) JukeboxAdd(ids []string) (*SubsonicResponse, error) {
+	return connection.JukeboxAddContext(context.Background(), ids)
+}
+
+func (connection *SubsonicConnection) JukeboxAddContext(ctx context.Context, ids []string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	return connection.jukeboxControlContext(ctx, "JukeboxAdd", "add", query)
+}
+
+// JukeboxRemove removes the song at index from the jukebox playlist.
+func (connection *SubsonicConnection) JukeboxRemove(index int) (*SubsonicResponse, error) {
+	return connection.JukeboxRemoveContext(context.Background(), index)
+}
+
+func (connection *SubsonicConnection) JukeboxRemoveContext(ctx context.Context, index int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("index", strconv.Itoa(index))
+	return connection.jukeboxControlContext(ctx, "JukeboxRemove", "remove", query)
+}
+
+// JukeboxClear empties the jukebox playlist.
+func (connection *SubsonicConnection) JukeboxClear() (*SubsonicResponse, error) {
+	return connection.JukeboxClearContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxClearContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxClear", "clear", defaultQuery(connection))
+}
+
+// JukeboxStart starts or resumes playback.
+func (connection *SubsonicConnection) JukeboxStart() (*SubsonicResponse, error) {
+	return connection.JukeboxStartContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxStartContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxStart", "start", defaultQuery(connection))
+}
+
+// JukeboxStop stops playback, starting again resumes at the same position.
+func (connection *SubsonicConnection) JukeboxStop() (*SubsonicResponse, error) {
+	return connection.JukeboxStopContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxStopContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxStop", "stop", defaultQuery(connection))
+}
+
+// JukeboxSkip plays the song at index, starting at offset seconds.
+func (connection *SubsonicConnection) JukeboxSkip(index, offset int) (*SubsonicResponse, error) {
+	return connection.JukeboxSkipContext(context.Background(), index, offset)
+}
+
+func (connection *SubsonicConnection) JukeboxSkipContext(ctx context.Context, index, offset int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("index", strconv.Itoa(index))
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	return connection.jukeboxControlContext(ctx, "JukeboxSkip", "skip", query)
+}
+
+// JukeboxShuffle shuffles the jukebox playlist.
+func (connection *SubsonicConnection) JukeboxShuffle() (*SubsonicResponse, error) {
+	return connection.JukeboxShuffleContext(context.Background())
+}
+
+func (connection *SubsonicConnection) JukeboxShuffleContext(ctx context.Context) (*SubsonicResponse, error) {
+	return connection.jukeboxControlContext(ctx, "JukeboxShuffle", "shuffle", defaultQuery(connection))
+}
+
+// JukeboxSetGain sets the volume of the jukebox, gain is between 0 and 1.
+func (connection *SubsonicConnection) JukeboxSetGain(gain float64) (*SubsonicResponse, error) {
+	return connection.JukeboxSetGainContext(context.Background(), gain)
+}
+
+func (connection *SubsonicConnection) JukeboxSetGainContext(ctx context.Context, gain float64) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("gain", strconv.FormatFloat(gain, 'f', 2, 64))
+	return connection.jukeboxControlContext(ctx, "JukeboxSetGain", "setGain", query)
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lyrics as returned by the original getLyrics endpoint
+type SubsonicLyrics struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Value  string `json:"value"`
+}
+
+type LyricsList struct {
+	StructuredLyrics []StructuredLyrics `json:"structuredLyrics"`
+}
+
+// StructuredLyrics is one set of lyrics of a song, a song can have several
+// (e.g. in different languages).
+type StructuredLyrics struct {
+	DisplayArtist string `json:"displayArtist"`
+	DisplayTitle  string `json:"displayTitle"`
+	Lang          string `json:"lang"`
+	// Offset in milliseconds, positive values make lyrics appear sooner
+	Offset int         `json:"offset"`
+	Synced bool        `json:"synced"`
+	Lines  []LyricLine `json:"line"`
+}
+
+type LyricLine struct {
+	// Start time in milliseconds, only set for synced lyrics
+	Start int    `json:"start"`
+	Value string `json:"value"`
+}
+
+// GetLyrics searches lyrics by artist and title.
+// https://www.subsonic.org/pages/api.jsp#getLyrics
+func (connection *SubsonicConnection) GetLyrics(artist, title string) (*SubsonicResponse, error) {
+	return connection.GetLyricsContext(context.Background(), artist, title)
+}
+
+func (connection *SubsonicConnection) GetLyricsContext(ctx context.Context, artist, title string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("artist", artist)
+	query.Set("title", title)
+	requestUrl := connection.Host + "/rest/getLyrics" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetLyrics", requestUrl)
+}
+
+// GetLyricsBySongId fetches the (possibly synced) lyrics of a song. This needs
+// the OpenSubsonic songLyrics extension.
+// https://opensubsonic.netlify.app/docs/endpoints/getlyricsbysongid/
+func (connection *SubsonicConnection) GetLyricsBySongId(id string) (*SubsonicResponse, error) {
+	return connection.GetLyricsBySongIdContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) GetLyricsBySongIdContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/getLyricsBySongId" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetLyricsBySongId", requestUrl)
+}
+
+// GetSongLyrics returns the lyrics of a song, preferring getLyricsBySongId if
+// the server supports it and falling back to getLyrics otherwise. The result
+// is empty if the server has no lyrics for the song.
+func (connection *SubsonicConnection) GetSongLyrics(id, artist, title string) ([]StructuredLyrics, error) {
+	return connection.GetSongLyricsContext(context.Background(), id, artist, title)
+}
+
+func (connection *SubsonicConnection) GetSongLyricsContext(ctx context.Context, id, artist, title string) ([]StructuredLyrics, error) {
+	if connection.HasExtension(ExtensionSongLyrics) {
+		resp, err := connection.GetLyricsBySongIdContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.LyricsList.StructuredLyrics) > 0 {
+			return resp.LyricsList.StructuredLyrics, nil
+		}
+	}
+
+	resp, err := connection.GetLyricsContext(ctx, artist, title)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.Lyrics.Value) == "" {
+		return nil, nil
+	}
+	lyrics := ParseLrc(resp.Lyrics.Value)
+	lyrics.DisplayArtist = resp.Lyrics.Artist
+	lyrics.DisplayTitle = resp.Lyrics.Title
+	return []StructuredLyrics{lyrics}, nil
+}
+
+// matches one or more leading LRC timestamps like [01:23.45]
+var lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// ParseLrc converts lyrics text into StructuredLyrics. Some servers return LRC
+// formatted text from getLyrics, in that case the result is synced. Lines with
+// several timestamps are repeated for each of them, LRC tags like [ar:...] are
+// dropped.
+func ParseLrc(text string) (lyrics StructuredLyrics) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		var starts []int
+		for {
+			match := lrcTimestamp.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(match[1])
+			seconds, _ := strconv.Atoi(match[2])
+			millis := 0
+			if match[3] != "" {
+				// .4 is 400ms, .45 is 450ms, .456 is 456ms
+				fraction := (match[3] + "00")[:3]
+				millis, _ = strconv.Atoi(fraction)
+			}
+			starts = append(starts, (minutes*60+seconds)*1000+millis)
+			line = line[len(match[0]):]
+		}
+
+		if len(starts) == 0 {
+			if isLrcTag(line) {
+				continue
+			}
+			lyrics.Lines = append(lyrics.Lines, LyricLine{Value: line})
+			continue
+		}
+
+		lyrics.Synced = true
+		for _, start := range starts {
+			lyrics.Lines = append(lyrics.Lines, LyricLine{Start: start, Value: strings.TrimSpace(line)})
+		}
+	}
+
+	if lyrics.Synced {
+		// drop unsynced lines (usually empty ones) and sort by time
+		lines := lyrics.Lines[:0]
+		for _, line := range lyrics.Lines {
+			if line.Start > 0 || line.Value != "" {
+				lines = append(lines, line)
+			}
+		}
+		lyrics.Lines = lines
+		sort.SliceStable(lyrics.Lines, func(i, j int) bool {
+			return lyrics.Lines[i].Start < lyrics.Lines[j].Start
+		})
+	} else {
+		// trim surrounding empty lines
+		for len(lyrics.Lines) > 0 && strings.TrimSpace(lyrics.Lines[0].Value) == "" {
+			lyrics.Lines = lyrics.Lines[1:]
+		}
+		for len(lyrics.Lines) > 0 && strings.TrimSpace(lyrics.Lines[len(lyrics.Lines)-1].Value) == "" {
+			lyrics.Lines = lyrics.Lines[:len(lyrics.Lines)-1]
+		}
+	}
+
+	return
+}
+
+// isLrcTag reports whether line is an LRC id tag like [ar:Artist]
+func isLrcTag(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && strings.Contains(line, ":")
+}
+
+// CurrentLine returns the index of the line that is sung at the given playback
+// position (in milliseconds), or -1 if the lyrics aren't synced or the first
+// line hasn't started yet.
+func (lyrics *StructuredLyrics) CurrentLine(positionMs int) int {
+	if !lyrics.Synced {
+		return -1
+	}
+	current := -1
+	for i, line := range lyrics.Lines {
+		if line.Start-lyrics.Offset > positionMs {
+			break
+		}
+		current = i
+	}
+	return current
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+type MusicFolders struct {
+	MusicFolder []MusicFolder `json:"musicFolder"`
+}
+
+type MusicFolder struct {
+	Id   SubsonicId `json:"id"`
+	Name string     `json:"name"`
+}
+
+// Find returns the folder with the given id or name, names are compared
+// case-insensitively.
+func (m MusicFolders) Find(idOrName string) (MusicFolder, bool) {
+	for _, folder := range m.MusicFolder {
+		if string(folder.Id) == idOrName || strings.EqualFold(folder.Name, idOrName) {
+			return folder, true
+		}
+	}
+	return MusicFolder{}, false
+}
+
+// GetMusicFolders lists the music folders (libraries) of the server.
+// https://www.subsonic.org/pages/api.jsp#getMusicFolders
+func (connection *SubsonicConnection) GetMusicFolders() (*SubsonicResponse, error) {
+	return connection.GetMusicFoldersContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetMusicFoldersContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getMusicFolders" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetMusicFolders", requestUrl)
+}
+
+// SetMusicFolderId limits listings, random songs and searches to one music
+// folder (library), empty means all folders
+func (connection *SubsonicConnection) SetMusicFolderId(id string) {
+	connection.musicFolderMutex.Lock()
+	defer connection.musicFolderMutex.Unlock()
+	connection.musicFolderId = id
+}
+
+// MusicFolderId returns the folder set by SetMusicFolderId
+func (connection *SubsonicConnection) MusicFolderId() string {
+	connection.musicFolderMutex.Lock()
+	defer connection.musicFolderMutex.Unlock()
+	return connection.musicFolderId
+}
+
+// setMusicFolder limits a listing, random or search request to the music folder
+func (connection *SubsonicConnection) setMusicFolder(query url.Values) {
+	if id := connection.MusicFolderId(); id != "" {
+		query.Set("musicFolderId", id)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+)
+
+type NowPlaying struct {
+	Entry []NowPlayingEntry `json:"entry"`
+}
+
+// NowPlayingEntry is a song that someone is playing right now
+type NowPlayingEntry struct {
+	SubsonicEntity
+	Username   string `json:"username"`
+	MinutesAgo int    `json:"minutesAgo"`
+	PlayerId   int    `json:"playerId"`
+	PlayerName string `json:"playerName"`
+}
+
+// GetNowPlaying lists what all users of the server are currently playing.
+// https://www.subsonic.org/pages/api.jsp#getNowPlaying
+func (connection *SubsonicConnection) GetNowPlaying() (*SubsonicResponse, error) {
+	return connection.GetNowPlayingContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetNowPlayingContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getNowPlaying" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetNowPlaying", requestUrl)
+}
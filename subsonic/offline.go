@@ -0,0 +1,317 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrOffline is returned by requests that can't be answered from the snapshot
+// while the connection is offline
+var ErrOffline = errors.New("server is offline")
+
+const (
+	snapshotFile = "snapshot.json"
+	journalFile  = "journal.json"
+)
+
+// snapshotEndpoints are read-only requests whose responses are kept in the
+// snapshot to answer them offline
+var snapshotEndpoints = map[string]bool{
+	"getIndexes":        true,
+	"getMusicDirectory": true,
+	"getMusicFolders":   true,
+	"getArtist":         true,
+	"getAlbum":          true,
+	"getPlaylists":      true,
+	"getPlaylist":       true,
+	"getStarred":        true,
+	"getStarred2":       true,
+}
+
+// journalEndpoints change data on the server, while offline they are written
+// to the journal and replayed by Reconnect
+var journalEndpoints = map[string]bool{
+	"star":           true,
+	"unstar":         true,
+	"setRating":      true,
+	"scrobble":       true,
+	"createPlaylist": true,
+	"updatePlaylist": true,
+	"deletePlaylist": true,
+}
+
+// authParameters identify the client and user, they aren't part of snapshot
+// keys and journal entries
+var authParameters = []string{"u", "p", "t", "s", "apiKey", "v", "c", "f"}
+
+// snapshot holds the responses of snapshotEndpoints by request, see
+// snapshotKey
+type snapshot struct {
+	Host      string                      `json:"host"`
+	Username  string                      `json:"username"`
+	Responses map[string]SubsonicResponse `json:"responses"`
+}
+
+type journalEntry struct {
+	Endpoint string     `json:"endpoint"`
+	Query    url.Values `json:"query"`
+}
+
+// EnableSnapshot keeps the responses the server sent in dir so they can be
+// browsed offline, the snapshot of a previous run is loaded. Changes made while
+// offline are journaled there too.
+func (connection *SubsonicConnection) EnableSnapshot(dir string) error {
+	connection.offlineMutex.Lock()
+	defer connection.offlineMutex.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	connection.offlineDir = dir
+	connection.snapshot = snapshot{
+		Host:      connection.Host,
+		Username:  connection.Username,
+		Responses: map[string]SubsonicResponse{},
+	}
+
+	var loaded snapshot
+	if err := readJson(filepath.Join(dir, snapshotFile), &loaded); err != nil {
+		return err
+	}
+	// a snapshot of another server or user is useless
+	if loaded.Host == connection.Host && loaded.Username == connection.Username && loaded.Responses != nil {
+		connection.snapshot = loaded
+	}
+
+	return readJson(filepath.Join(dir, journalFile), &connection.journal)
+}
+
+// HasSnapshot reports whether there's something to browse offline
+func (connection *SubsonicConnection) HasSnapshot() bool {
+	connection.offlineMutex.Lock()
+	defer connection.offlineMutex.Unlock()
+	return len(connection.snapshot.Responses) > 0
+}
+
+// SaveSnapshot writes the snapshot to disk if responses were added since it
+// was last saved
+func (connection *SubsonicConnection) SaveSnapshot() error {
+	connection.snapshotSaveMutex.Lock()
+	defer connection.snapshotSaveMutex.Unlock()
+
+	connection.offlineMutex.Lock()
+	if connection.offlineDir == "" || !connection.snapshotChanged {
+		connection.offlineMutex.Unlock()
+		return nil
+	}
+	// the responses aren't modified, a copy of the map is enough to write it
+	// without blocking requests
+	saved := connection.snapshot
+	saved.Responses = maps.Clone(saved.Responses)
+	path := filepath.Join(connection.offlineDir, snapshotFile)
+	connection.snapshotChanged = false
+	connection.offlineMutex.Unlock()
+
+	if err := writeJson(path, saved); err != nil {
+		connection.offlineMutex.Lock()
+		connection.snapshotChanged = true
+		connection.offlineMutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// SetOffline makes requests use the snapshot instead of the server. Changes
+// are journaled until Reconnect succeeds, everything else fails with
+// ErrOffline.
+func (connection *SubsonicConnection) SetOffline(offline bool) {
+	connection.offline.Store(offline)
+}
+
+func (connection *SubsonicConnection) IsOffline() bool {
+	return connection.offline.Load()
+}
+
+// JournalLength returns the number of changes waiting to be sent to the server
+func (connection *SubsonicConnection) JournalLength() int {
+	connection.offlineMutex.Lock()
+	defer connection.offlineMutex.Unlock()
+	return len(connection.journal)
+}
+
+// Reconnect checks whether the server is reachable. If it is, the journal is
+// replayed and the connection goes online once it's empty. Journaled changes
+// the server rejects are dropped, the number of replayed changes is returned.
+func (connection *SubsonicConnection) Reconnect() (int, error) {
+	return connection.ReconnectContext(context.Background())
+}
+
+func (connection *SubsonicConnection) ReconnectContext(ctx context.Context) (int, error) {
+	connection.reconnectMutex.Lock()
+	defer connection.reconnectMutex.Unlock()
+
+	query := defaultQuery(connection)
+	if _, err := connection.request(ctx, "Reconnect", connection.Host+"/rest/ping"+"?"+query.Encode()); err != nil {
+		return 0, err
+	}
+
+	// The connection stays offline until the journal is empty, changes made
+	// in the meantime are journaled after the ones being replayed. Entries
+	// stay in the journal until they're replayed so a crash doesn't lose them.
+	replayed := 0
+	var replayErr error
+	for {
+		connection.offlineMutex.Lock()
+		entries := slices.Clone(connection.journal)
+		if len(entries) == 0 {
+			connection.SetOffline(false)
+			connection.offlineMutex.Unlock()
+			return replayed, replayErr
+		}
+		connection.offlineMutex.Unlock()
+
+		done := 0
+		var err error
+		for _, entry := range entries {
+			if err = connection.replay(ctx, entry); err != nil {
+				var apiError *APIError
+				if !errors.As(err, &apiError) {
+					// try again next time
+					break
+				}
+				// the server rejected it, it's dropped
+				replayErr = errors.Join(replayErr, err)
+			} else {
+				replayed++
+			}
+			done++
+		}
+
+		connection.offlineMutex.Lock()
+		connection.journal = connection.journal[done:]
+		if done > 0 && connection.offlineDir != "" {
+			if err := writeJson(filepath.Join(connection.offlineDir, journalFile), connection.journal); err != nil {
+				replayErr = errors.Join(replayErr, err)
+			}
+		}
+		connection.offlineMutex.Unlock()
+
+		if done < len(entries) {
+			return replayed, errors.Join(replayErr, err)
+		}
+	}
+}
+
+// replay sends a journaled change to the server
+func (connection *SubsonicConnection) replay(ctx context.Context, entry journalEntry) error {
+	query := defaultQuery(connection)
+	for key, values := range entry.Query {
+		query[key] = values
+	}
+	requestUrl := connection.Host + "/rest/" + entry.Endpoint + "?" + query.Encode()
+	_, err := connection.request(ctx, "Reconnect", requestUrl)
+	return err
+}
+
+// snapshotKey splits a request url into its endpoint and the query without
+// authentication, which identifies the request
+func snapshotKey(requestUrl string) (endpoint string, query url.Values) {
+	parsed, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", nil
+	}
+	endpoint = parsed.Path[strings.LastIndex(parsed.Path, "/")+1:]
+	query = parsed.Query()
+	for _, name := range authParameters {
+		query.Del(name)
+	}
+	return endpoint, query
+}
+
+// remember puts a successful response into the snapshot if it's enabled
+func (connection *SubsonicConnection) remember(requestUrl string, response *SubsonicResponse) {
+	endpoint, query := snapshotKey(requestUrl)
+	if !snapshotEndpoints[endpoint] {
+		return
+	}
+
+	connection.offlineMutex.Lock()
+	defer connection.offlineMutex.Unlock()
+	if connection.snapshot.Responses != nil {
+		connection.snapshot.Responses[endpoint+"?"+query.Encode()] = *response
+		connection.snapshotChanged = true
+	}
+}
+
+// offlineResponse answers a request from the snapshot or journals it
+func (connection *SubsonicConnection) offlineResponse(ctx context.Context, caller, requestUrl string) (*SubsonicResponse, error) {
+	endpoint, query := snapshotKey(requestUrl)
+
+	connection.offlineMutex.Lock()
+	if !connection.IsOffline() {
+		// Reconnect finished replaying the journal in the meantime
+		connection.offlineMutex.Unlock()
+		return connection.getResponseContext(ctx, caller, requestUrl)
+	}
+	defer connection.offlineMutex.Unlock()
+
+	if snapshotEndpoints[endpoint] {
+		if response, ok := connection.snapshot.Responses[endpoint+"?"+query.Encode()]; ok {
+			return &response, nil
+		}
+	} else if journalEndpoints[endpoint] && connection.offlineDir != "" {
+		if endpoint == "scrobble" {
+			if query.Get("submission") == "false" {
+				// it's too late to tell what's playing now
+				return &SubsonicResponse{Status: "ok"}, nil
+			}
+			if !query.Has("time") {
+				query.Set("time", strconv.FormatInt(time.Now().UnixMilli(), 10))
+			}
+		}
+
+		connection.journal = append(connection.journal, journalEntry{Endpoint: endpoint, Query: query})
+		if err := writeJson(filepath.Join(connection.offlineDir, journalFile), connection.journal); err != nil {
+			return nil, fmt.Errorf("[%s] failed to write journal: %w", caller, err)
+		}
+		return &SubsonicResponse{Status: "ok"}, nil
+	}
+	return nil, fmt.Errorf("[%s] %w", caller, ErrOffline)
+}
+
+// readJson leaves v alone if the file doesn't exist
+func readJson(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJson replaces the file at path, it doesn't leave a truncated file
+// behind if writing fails
+func writeJson(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".new", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
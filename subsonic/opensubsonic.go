@@ -0,0 +1,90 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OpenSubsonic extension names
+// https://opensubsonic.netlify.app/docs/extensions/
+const (
+	ExtensionApiKeyAuthentication = "apiKeyAuthentication"
+	ExtensionFormPost             = "formPost"
+	ExtensionSongLyrics           = "songLyrics"
+	ExtensionTranscodeOffset      = "transcodeOffset"
+)
+
+// GetOpenSubsonicExtensions asks the server which OpenSubsonic extensions it
+// supports and records them, so they can be checked with HasExtension.
+// Servers that only implement the Subsonic API return an error here, which
+// leaves the connection without extensions.
+// https://opensubsonic.netlify.app/docs/endpoints/getopensubsonicextensions/
+func (connection *SubsonicConnection) GetOpenSubsonicExtensions() (*SubsonicResponse, error) {
+	return connection.GetOpenSubsonicExtensionsContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetOpenSubsonicExtensionsContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getOpenSubsonicExtensions" + "?" + query.Encode()
+	resp, err := connection.getResponseContext(ctx, "GetOpenSubsonicExtensions", requestUrl)
+	if resp != nil {
+		// the API version is part of every response, even failed ones
+		connection.apiVersion = resp.Version
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	connection.openSubsonic = resp.OpenSubsonic
+	connection.extensions = make(map[string][]int, len(resp.OpenSubsonicExtensions))
+	for _, extension := range resp.OpenSubsonicExtensions {
+		connection.extensions[extension.Name] = extension.Versions
+	}
+
+	return resp, nil
+}
+
+// IsOpenSubsonic reports whether the server announced OpenSubsonic support.
+func (connection *SubsonicConnection) IsOpenSubsonic() bool {
+	return connection.openSubsonic
+}
+
+// HasExtension reports whether the server supports the OpenSubsonic extension
+// with the given name, in any version.
+func (connection *SubsonicConnection) HasExtension(name string) bool {
+	_, ok := connection.extensions[name]
+	return ok
+}
+
+// HasExtensionVersion reports whether the server supports the given version of
+// an OpenSubsonic extension.
+func (connection *SubsonicConnection) HasExtensionVersion(name string, version int) bool {
+	return slices.Contains(connection.extensions[name], version)
+}
+
+// SupportsApiVersion reports whether the server's Subsonic API version is at
+// least the given one, e.g. "1.8.0". This is unknown (false) until a request
+// has returned the server version, see GetOpenSubsonicExtensions.
+func (connection *SubsonicConnection) SupportsApiVersion(version string) bool {
+	if connection.apiVersion == "" {
+		return false
+	}
+	have := strings.Split(connection.apiVersion, ".")
+	want := strings.Split(version, ".")
+	for i := range want {
+		w, _ := strconv.Atoi(want[i])
+		h := 0
+		if i < len(have) {
+			h, _ = strconv.Atoi(have[i])
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"strconv"
+)
+
+// status of podcast channels and episodes
+const (
+	PodcastStatusNew         = "new"
+	PodcastStatusDownloading = "downloading"
+	PodcastStatusCompleted   = "completed"
+	PodcastStatusError       = "error"
+	PodcastStatusDeleted     = "deleted"
+	PodcastStatusSkipped     = "skipped"
+)
+
+type Podcasts struct {
+	Channel []PodcastChannel `json:"channel"`
+}
+
+type NewestPodcasts struct {
+	Episode []PodcastEpisode `json:"episode"`
+}
+
+type PodcastChannel struct {
+	Id           string           `json:"id"`
+	Url          string           `json:"url"`
+	Title        string           `json:"title"`
+	Description  string           `json:"description"`
+	CoverArtId   string           `json:"coverArt"`
+	Status       string           `json:"status"`
+	ErrorMessage string           `json:"errorMessage"`
+	Episode      []PodcastEpisode `json:"episode"`
+}
+
+func (c PodcastChannel) ID() string {
+	return c.Id
+}
+
+type PodcastEpisode struct {
+	Id          string `json:"id"`
+	StreamId    string `json:"streamId"`
+	ChannelId   string `json:"channelId"`
+	Title       string `json:"title"`
+	Album       string `json:"album"`
+	Artist      string `json:"artist"`
+	Description string `json:"description"`
+	PublishDate string `json:"publishDate"`
+	Status      string `json:"status"`
+	Duration    int    `json:"duration"`
+	Size        int64  `json:"size"`
+	CoverArtId  string `json:"coverArt"`
+}
+
+func (e PodcastEpisode) ID() string {
+	return e.Id
+}
+
+// IsPlayable reports whether the server has downloaded the episode, only
+// then it can be streamed
+func (e PodcastEpisode) IsPlayable() bool {
+	return e.Status == PodcastStatusCompleted && e.StreamId != ""
+}
+
+// GetPodcasts lists the podcast channels, with their episodes if
+// includeEpisodes is set. If id isn't empty only that channel is returned.
+// https://www.subsonic.org/pages/api.jsp#getPodcasts
+func (connection *SubsonicConnection) GetPodcasts(includeEpisodes bool, id string) (*SubsonicResponse, error) {
+	return connection.GetPodcastsContext(context.Background(), includeEpisodes, id)
+}
+
+func (connection *SubsonicConnection) GetPodcastsContext(ctx context.Context, includeEpisodes bool, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("includeEpisodes", strconv.FormatBool(includeEpisodes))
+	if id != "" {
+		query.Set("id", id)
+	}
+	requestUrl := connection.Host + "/rest/getPodcasts" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetPodcasts", requestUrl)
+}
+
+// GetNewestPodcasts lists the count most recently published episodes of all
+// channels.
+// https://www.subsonic.org/pages/api.jsp#getNewestPodcasts
+func (connection *SubsonicConnection) GetNewestPodcasts(count int) (*SubsonicResponse, error) {
+	return connection.GetNewestPodcastsContext(context.Background(), count)
+}
+
+func (connection *SubsonicConnection) GetNewestPodcastsContext(ctx context.Context, count int) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("count", strconv.Itoa(count))
+	requestUrl := connection.Host + "/rest/getNewestPodcasts" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetNewestPodcasts", requestUrl)
+}
+
+// CreatePodcastChannel subscribes to the podcast feed at url. This needs
+// podcast permissions on most servers.
+// https://www.subsonic.org/pages/api.jsp#createPodcastChannel
+func (connection *SubsonicConnection) CreatePodcastChannel(url string) (*SubsonicResponse, error) {
+	return connection.CreatePodcastChannelContext(context.Background(), url)
+}
+
+func (connection *SubsonicConnection) CreatePodcastChannelContext(ctx context.Context, url string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("url", url)
+	requestUrl := connection.Host + "/rest/createPodcastChannel" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "CreatePodcastChannel", requestUrl)
+}
+
+// DownloadPodcastEpisode asks the server to download an episode, it can be
+// streamed once its status is completed.
+// https://www.subsonic.org/pages/api.jsp#downloadPodcastEpisode
+func (connection *SubsonicConnection) DownloadPodcastEpisode(id string) (*SubsonicResponse, error) {
+	return connection.DownloadPodcastEpisodeContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DownloadPodcastEpisodeContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/downloadPodcastEpisode" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "DownloadPodcastEpisode", requestUrl)
+}
+
+// DeletePodcastEpisode deletes the downloaded episode from the server.
+// https://www.subsonic.org/pages/api.jsp#deletePodcastEpisode
+func (connection *SubsonicConnection) DeletePodcastEpisode(id string) (*SubsonicResponse, error) {
+	return connection.DeletePodcastEpisodeContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DeletePodcastEpisodeContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deletePodcastEpisode" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "DeletePodcastEpisode", requestUrl)
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+)
+
+type InternetRadioStations struct {
+	InternetRadioStation []InternetRadioStation `json:"internetRadioStation"`
+}
+
+type InternetRadioStation struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	StreamUrl   string `json:"streamUrl"`
+	HomePageUrl string `json:"homePageUrl"`
+}
+
+func (s InternetRadioStation) ID() string {
+	return s.Id
+}
+
+// GetInternetRadioStations lists all internet radio stations.
+// https://www.subsonic.org/pages/api.jsp#getInternetRadioStations
+func (connection *SubsonicConnection) GetInternetRadioStations() (*SubsonicResponse, error) {
+	return connection.GetInternetRadioStationsContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetInternetRadioStationsContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getInternetRadioStations" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetInternetRadioStations", requestUrl)
+}
+
+// CreateInternetRadioStation adds a station, homePageUrl is optional. This
+// needs admin permissions on most servers.
+// https://www.subsonic.org/pages/api.jsp#createInternetRadioStation
+func (connection *SubsonicConnection) CreateInternetRadioStation(streamUrl, name, homePageUrl string) (*SubsonicResponse, error) {
+	return connection.CreateInternetRadioStationContext(context.Background(), streamUrl, name, homePageUrl)
+}
+
+func (connection *SubsonicConnection) CreateInternetRadioStationContext(ctx context.Context, streamUrl, name, homePageUrl string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("streamUrl", streamUrl)
+	query.Set("name", name)
+	if homePageUrl != "" {
+		query.Set("homepageUrl", homePageUrl)
+	}
+	requestUrl := connection.Host + "/rest/createInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "CreateInternetRadioStation", requestUrl)
+}
+
+// UpdateInternetRadioStation changes an existing station.
+// https://www.subsonic.org/pages/api.jsp#updateInternetRadioStation
+func (connection *SubsonicConnection) UpdateInternetRadioStation(id, streamUrl, name, homePageUrl string) (*SubsonicResponse, error) {
+	return connection.UpdateInternetRadioStationContext(context.Background(), id, streamUrl, name, homePageUrl)
+}
+
+func (connection *SubsonicConnection) UpdateInternetRadioStationContext(ctx context.Context, id, streamUrl, name, homePageUrl string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("streamUrl", streamUrl)
+	query.Set("name", name)
+	if homePageUrl != "" {
+		query.Set("homepageUrl", homePageUrl)
+	}
+	requestUrl := connection.Host + "/rest/updateInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "UpdateInternetRadioStation", requestUrl)
+}
+
+// DeleteInternetRadioStation removes a station.
+// https://www.subsonic.org/pages/api.jsp#deleteInternetRadioStation
+func (connection *SubsonicConnection) DeleteInternetRadioStation(id string) (*SubsonicResponse, error) {
+	return connection.DeleteInternetRadioStationContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DeleteInternetRadioStationContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteInternetRadioStation" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "DeleteInternetRadioStation", requestUrl)
+}
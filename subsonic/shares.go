@@ -0,0 +1,95 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+type Shares struct {
+	Share []Share `json:"share"`
+}
+
+type Share struct {
+	Id          string           `json:"id"`
+	Url         string           `json:"url"`
+	Description string           `json:"description"`
+	Username    string           `json:"username"`
+	Created     string           `json:"created"`
+	Expires     string           `json:"expires"`
+	LastVisited string           `json:"lastVisited"`
+	VisitCount  int              `json:"visitCount"`
+	Entry       SubsonicEntities `json:"entry"`
+}
+
+func (s Share) ID() string {
+	return s.Id
+}
+
+// GetShares lists the shares of the user.
+// https://www.subsonic.org/pages/api.jsp#getShares
+func (connection *SubsonicConnection) GetShares() (*SubsonicResponse, error) {
+	return connection.GetSharesContext(context.Background())
+}
+
+func (connection *SubsonicConnection) GetSharesContext(ctx context.Context) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	requestUrl := connection.Host + "/rest/getShares" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "GetShares", requestUrl)
+}
+
+// CreateShare creates a public link to the songs, albums or playlists with the
+// given ids. description is optional, a zero expires never expires.
+// https://www.subsonic.org/pages/api.jsp#createShare
+func (connection *SubsonicConnection) CreateShare(ids []string, description string, expires time.Time) (*SubsonicResponse, error) {
+	return connection.CreateShareContext(context.Background(), ids, description, expires)
+}
+
+func (connection *SubsonicConnection) CreateShareContext(ctx context.Context, ids []string, description string, expires time.Time) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+	if description != "" {
+		query.Set("description", description)
+	}
+	if !expires.IsZero() {
+		query.Set("expires", strconv.FormatInt(expires.UnixMilli(), 10))
+	}
+	requestUrl := connection.Host + "/rest/createShare" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "CreateShare", requestUrl)
+}
+
+// UpdateShare changes description and expiry of a share, a zero expires
+// leaves the expiry unchanged.
+// https://www.subsonic.org/pages/api.jsp#updateShare
+func (connection *SubsonicConnection) UpdateShare(id, description string, expires time.Time) (*SubsonicResponse, error) {
+	return connection.UpdateShareContext(context.Background(), id, description, expires)
+}
+
+func (connection *SubsonicConnection) UpdateShareContext(ctx context.Context, id, description string, expires time.Time) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	query.Set("description", description)
+	if !expires.IsZero() {
+		query.Set("expires", strconv.FormatInt(expires.UnixMilli(), 10))
+	}
+	requestUrl := connection.Host + "/rest/updateShare" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "UpdateShare", requestUrl)
+}
+
+// DeleteShare revokes a share, its link stops working.
+// https://www.subsonic.org/pages/api.jsp#deleteShare
+func (connection *SubsonicConnection) DeleteShare(id string) (*SubsonicResponse, error) {
+	return connection.DeleteShareContext(context.Background(), id)
+}
+
+func (connection *SubsonicConnection) DeleteShareContext(ctx context.Context, id string) (*SubsonicResponse, error) {
+	query := defaultQuery(connection)
+	query.Set("id", id)
+	requestUrl := connection.Host + "/rest/deleteShare" + "?" + query.Encode()
+	return connection.getResponseContext(ctx, "DeleteShare", requestUrl)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package subsonic
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// StreamProfile selects the format songs are streamed in, e.g. raw files at
+// home and low bit rate opus over a VPN. The zero value leaves it to the
+// server.
+type StreamProfile struct {
+	Name string
+	// MaxBitRate in kbit/s, 0 means no limit
+	MaxBitRate int
+	// Format like "mp3" or "opus", "raw" disables transcoding
+	Format string
+	// TimeOffset lets the server start streams at a position instead of
+	// seeking in them, this needs the transcodeOffset extension
+	TimeOffset bool
+}
+
+func (profile StreamProfile) apply(query url.Values) {
+	query.Del("maxBitRate")
+	query.Del("format")
+	if profile.MaxBitRate > 0 {
+		query.Set("maxBitRate", strconv.Itoa(profile.MaxBitRate))
+	}
+	if profile.Format != "" {
+		query.Set("format", profile.Format)
+	}
+}
+
+// CanTimeOffset reports whether streams should be started at a position with
+// PlayUrlWithTimeOffset rather than by seeking
+func (connection *SubsonicConnection) CanTimeOffset() bool {
+	return connection.StreamProfile.TimeOffset && connection.HasExtension(ExtensionTranscodeOffset)
+}
+
+// ResolvePlayUrl updates a url made by GetPlayUrl to the current StreamProfile.
+// Other urls, like radio streams, are returned unchanged.
+func (connection *SubsonicConnection) ResolvePlayUrl(uri string) string {
+	return connection.rewritePlayUrl(uri, connection.StreamProfile.apply)
+}
+
+// PlayUrlWithTimeOffset makes a url made by GetPlayUrl start at offset seconds,
+// see CanTimeOffset.
+// https://opensubsonic.netlify.app/docs/extensions/transcodeoffset/
+func (connection *SubsonicConnection) PlayUrlWithTimeOffset(uri string, offset int) string {
+	return connection.rewritePlayUrl(uri, func(query url.Values) {
+		if offset > 0 {
+			query.Set("timeOffset", strconv.Itoa(offset))
+		} else {
+			query.Del("timeOffset")
+		}
+	})
+}
+
+// IsPlayUrl reports whether uri was made by GetPlayUrl, as opposed to e.g. a
+// radio stream or a downloaded file
+func (connection *SubsonicConnection) IsPlayUrl(uri string) bool {
+	return strings.HasPrefix(uri, connection.Host+"/rest/stream?")
+}
+
+func (connection *SubsonicConnection) rewritePlayUrl(uri string, rewrite func(query url.Values)) string {
+	if !connection.IsPlayUrl(uri) {
+		return uri
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	rewrite(query)
+	return connection.Host + "/rest/stream" + "?" + query.Encode()
+}
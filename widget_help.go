@@ -64,6 +64,33 @@ func (h *HelpWidget) RenderHelp(context string) {
 	case PageSearch:
 		rightText = "[::b]Search[::-]\n" + tview.Escape(strings.TrimSpace(helpSearchPage))
 
+	case PageAlbums:
+		rightText = "[::b]Albums[::-]\n" + tview.Escape(strings.TrimSpace(helpPageAlbums))
+
+	case PageGenres:
+		rightText = "[::b]Genres[::-]\n" + tview.Escape(strings.TrimSpace(helpPageGenres))
+
+	case PageRadio:
+		rightText = "[::b]Radio[::-]\n" + tview.Escape(strings.TrimSpace(helpPageRadio))
+
+	case PagePodcasts:
+		rightText = "[::b]Podcasts[::-]\n" + tview.Escape(strings.TrimSpace(helpPagePodcasts))
+
+	case PageStarred:
+		rightText = "[::b]Starred[::-]\n" + tview.Escape(strings.TrimSpace(helpPageStarred))
+
+	case PageNowPlaying:
+		rightText = "[::b]Now Playing[::-]\n" + tview.Escape(strings.TrimSpace(helpPageNowPlaying))
+
+	case PageShares:
+		rightText = "[::b]Shares[::-]\n" + tview.Escape(strings.TrimSpace(helpPageShares))
+
+	case PageDownloads:
+		rightText = "[::b]Downloads[::-]\n" + tview.Escape(strings.TrimSpace(helpPageDownloads))
+
+	case PageBookmarks:
+		rightText = "[::b]Bookmarks[::-]\n" + tview.Escape(strings.TrimSpace(helpPageBookmarks))
+
 	case PageLog:
 		fallthrough
 	default:
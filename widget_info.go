@@ -0,0 +1,206 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// InfoWidget shows biography and similar artists of an artist and the notes
+// of an album
+type InfoWidget struct {
+	Root *tview.Flex
+
+	text        *tview.TextView
+	similarList *tview.List
+
+	// what is shown (or being fetched)
+	artistId   string
+	artistName string
+	albumId    string
+	albumName  string
+
+	artistInfo     *subsonic.ArtistInfo
+	albumInfo      *subsonic.AlbumInfo
+	similarArtists []subsonic.Artist
+
+	// external references
+	ui *Ui
+}
+
+func (ui *Ui) createInfoWidget() (i *InfoWidget) {
+	i = &InfoWidget{
+		ui: ui,
+	}
+
+	i.text = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(true).
+		SetWordWrap(true)
+	i.text.Box.
+		SetTitle(" info ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	i.similarList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFocusOnly(true)
+	i.similarList.Box.
+		SetTitle(" similar artists ").
+		SetTitleAlign(tview.AlignLeft).
+		SetBorder(true)
+
+	i.Root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(i.text, 0, 2, false).
+		AddItem(i.similarList, 0, 1, false)
+
+	return
+}
+
+// Load fetches artist and album info in the background, unless it's already
+// shown. albumId may be empty if no album is open. Must be called from the UI
+// goroutine.
+func (i *InfoWidget) Load(artistId, artistName, albumId, albumName string) {
+	if artistId != i.artistId {
+		i.artistId = artistId
+		i.artistName = artistName
+		i.artistInfo = nil
+		i.similarArtists = nil
+		i.similarList.Clear()
+		if artistId != "" {
+			go i.fetchArtistInfo(artistId)
+		}
+	}
+
+	if albumId != i.albumId {
+		i.albumId = albumId
+		i.albumName = albumName
+		i.albumInfo = nil
+		if albumId != "" {
+			go i.fetchAlbumInfo(albumId)
+		}
+	}
+
+	i.render()
+}
+
+// Clear forgets what is shown so the next Load fetches it again
+func (i *InfoWidget) Clear() {
+	i.artistId = ""
+	i.albumId = ""
+	i.artistInfo = nil
+	i.albumInfo = nil
+	i.similarArtists = nil
+	i.similarList.Clear()
+	i.text.Clear()
+}
+
+// GetSelectedSimilarArtist returns the similar artist selected in the list
+func (i *InfoWidget) GetSelectedSimilarArtist() (subsonic.Artist, bool) {
+	index := i.similarList.GetCurrentItem()
+	if index < 0 || index >= len(i.similarArtists) {
+		return subsonic.Artist{}, false
+	}
+	return i.similarArtists[index], true
+}
+
+func (i *InfoWidget) fetchArtistInfo(artistId string) {
+	// the browser uses folder ids, which only some servers accept as ID3 ids
+	var info subsonic.ArtistInfo
+	response, err := i.ui.connection.GetArtistInfo2(artistId, subsonic.DefaultSimilarArtistCount)
+	if err == nil {
+		info = response.ArtistInfo2
+	} else if response, err = i.ui.connection.GetArtistInfo(artistId, subsonic.DefaultSimilarArtistCount); err == nil {
+		info = response.ArtistInfo
+	} else {
+		i.ui.logger.PrintError("GetArtistInfo", err)
+	}
+
+	i.ui.app.QueueUpdateDraw(func() {
+		if i.artistId != artistId {
+			return
+		}
+		i.artistInfo = &info
+		i.similarArtists = info.SimilarArtist
+		i.similarList.Clear()
+		for _, artist := range i.similarArtists {
+			i.similarList.AddItem(tview.Escape(artist.Name), "", 0, nil)
+		}
+		i.render()
+	})
+}
+
+func (i *InfoWidget) fetchAlbumInfo(albumId string) {
+	var info subsonic.AlbumInfo
+	response, err := i.ui.connection.GetAlbumInfo2(albumId)
+	if err != nil {
+		response, err = i.ui.connection.GetAlbumInfo(albumId)
+	}
+	if err == nil {
+		info = response.AlbumInfo
+	} else {
+		i.ui.logger.PrintError("GetAlbumInfo", err)
+	}
+
+	i.ui.app.QueueUpdateDraw(func() {
+		if i.albumId != albumId {
+			return
+		}
+		i.albumInfo = &info
+		i.render()
+	})
+}
+
+func (i *InfoWidget) render() {
+	var sb strings.Builder
+
+	if i.albumId != "" {
+		fmt.Fprintf(&sb, "[::b]%s[::-]\n", tview.Escape(stringOr(i.albumName, "Album")))
+		if i.albumInfo == nil {
+			sb.WriteString("[gray]loading...[-]\n")
+		} else {
+			writeInfoText(&sb, i.albumInfo.Notes, i.albumInfo.MusicBrainzId)
+		}
+		sb.WriteString("\n")
+	}
+
+	if i.artistId != "" {
+		fmt.Fprintf(&sb, "[::b]%s[::-]\n", tview.Escape(stringOr(i.artistName, "Artist")))
+		if i.artistInfo == nil {
+			sb.WriteString("[gray]loading...[-]\n")
+		} else {
+			writeInfoText(&sb, i.artistInfo.Biography, i.artistInfo.MusicBrainzId)
+		}
+	}
+
+	i.text.SetText(sb.String())
+	i.text.ScrollToBeginning()
+}
+
+func writeInfoText(sb *strings.Builder, text, musicBrainzId string) {
+	text = stripHtml(text)
+	if text == "" {
+		text = "[gray]no description[-]"
+	} else {
+		text = tview.Escape(text)
+	}
+	sb.WriteString(text + "\n")
+	if musicBrainzId != "" {
+		fmt.Fprintf(sb, "[gray]MusicBrainz:[-] %s\n", tview.Escape(musicBrainzId))
+	}
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// stripHtml turns biographies from last.fm (which contain links) into plain text
+func stripHtml(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
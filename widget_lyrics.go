@@ -0,0 +1,153 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/mpvplayer"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// LyricsWidget shows the lyrics of the playing song. Synced lyrics follow the
+// playback position.
+type LyricsWidget struct {
+	Root *tview.TextView
+
+	// song the lyrics belong to (or are being fetched for)
+	songId string
+	lyrics *subsonic.StructuredLyrics
+	// highlighted line of synced lyrics
+	currentLine int
+
+	// external references
+	ui *Ui
+}
+
+func (ui *Ui) createLyricsWidget() (l *LyricsWidget) {
+	l = &LyricsWidget{
+		currentLine: -1,
+		ui:          ui,
+	}
+
+	l.Root = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetScrollable(true).
+		SetWrap(true).
+		SetWordWrap(true)
+
+	return
+}
+
+// Load fetches the lyrics of song in the background, unless they are already
+// shown. Must be called from the UI goroutine.
+func (l *LyricsWidget) Load(song mpvplayer.QueueItem) {
+	if song.Id == l.songId {
+		return
+	}
+
+	l.songId = song.Id
+	l.lyrics = nil
+	l.currentLine = -1
+	if song.Id == "" {
+		l.Root.SetText("[gray]nothing playing")
+		return
+	}
+	if !song.IsSong() {
+		l.Root.SetText("[gray]no lyrics for radio stations and podcasts")
+		return
+	}
+	l.Root.SetText("[gray]loading lyrics...")
+
+	go func() {
+		lyrics, err := l.ui.connection.GetSongLyrics(song.Id, song.Artist, song.Title)
+		if err != nil {
+			l.ui.logger.PrintError("GetSongLyrics", err)
+		}
+
+		l.ui.app.QueueUpdateDraw(func() {
+			if l.songId != song.Id {
+				// another song started while we were fetching
+				return
+			}
+			if err != nil {
+				l.Root.SetText("[red]failed to load lyrics")
+				return
+			}
+			l.setLyrics(lyrics)
+		})
+	}()
+}
+
+// Clear forgets the current lyrics so the next Load fetches them again
+func (l *LyricsWidget) Clear() {
+	l.songId = ""
+	l.lyrics = nil
+	l.currentLine = -1
+	l.Root.Clear()
+}
+
+// setLyrics picks which of the available lyrics to show, synced ones are
+// preferred.
+func (l *LyricsWidget) setLyrics(available []subsonic.StructuredLyrics) {
+	l.currentLine = -1
+	l.lyrics = nil
+	for i := range available {
+		if len(available[i].Lines) == 0 {
+			continue
+		}
+		if l.lyrics == nil || (available[i].Synced && !l.lyrics.Synced) {
+			l.lyrics = &available[i]
+		}
+	}
+
+	if l.lyrics == nil {
+		l.Root.SetText("[gray]no lyrics found")
+		return
+	}
+	l.render()
+	l.Root.ScrollToBeginning()
+}
+
+// UpdatePosition highlights the line that is sung at position (in seconds).
+// Must be called from the UI goroutine.
+func (l *LyricsWidget) UpdatePosition(position int64) {
+	if l.lyrics == nil || !l.lyrics.Synced {
+		return
+	}
+
+	line := l.lyrics.CurrentLine(int(position * 1000))
+	if line == l.currentLine {
+		return
+	}
+	l.currentLine = line
+
+	if line < 0 {
+		l.Root.Highlight()
+		l.Root.ScrollToBeginning()
+	} else {
+		l.Root.Highlight(lyricsRegion(line))
+		l.Root.ScrollToHighlight()
+	}
+}
+
+func (l *LyricsWidget) render() {
+	var sb strings.Builder
+	for i, line := range l.lyrics.Lines {
+		if l.lyrics.Synced {
+			// regions are used to highlight and scroll to the current line
+			fmt.Fprintf(&sb, "[\"%s\"]%s[\"\"]\n", lyricsRegion(i), tview.Escape(line.Value))
+		} else {
+			sb.WriteString(tview.Escape(line.Value) + "\n")
+		}
+	}
+	l.Root.SetText(sb.String())
+}
+
+func lyricsRegion(line int) string {
+	return fmt.Sprintf("l%d", line)
+}
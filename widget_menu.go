@@ -32,9 +32,18 @@ const (
 	PAGE_PLAYLISTS
 	PAGE_SEARCH
 	PAGE_LOG
+	PAGE_ALBUMS
+	PAGE_GENRES
+	PAGE_RADIO
+	PAGE_PODCASTS
+	PAGE_BOOKMARKS
+	PAGE_STARRED
+	PAGE_NOWPLAYING
+	PAGE_SHARES
+	PAGE_DOWNLOADS
 )
 
-var buttonOrder = []string{PageBrowser, PageQueue, PagePlaylists, PageSearch, PageLog}
+var buttonOrder = []string{PageBrowser, PageQueue, PagePlaylists, PageSearch, PageLog, PageAlbums, PageGenres, PageRadio, PagePodcasts, PageBookmarks, PageStarred, PageNowPlaying, PageShares, PageDownloads}
 
 func (ui *Ui) createMenuWidget() (m *MenuWidget) {
 	m = &MenuWidget{
@@ -98,8 +107,9 @@ func (m *MenuWidget) createPageButtons() {
 		})
 
 		m.buttons[page] = button
-		// add button
-		m.buttonsLeft.AddItem(button, 15, 0, false)
+		// add button, sized to fit the label plus padding
+		width := len(pageLabel(i, page)) + 2
+		m.buttonsLeft.AddItem(button, width, 0, false)
 
 		// add spacer
 		if i < len(buttonOrder)-1 {
@@ -110,17 +120,25 @@ func (m *MenuWidget) createPageButtons() {
 
 func (m *MenuWidget) updatePageButtons() {
 	for i, page := range buttonOrder {
-		var text string
+		text := pageLabel(i, page)
 		if page == m.activeButton {
-			text = fmt.Sprintf("%d: [::b]%s[::-]", i+1, page)
-		} else {
-			text = fmt.Sprintf("%d: %s", i+1, page)
+			text = pageLabel(i, "[::b]"+page+"[::-]")
 		}
 
 		m.buttons[page].SetLabel(text)
 	}
 }
 
+// pageLabel returns the menu label of the page at index of buttonOrder, with
+// the key that shows it. The pages after the first ten are shown with F1,
+// F2, ...
+func pageLabel(index int, page string) string {
+	if index >= 10 {
+		return fmt.Sprintf("F%d: %s", index-9, page)
+	}
+	return fmt.Sprintf("%d: %s", (index+1)%10, page)
+}
+
 func (m *MenuWidget) SetActivePage(name string) {
 	if _, ok := m.buttons[name]; !ok {
 		return // invalid button name
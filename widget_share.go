@@ -0,0 +1,188 @@
+// Copyright 2023 The STMPS Authors
+// SPDX-License-Identifier: GPL-3.0-only
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spezifisch/stmps/subsonic"
+)
+
+// ShareWidget creates or edits shares and shows their public URL
+type ShareWidget struct {
+	FormModal tview.Primitive
+	UrlModal  *tview.Modal
+
+	form *tview.Form
+
+	// songs, albums or playlists a new share is created for, or the share
+	// being edited
+	ids    []string
+	editId string
+
+	url string
+	// URL to be copied by the next draw
+	clipboard atomic.Pointer[string]
+
+	// what had focus before the widget was opened
+	returnFocus tview.Primitive
+
+	// external refs
+	ui *Ui
+}
+
+func (ui *Ui) createShareWidget() *ShareWidget {
+	shareWidget := ShareWidget{
+		ui: ui,
+	}
+
+	shareWidget.form = tview.NewForm().
+		AddInputField("Description", "", 40, nil, nil).
+		AddInputField("Expires in days", "", 6, tview.InputFieldInteger, nil).
+		AddButton("Save", shareWidget.submit).
+		AddButton("Cancel", shareWidget.hideForm)
+	shareWidget.form.SetCancelFunc(shareWidget.hideForm)
+	shareWidget.form.SetBorder(true)
+
+	shareWidget.FormModal = makeModal(shareWidget.form, 62, 9)
+
+	shareWidget.UrlModal = tview.NewModal().
+		AddButtons([]string{"Copy", "Close"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			if buttonLabel == "Copy" {
+				shareWidget.copyUrl()
+			}
+			ui.pages.HidePage(PageShareUrl)
+			ui.app.SetFocus(shareWidget.returnFocus)
+		})
+
+	return &shareWidget
+}
+
+// ShowCreate opens the form for a new share of ids, name is what is shared.
+func (s *ShareWidget) ShowCreate(ids []string, name string) {
+	s.ids = ids
+	s.editId = ""
+	s.showForm(" Share "+name+" ", "", "")
+}
+
+// ShowEdit opens the form to change description and expiry of share. An empty
+// expiry keeps the current one.
+func (s *ShareWidget) ShowEdit(share subsonic.Share) {
+	s.ids = nil
+	s.editId = share.Id
+	s.showForm(" Edit share ", share.Description, "")
+}
+
+// ShowUrl shows the public URL of a share.
+func (s *ShareWidget) ShowUrl(url string) {
+	if name, _ := s.ui.pages.GetFrontPage(); name != PageShareForm {
+		s.returnFocus = s.ui.app.GetFocus()
+	}
+	s.url = url
+	s.UrlModal.SetText("Shared at\n\n" + url)
+	s.ui.pages.ShowPage(PageShareUrl)
+	s.ui.app.SetFocus(s.UrlModal)
+}
+
+func (s *ShareWidget) showForm(title, description, expires string) {
+	s.returnFocus = s.ui.app.GetFocus()
+	s.form.SetTitle(title)
+	s.form.GetFormItem(0).(*tview.InputField).SetText(description)
+	s.form.GetFormItem(1).(*tview.InputField).SetText(expires)
+	s.form.SetFocus(0)
+
+	s.ui.pages.ShowPage(PageShareForm)
+	s.ui.app.SetFocus(s.form)
+}
+
+func (s *ShareWidget) hideForm() {
+	s.ui.pages.HidePage(PageShareForm)
+	s.ui.app.SetFocus(s.returnFocus)
+}
+
+func (s *ShareWidget) submit() {
+	description := strings.TrimSpace(s.form.GetFormItem(0).(*tview.InputField).GetText())
+	expires, err := parseExpiryDays(s.form.GetFormItem(1).(*tview.InputField).GetText())
+	if err != nil {
+		s.form.SetTitle(" Expiry must be a number of days ")
+		return
+	}
+
+	s.hideForm()
+
+	if s.editId != "" {
+		if _, err := s.ui.connection.UpdateShare(s.editId, description, expires); err != nil {
+			s.ui.showErrorMessage("Updating share", err)
+			return
+		}
+		s.ui.sharesPage.Load()
+		return
+	}
+
+	response, err := s.ui.connection.CreateShare(s.ids, description, expires)
+	if err != nil {
+		s.ui.showErrorMessage("Creating share", err)
+		return
+	}
+	if len(response.Shares.Share) == 0 {
+		s.ui.showErrorMessage("Creating share", errors.New("server returned no share"))
+		return
+	}
+	s.ShowUrl(response.Shares.Share[0].Url)
+}
+
+// copyUrl has the terminal put the URL into the system clipboard, tview can't
+// tell whether it supports this. It's written by the next draw, see
+// writeClipboard.
+func (s *ShareWidget) copyUrl() {
+	url := s.url
+	s.clipboard.Store(&url)
+}
+
+// writeClipboard is called after the screen was drawn and before tcell writes
+// it out, so the escape sequence doesn't end up in the middle of its output
+func (s *ShareWidget) writeClipboard(screen tcell.Screen) {
+	url := s.clipboard.Swap(nil)
+	if url == nil {
+		return
+	}
+	tty, ok := screen.Tty()
+	if !ok {
+		s.ui.logger.PrintError("copyUrl", errors.New("the screen isn't a terminal"))
+		return
+	}
+	if _, err := tty.Write([]byte(osc52Sequence(*url))); err != nil {
+		s.ui.logger.PrintError("copyUrl", err)
+		return
+	}
+	s.ui.logger.Printf("copied %s to the clipboard", *url)
+}
+
+// parseExpiryDays turns the number of days from now into the expiry time, an
+// empty text is the zero time.
+func parseExpiryDays(text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, nil
+	}
+	days, err := strconv.Atoi(text)
+	if err != nil || days <= 0 {
+		return time.Time{}, fmt.Errorf("invalid number of days: %q", text)
+	}
+	return time.Now().AddDate(0, 0, days), nil
+}
+
+// osc52Sequence is the terminal escape sequence that sets the clipboard to text
+func osc52Sequence(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}